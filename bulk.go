@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/bulk"
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// selectProjects returns the known projects picked by -all, -projects (names
+// or globs) and -tag. -projects and -tag together select the projects that
+// match both.
+func selectProjects(db *database.Database, all bool, patterns, tag string) ([]database.Project, error) {
+	projects, err := db.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]database.Project, len(projects))
+	var names []string
+	for _, p := range projects {
+		byName[p.Name] = p
+		names = append(names, p.Name)
+	}
+
+	if !all && patterns != "" {
+		if names, err = bulk.Match(names, strings.Split(patterns, ",")); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+
+	var out []database.Project
+	for _, n := range names {
+		p := byName[n]
+		if tag == "" || p.HasTag(tag) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// runBulk starts or stops the selected projects, at most parallel at a time,
+// and prints a summary. It returns an error if any project failed.
+func runBulk(db *database.Database, mgr *docker.Manager, projects []database.Project, stop bool, parallel int) error {
+	if len(projects) == 0 {
+		return fmt.Errorf("no projects match the selection")
+	}
+	byName := make(map[string]*database.Project, len(projects))
+	names := make([]string, len(projects))
+	for i := range projects {
+		byName[projects[i].Name] = &projects[i]
+		names[i] = projects[i].Name
+	}
+
+	action := OP_START
+	if stop {
+		action = OP_STOP
+	}
+	fmt.Printf("Running %s on %d project(s), %d at a time: %s\n", action, len(names), parallel, strings.Join(names, ", "))
+
+	op := func(name string) error { return bulkStop(mgr, byName[name]) }
+	if !stop {
+		// Prepare one project at a time before any start in parallel
+		preps := make(map[string]startPrep, len(names))
+		for _, n := range names {
+			preps[n] = prepareStart(db, mgr, byName[n])
+		}
+		op = func(name string) error { return bulkStart(mgr, byName[name], preps[name]) }
+	}
+	results := bulk.Run(names, parallel, func(name string) error {
+		started := time.Now()
+		err := op(name)
+		// Skipped projects were not touched
+		var skipped bulk.Skipped
+		if !errors.As(err, &skipped) {
+			recordEvent(db, name, action, started, err)
+		}
+		return err
+	})
+
+	fmt.Println()
+	if err := bulk.Print(os.Stdout, action, results); err != nil {
+		return err
+	}
+	if n := bulk.Failed(results); n > 0 {
+		return fmt.Errorf("%s failed for %d of %d project(s)", action, n, len(results))
+	}
+	return nil
+}
+
+// startPrep is what a bulk start resolves before projects start in parallel:
+// a failed pull may prompt for an image bundle, and port allocation has to
+// see the ports claimed by the projects before it.
+type startPrep struct {
+	creds  database.Credentials
+	images docker.Images
+	ports  docker.Ports
+	err    error // Why the project is skipped or cannot start
+}
+
+// prepareStart checks whether a project needs starting and resolves its
+// images, without prompting, and its ports, assigning and storing any that
+// are missing as a single-project start does.
+func prepareStart(db *database.Database, mgr *docker.Manager, proj *database.Project) startPrep {
+	if proj.SSHHost != "" {
+		return startPrep{err: remoteSkipped(proj)}
+	}
+	running, err := mgr.IsRunning(proj.Name)
+	if err != nil {
+		return startPrep{err: err}
+	}
+	if running {
+		return startPrep{err: bulk.Skipped("already running")}
+	}
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return startPrep{err: err}
+	}
+	if creds == nil {
+		return startPrep{err: fmt.Errorf("no credentials stored for project %s", proj.Name)}
+	}
+	images, err := projectImages(db, mgr, proj, false, "", false)
+	if err != nil {
+		return startPrep{err: err}
+	}
+	ports, err := resolvePorts(db, mgr, proj.Name, projectPorts(proj))
+	if err != nil {
+		return startPrep{err: fmt.Errorf("failed to allocate ports: %w", err)}
+	}
+	return startPrep{creds: *creds, images: images, ports: ports}
+}
+
+// bulkStart starts a stopped project with what prepareStart resolved.
+func bulkStart(mgr *docker.Manager, proj *database.Project, prep startPrep) error {
+	if prep.err != nil {
+		return prep.err
+	}
+	return spawnStored(mgr, proj, prep.creds, prep.images, prep.ports)
+}
+
+// bulkStop stops a running project's containers.
+func bulkStop(mgr *docker.Manager, proj *database.Project) error {
+	if proj.SSHHost != "" {
+		return remoteSkipped(proj)
+	}
+	name := proj.Name
+	running, err := mgr.IsRunning(name)
+	if err != nil {
+		return err
+	}
+	if !running {
+		return bulk.Skipped("not running")
+	}
+	fmt.Printf("Stopping containers for project %s...\n", name)
+	if err := mgr.StopProjectContainers(name); err != nil {
+		return err
+	}
+	if still, _ := mgr.IsRunning(name); still {
+		return fmt.Errorf("containers are still running")
+	}
+	return nil
+}
+
+// remoteSkipped skips projects on a remote host: bulk runs use the local
+// engine only.
+func remoteSkipped(proj *database.Project) error {
+	return bulk.Skipped(fmt.Sprintf("runs on %s; use -name %s", proj.SSHHost, proj.Name))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Mortimus/SiloHound/internal/bloodhound"
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// loadCredentials returns the stored credentials for a project, creating them
+// on first use. Data directories that were initialized before credentials were
+// stored keep the legacy passwords, since Postgres and Neo4j only apply their
+// password settings when the data directory is empty.
+func loadCredentials(db *database.Database, name, workingDir string) (database.Credentials, error) {
+	stored, err := db.GetCredentials(name)
+	if err != nil {
+		return database.Credentials{}, err
+	}
+	if stored != nil {
+		return *stored, nil
+	}
+
+	var creds database.Credentials
+	if _, err := os.Stat(filepath.Join(workingDir, docker.PSQLFOLDER, "PG_VERSION")); err == nil {
+		fmt.Println("Existing data directory detected; keeping the legacy default credentials.")
+		fmt.Printf("Run '%s -name %s -rotate-creds' while the project is running to replace them.\n", os.Args[0], name)
+		creds = database.LegacyCredentials()
+	} else {
+		creds, err = database.GenerateCredentials()
+		if err != nil {
+			return database.Credentials{}, err
+		}
+	}
+
+	if err := db.SetCredentials(name, creds); err != nil {
+		return database.Credentials{}, err
+	}
+	return creds, nil
+}
+
+func printCredentials(name string, creds database.Credentials) {
+	fmt.Printf("Credentials for project %s:\n", name)
+	fmt.Printf("  BloodHound: %s / %s\n", creds.AdminUser, creds.AdminPassword)
+	fmt.Printf("  Neo4j:      neo4j / %s\n", creds.Neo4jPassword)
+	fmt.Printf("  Postgres:   bloodhound / %s\n", creds.PostgresPassword)
+}
+
+// rotateCredentials replaces every service password of a running project.
+// Each change is saved as soon as it succeeds so the database never falls
+// behind the running services, then BloodHound is restarted with the new
+// connection settings.
+func rotateCredentials(db *database.Database, mgr *docker.Manager, proj *database.Project) error {
+	current, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("no credentials stored for project %s; start it once first", proj.Name)
+	}
+	creds := *current
+
+	next, err := database.GenerateCredentials()
+	if err != nil {
+		return err
+	}
+
+	psqlID, err := mgr.ContainerID(proj.Name, docker.ROLE_POSTGRES)
+	if err != nil {
+		return err
+	}
+	neo4jID, err := mgr.ContainerID(proj.Name, docker.ROLE_NEO4J)
+	if err != nil {
+		return err
+	}
+	if psqlID == "" || neo4jID == "" {
+		return fmt.Errorf("project %s must be running to rotate credentials", proj.Name)
+	}
+
+	fmt.Println("Rotating BloodHound admin password...")
+	bh := bloodhound.NewClient(fmt.Sprintf("http://127.0.0.1:%d", proj.BHPort))
+	if err := bh.Login(creds.AdminUser, creds.AdminPassword); err != nil {
+		return err
+	}
+	if err := bh.ChangePassword(creds.AdminPassword, next.AdminPassword); err != nil {
+		return fmt.Errorf("failed to change admin password: %w", err)
+	}
+	creds.AdminPassword = next.AdminPassword
+	if err := db.SetCredentials(proj.Name, creds); err != nil {
+		return err
+	}
+
+	fmt.Println("Rotating Neo4j password...")
+	if err := mgr.ChangeNeo4jPassword(neo4jID, creds.Neo4jPassword, next.Neo4jPassword); err != nil {
+		return fmt.Errorf("failed to change Neo4j password: %w", err)
+	}
+	creds.Neo4jPassword = next.Neo4jPassword
+	if err := db.SetCredentials(proj.Name, creds); err != nil {
+		return err
+	}
+
+	fmt.Println("Rotating Postgres password...")
+	if err := mgr.ChangePostgresPassword(psqlID, "bloodhound", next.PostgresPassword); err != nil {
+		return fmt.Errorf("failed to change Postgres password: %w", err)
+	}
+	creds.PostgresPassword = next.PostgresPassword
+	if err := db.SetCredentials(proj.Name, creds); err != nil {
+		return err
+	}
+
+	fmt.Println("Restarting BloodHound with the new credentials...")
+	netName, err := mgr.EnsureNetwork(proj.Name, proj.Path)
+	if err != nil {
+		return err
+	}
+	ports := projectPorts(proj)
+	bhImage := proj.BHImage
+	if bhImage == "" {
+		bhImage = docker.BLOODHOUND
+	}
+	if _, err := mgr.SpawnBloodhound(proj.Name, proj.Path, netName, bhImage, creds.AdminUser, creds.AdminPassword, creds.PostgresPassword, creds.Neo4jPassword, ports, roleLimits(storedResources(proj), docker.ROLE_BLOODHOUND)); err != nil {
+		return fmt.Errorf("credentials rotated but BloodHound failed to restart: %w", err)
+	}
+	return nil
+}
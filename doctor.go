@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/doctor"
+)
+
+// doctorCheck runs the preflight checks for one project, or all known
+// projects when name is empty, and reports whether none of them failed.
+func doctorCheck(db *database.Database, mgr *docker.Manager, engineErr error, name, heap string) bool {
+	// Auto sizing always fits the host, so check against its smallest heap
+	if heap == AUTO_SIZE {
+		heap = docker.FormatJVMSize(docker.MIN_AUTO_HEAP)
+	}
+	opts := doctor.Options{
+		Heap:      heap,
+		Images:    docker.DefaultImages().List(),
+		EngineErr: engineErr,
+	}
+	if name != "" {
+		proj, err := db.GetProject(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj != nil {
+			opts.Projects = []database.Project{*proj}
+		} else {
+			fmt.Printf("Project %s is not registered yet; checking the defaults a new project would use.\n\n", name)
+		}
+	} else {
+		projects, err := db.ListProjects()
+		if err != nil {
+			log.Fatalf("Failed to list projects: %v", err)
+		}
+		opts.Projects = projects
+	}
+
+	report := doctor.Run(mgr, opts)
+	report.Print(os.Stdout)
+	return report.Count(doctor.STATUS_FAIL) == 0
+}
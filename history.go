@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/plan"
+	"github.com/Mortimus/SiloHound/internal/secrets"
+)
+
+// Operations recorded in the project history.
+const (
+	OP_CREATE          = "create"
+	OP_START           = "start"
+	OP_STOP            = "stop"
+	OP_MOVE            = "move"
+	OP_CLEAN           = "clean"
+	OP_UPDATE          = "update"
+	OP_AUDIT           = "audit"
+	OP_INJECT_QUERIES  = "inject-queries"
+	OP_SNAPSHOT        = "snapshot"
+	OP_RESTORE         = "restore"
+	OP_DELETE_SNAPSHOT = "delete-snapshot"
+	OP_UPGRADE         = "upgrade"
+	OP_MIGRATE_NEO4J   = "migrate-neo4j"
+	OP_MIGRATE_STORAGE = "migrate-storage"
+	OP_EXPORT          = "export"
+	OP_IMPORT          = "import"
+	OP_SHOW_CREDS      = "show-creds"
+	OP_ROTATE_CREDS    = "rotate-creds"
+)
+
+// operation is a recorded operation in progress.
+type operation struct {
+	db      *database.Database
+	project string
+	name    string
+	started time.Time
+	ended   bool
+}
+
+// beginOperation starts recording an operation on a project. End it with the
+// operation's result, or exit through fail so the failure is recorded.
+func beginOperation(db *database.Database, project, name string) *operation {
+	return &operation{db: db, project: project, name: name, started: time.Now()}
+}
+
+// end records the operation once.
+func (op *operation) end(err error) {
+	if op.ended {
+		return
+	}
+	op.ended = true
+	recordEvent(op.db, op.project, op.name, op.started, err)
+}
+
+// fail is log.Fatalf for recorded operations: it records op as failed with
+// the message before exiting. op may be nil when nothing is recorded.
+func fail(op *operation, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if op != nil {
+		op.end(errors.New(msg))
+	}
+	log.Print(msg)
+	os.Exit(1)
+}
+
+// recordEvent appends an operation to the history. Failing to record is
+// reported but does not fail the operation.
+func recordEvent(db *database.Database, project, name string, started time.Time, err error) {
+	e := database.Event{
+		Project:   project,
+		Operation: name,
+		User:      osUser(),
+		Args:      strings.Join(commandArgs(), " "),
+		Outcome:   database.OUTCOME_OK,
+		Duration:  time.Since(started),
+		StartedAt: started,
+	}
+	if err != nil {
+		e.Outcome = database.OUTCOME_FAILED
+		e.Error = err.Error()
+	}
+	if _, err := db.AddEvent(e); err != nil {
+		fmt.Printf("Warning: Failed to record %s of project %s in the history: %v\n", name, project, err)
+	}
+}
+
+// recordedStep records a plan step as an operation of its own.
+func recordedStep(db *database.Database, project, name string, step plan.Step) plan.Step {
+	do := step.Do
+	step.Do = func() error {
+		started := time.Now()
+		err := do()
+		recordEvent(db, project, name, started, err)
+		return err
+	}
+	return step
+}
+
+// osUser names the user running SiloHound, and the user behind sudo.
+func osUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if name == "" {
+		name = "uid " + strconv.Itoa(os.Getuid())
+	}
+	if sudo := os.Getenv("SUDO_USER"); sudo != "" && sudo != name {
+		name += " (sudo by " + sudo + ")"
+	}
+	return name
+}
+
+// commandArgs is the command line with secrets redacted.
+func commandArgs() []string {
+	return secrets.RedactArgs(os.Args[1:], func(name string) bool {
+		f := flag.Lookup(name)
+		if f == nil {
+			return false
+		}
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		return !ok || !b.IsBoolFlag()
+	})
+}
+
+// historyEvent is the exported form of an event.
+type historyEvent struct {
+	Time      string  `json:"time"`
+	Project   string  `json:"project"`
+	Operation string  `json:"operation"`
+	User      string  `json:"user"`
+	Outcome   string  `json:"outcome"`
+	Seconds   float64 `json:"duration_seconds"`
+	Args      string  `json:"args"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func toHistoryEvent(e database.Event) historyEvent {
+	return historyEvent{
+		Time:      e.StartedAt.Format(time.RFC3339),
+		Project:   e.Project,
+		Operation: e.Operation,
+		User:      e.User,
+		Outcome:   e.Outcome,
+		Seconds:   e.Duration.Round(time.Millisecond).Seconds(),
+		Args:      e.Args,
+		Error:     e.Error,
+	}
+}
+
+// printHistory shows the history of one project, or of all projects if
+// project is empty, as a table or as JSON.
+func printHistory(db *database.Database, project string, asJSON bool) error {
+	events, err := db.ListEvents(project)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeHistoryJSON(os.Stdout, events)
+	}
+	if len(events) == 0 {
+		fmt.Println("No operations recorded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tPROJECT\tOPERATION\tUSER\tOUTCOME\tDURATION\tDETAIL")
+	for _, e := range events {
+		detail := e.Args
+		if e.Error != "" {
+			detail = e.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.StartedAt.Local().Format("2006-01-02 15:04:05"), e.Project, e.Operation, e.User, e.Outcome, e.Duration.Round(time.Second), detail)
+	}
+	return tw.Flush()
+}
+
+// exportHistory writes the history to dest as JSON if it ends in .json, and
+// as CSV otherwise.
+func exportHistory(db *database.Database, project, dest string) error {
+	events, err := db.ListEvents(project)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(dest), ".json") {
+		err = writeHistoryJSON(f, events)
+	} else {
+		err = writeHistoryCSV(f, events)
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d event(s) to %s\n", len(events), dest)
+	return nil
+}
+
+func writeHistoryJSON(w io.Writer, events []database.Event) error {
+	out := []historyEvent{}
+	for _, e := range events {
+		out = append(out, toHistoryEvent(e))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeHistoryCSV(w io.Writer, events []database.Event) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "project", "operation", "user", "outcome", "duration_seconds", "args", "error"})
+	for _, e := range events {
+		h := toHistoryEvent(e)
+		cw.Write([]string{h.Time, h.Project, h.Operation, h.User, h.Outcome, strconv.FormatFloat(h.Seconds, 'f', 3, 64), h.Args, h.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/offline"
+)
+
+// ensureImages makes sure every image is available locally, pulling the ones
+// that are missing (or all of them when pull is set). When a required pull
+// fails, an offline bundle is loaded instead: the one given with
+// -image-bundle, or one the user is prompted for unless prompt is off. It
+// returns the local reference to start each image from.
+func ensureImages(mgr *docker.Manager, images docker.Images, pull bool, bundle string, prompt bool) (docker.Images, error) {
+	imported := false
+	ensure := func(ref string) (string, error) {
+		localRef, exists, _ := mgr.LocalImage(ref)
+		if exists && !pull {
+			fmt.Printf("Image %s found locally. Skipping pull.\n", ref)
+			return localRef, nil
+		}
+
+		fmt.Printf("Pulling %s...\n", ref)
+		err := mgr.PullImage(ref)
+		if err == nil {
+			return ref, nil
+		}
+		if exists {
+			fmt.Printf("Warning: Failed to pull %s: %v\n", ref, err)
+			return localRef, nil
+		}
+		if imported {
+			return "", fmt.Errorf("failed to pull %s and the image bundle does not contain it: %w", ref, err)
+		}
+
+		fmt.Printf("Failed to pull %s: %v\n", ref, err)
+		if bundle == "" && prompt {
+			bundle = promptImageBundle()
+		}
+		if bundle == "" {
+			return "", fmt.Errorf("failed to pull %s: %w", ref, err)
+		}
+		if err := importImageBundle(mgr, bundle); err != nil {
+			return "", err
+		}
+		imported = true
+
+		localRef, exists, _ = mgr.LocalImage(ref)
+		if !exists {
+			return "", fmt.Errorf("image bundle %s does not contain %s", bundle, ref)
+		}
+		return localRef, nil
+	}
+
+	var out docker.Images
+	var err error
+	if out.Postgres, err = ensure(images.Postgres); err != nil {
+		return docker.Images{}, err
+	}
+	if out.Neo4j, err = ensure(images.Neo4j); err != nil {
+		return docker.Images{}, err
+	}
+	if out.BloodHound, err = ensure(images.BloodHound); err != nil {
+		return docker.Images{}, err
+	}
+	return out, nil
+}
+
+func promptImageBundle() string {
+	fmt.Println("No registry access? Images can be loaded from an offline bundle created with -export-images.")
+	fmt.Print("Path to image bundle (leave empty to abort): ")
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return ""
+	}
+	return strings.TrimSpace(scanner.Text())
+}
+
+func importImageBundle(mgr *docker.Manager, path string) error {
+	fmt.Printf("Loading image bundle %s...\n", path)
+	manifest, err := offline.ImportImages(mgr, path)
+	if err != nil {
+		return fmt.Errorf("failed to load image bundle: %w", err)
+	}
+	fmt.Printf("Loaded %d image(s) from bundle created by SiloHound %s on %s.\n", len(manifest.Images), manifest.SiloHoundVersion, manifest.CreatedAt.Format(time.RFC822))
+	return nil
+}
+
+// exportImageBundle saves the default images, the Neo4j 5 image needed to
+// migrate, plus every image pinned by a known project into a bundle for
+// machines without registry access.
+func exportImageBundle(db *database.Database, mgr *docker.Manager, path string) error {
+	refs := append(docker.DefaultImages().List(), docker.NEO4J_5)
+	projects, err := db.ListProjects()
+	if err != nil {
+		return err
+	}
+	for i := range projects {
+		refs = append(refs, pinnedImages(&projects[i]).List()...)
+	}
+
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		if _, exists, _ := mgr.LocalImage(ref); !exists {
+			fmt.Printf("Pulling %s...\n", ref)
+			if err := mgr.PullImage(ref); err != nil {
+				return fmt.Errorf("failed to pull %s: %w", ref, err)
+			}
+		}
+	}
+
+	manifest, err := offline.ExportImages(mgr, refs, path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d image(s) to %s\n", len(manifest.Images), path)
+	return nil
+}
+
+// projectImages returns the images pinned for a project. Projects without
+// pins are pinned to the digests of the default images on first start, so a
+// later -pull can never move them to a different version.
+func projectImages(db *database.Database, mgr *docker.Manager, proj *database.Project, pull bool, bundle string, prompt bool) (docker.Images, error) {
+	pinned := pinnedImages(proj)
+	if !pinned.IsZero() {
+		if pull {
+			fmt.Println("Images are pinned by digest; use -upgrade to move to newer versions.")
+		}
+		return ensureImages(mgr, pinned, pull, bundle, prompt)
+	}
+
+	local, err := ensureImages(mgr, docker.DefaultImagesFor(proj.Neo4jVersion), pull, bundle, prompt)
+	if err != nil {
+		return docker.Images{}, err
+	}
+	resolved, err := mgr.ResolveImages(local)
+	if err != nil {
+		return docker.Images{}, err
+	}
+	if err := db.UpdateProjectImages(proj.Name, resolved.BloodHound, resolved.Neo4j, resolved.Postgres); err != nil {
+		return docker.Images{}, err
+	}
+	fmt.Println("Pinned project images:")
+	printImages(resolved)
+	return resolved, nil
+}
+
+func pinnedImages(proj *database.Project) docker.Images {
+	return docker.Images{
+		BloodHound: proj.BHImage,
+		Neo4j:      proj.Neo4jImage,
+		Postgres:   proj.PostgresImage,
+	}
+}
+
+func printImages(images docker.Images) {
+	fmt.Printf("  BloodHound: %s\n", images.BloodHound)
+	fmt.Printf("  Neo4j:      %s\n", images.Neo4j)
+	fmt.Printf("  Postgres:   %s\n", images.Postgres)
+}
@@ -0,0 +1,109 @@
+package bloodhound
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Client struct {
+	url     string
+	token   string
+	userID  string
+	httpCli *http.Client
+}
+
+func NewClient(url string) *Client {
+	return &Client{
+		url:     url, // e.g. http://127.0.0.1:8181
+		httpCli: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Login authenticates with a username and secret and keeps the session token
+// for later calls.
+func (c *Client) Login(username, secret string) error {
+	body := map[string]string{
+		"login_method": "secret",
+		"username":     username,
+		"secret":       secret,
+	}
+
+	var resBody struct {
+		Data struct {
+			UserID       string `json:"user_id"`
+			SessionToken string `json:"session_token"`
+		} `json:"data"`
+	}
+	if err := c.do("POST", "/api/v2/login", body, &resBody); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	if resBody.Data.SessionToken == "" {
+		return fmt.Errorf("login failed: no session token returned")
+	}
+
+	c.token = resBody.Data.SessionToken
+	c.userID = resBody.Data.UserID
+	return nil
+}
+
+// ChangePassword sets a new secret for the logged in user.
+func (c *Client) ChangePassword(current, secret string) error {
+	if c.token == "" {
+		return fmt.Errorf("not logged in")
+	}
+	body := map[string]interface{}{
+		"current_secret":       current,
+		"secret":               secret,
+		"needs_password_reset": false,
+	}
+	return c.do("PUT", fmt.Sprintf("/api/v2/bloodhound-users/%s/secret", c.userID), body, nil)
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reader *bytes.Buffer
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewBuffer(b)
+	} else {
+		reader = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("bloodhound returned status %d: %s", resp.StatusCode, apiErr.Errors[0].Message)
+		}
+		return fmt.Errorf("bloodhound returned status %d", resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,129 @@
+// Package bulk runs one operation on several projects at once, with a limit
+// on how many run concurrently, and reports the outcome per project.
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome for one project.
+type Status string
+
+const (
+	STATUS_OK      Status = "ok"
+	STATUS_SKIPPED Status = "skipped"
+	STATUS_FAILED  Status = "FAILED"
+
+	DEFAULT_PARALLEL = 3
+)
+
+// Skipped is returned by an operation that had nothing to do for a project,
+// e.g. stopping a project that is not running.
+type Skipped string
+
+func (s Skipped) Error() string { return string(s) }
+
+type Result struct {
+	Project  string
+	Status   Status
+	Err      error // Why the operation failed or was skipped
+	Duration time.Duration
+}
+
+// Run calls op for every project, at most parallel at a time, and returns the
+// results in the order of projects.
+func Run(projects []string, parallel int, op func(project string) error) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]Result, len(projects))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, name := range projects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := op(name)
+			res := Result{Project: name, Status: STATUS_OK, Err: err, Duration: time.Since(start)}
+			var skipped Skipped
+			switch {
+			case errors.As(err, &skipped):
+				res.Status = STATUS_SKIPPED
+			case err != nil:
+				res.Status = STATUS_FAILED
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Failed counts the projects the operation failed for.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == STATUS_FAILED {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes a summary table with one line per project.
+func Print(w io.Writer, action string, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "PROJECT\t%s\tTIME\tDETAIL\n", strings.ToUpper(action))
+	for _, r := range results {
+		detail := ""
+		if r.Err != nil {
+			detail = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Project, r.Status, r.Duration.Round(100*time.Millisecond), detail)
+	}
+	return tw.Flush()
+}
+
+// Match returns the names matching any of the patterns, sorted. Patterns are
+// shell globs (see path.Match); a pattern without wildcards must name an
+// existing project exactly.
+func Match(names, patterns []string) ([]string, error) {
+	selected := map[string]bool{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		found := false
+		for _, n := range names {
+			if ok, _ := path.Match(p, n); ok {
+				selected[n] = true
+				found = true
+			}
+		}
+		if !found && !strings.ContainsAny(p, "*?[") {
+			return nil, fmt.Errorf("project %s not found", p)
+		}
+	}
+	out := make([]string, 0, len(selected))
+	for n := range selected {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out, nil
+}
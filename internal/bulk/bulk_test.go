@@ -0,0 +1,86 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	op := func(name string) error {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		switch name {
+		case "idle":
+			return Skipped("not running")
+		case "broken":
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	names := []string{"a", "idle", "b", "broken", "c"}
+	results := Run(names, 2, op)
+	if peak > 2 {
+		t.Errorf("ran %d operations at once, want at most 2", peak)
+	}
+	want := []Status{STATUS_OK, STATUS_SKIPPED, STATUS_OK, STATUS_FAILED, STATUS_OK}
+	for i, r := range results {
+		if r.Project != names[i] || r.Status != want[i] {
+			t.Errorf("result %d = %s %s, want %s %s", i, r.Project, r.Status, names[i], want[i])
+		}
+	}
+	if n := Failed(results); n != 1 {
+		t.Errorf("Failed = %d, want 1", n)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, "stop", results); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"PROJECT", "STOP", "not running", "boom"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("summary lacks %q:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	names := []string{"acme-corp", "acme-dev", "globex", "initech"}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"acme-*"}, []string{"acme-corp", "acme-dev"}},
+		{[]string{"globex", "acme-dev", "globex"}, []string{"acme-dev", "globex"}},
+		{[]string{"*"}, names},
+		{[]string{"nomatch-*"}, []string{}},
+	}
+	for _, tt := range tests {
+		got, err := Match(names, tt.patterns)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%v) = %v, %v; want %v", tt.patterns, got, err, tt.want)
+		}
+	}
+	if _, err := Match(names, []string{"missing"}); err == nil {
+		t.Error("Match accepted an unknown project name")
+	}
+	if _, err := Match(names, []string{"[bad"}); err == nil {
+		t.Error("Match accepted an invalid pattern")
+	}
+}
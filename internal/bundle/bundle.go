@@ -0,0 +1,307 @@
+// Package bundle packs a whole project (data, saved queries, audit reports,
+// settings and credentials) into one archive that can be imported on another
+// machine.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+const (
+	MANIFEST_NAME  = "manifest.json"
+	FORMAT_VERSION = 1
+
+	DATA_FILE    = "data.tar.gz"  // bloodhound-data, archived with ownership
+	QUERIES_FILE = "queries.json" // Saved queries in importer.BloodHoundQueries format
+	REPORTS_DIR  = "reports"
+)
+
+// Manifest describes a project bundle. It is always the first entry.
+type Manifest struct {
+	FormatVersion    int                  `json:"format_version"`
+	SiloHoundVersion string               `json:"silohound_version"`
+	CreatedAt        time.Time            `json:"created_at"`
+	Project          Project              `json:"project"`
+	Images           Images               `json:"images"`
+	Credentials      database.Credentials `json:"credentials"`
+	Files            []FileEntry          `json:"files"`
+}
+
+// Project is the project's settings and engagement details. Bundles from
+// older versions carry only the name, creation time and heap.
+type Project struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Neo4jHeap string    `json:"neo4j_heap,omitempty"`
+
+	Neo4jPlugins   string  `json:"neo4j_plugins,omitempty"`
+	Neo4jPageCache string  `json:"neo4j_pagecache,omitempty"`
+	CPULimit       float64 `json:"cpu_limit,omitempty"`
+	PostgresMemory string  `json:"postgres_memory,omitempty"`
+	Neo4jMemory    string  `json:"neo4j_memory,omitempty"`
+	BHMemory       string  `json:"bloodhound_memory,omitempty"`
+
+	Client          string `json:"client,omitempty"`
+	EngagementStart string `json:"engagement_start,omitempty"`
+	EngagementEnd   string `json:"engagement_end,omitempty"`
+	Domains         string `json:"domains,omitempty"`
+	Tags            string `json:"tags,omitempty"`
+	Notes           string `json:"notes,omitempty"`
+	Status          string `json:"status,omitempty"`
+}
+
+// Images are the pinned references the project ran on, plus the image
+// versions its data directories were written by.
+type Images struct {
+	BloodHound      string `json:"bloodhound"`
+	Neo4j           string `json:"neo4j"`
+	Postgres        string `json:"postgres"`
+	Neo4jVersion    string `json:"neo4j_version"`
+	PostgresVersion string `json:"postgres_version"`
+}
+
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Write creates the bundle at dest. files maps archive names to local paths.
+// The bundle holds credentials, so it is only readable by the owner.
+func Write(dest string, manifest *Manifest, files map[string]string) error {
+	manifest.FormatVersion = FORMAT_VERSION
+	manifest.SiloHoundVersion = docker.Version
+	manifest.Files = nil
+	for name, src := range files {
+		if err := checkName(name); err != nil {
+			return err
+		}
+		size, sum, err := checksum(src)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, FileEntry{Name: name, Size: size, SHA256: sum})
+	}
+
+	tmpPath := dest + ".partial"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: MANIFEST_NAME, Mode: 0600, Size: int64(len(b)), ModTime: manifest.CreatedAt}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+
+	for _, entry := range manifest.Files {
+		f, err := os.Open(files[entry.Name])
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: entry.Name, Mode: 0600, Size: entry.Size, ModTime: manifest.CreatedAt})
+		if err == nil {
+			_, err = io.CopyN(tw, f, entry.Size)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", entry.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, dest)
+}
+
+// ReadManifest returns the manifest of the bundle at src without extracting
+// anything.
+func ReadManifest(src string) (*Manifest, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr, closeFn, err := openTar(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	return readManifest(tr)
+}
+
+// Extract verifies every file listed in the manifest and writes it under
+// dir. Nothing outside dir is ever written.
+func Extract(src, dir string) (*Manifest, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr, closeFn, err := openTar(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]FileEntry, len(manifest.Files))
+	for _, e := range manifest.Files {
+		if err := checkName(e.Name); err != nil {
+			return nil, err
+		}
+		entries[e.Name] = e
+	}
+
+	extracted := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := entries[hdr.Name]
+		if !ok {
+			continue
+		}
+		if err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(entry.Name)), entry); err != nil {
+			return nil, err
+		}
+		extracted++
+	}
+	if extracted != len(manifest.Files) {
+		return nil, fmt.Errorf("bundle is incomplete: found %d of %d files", extracted, len(manifest.Files))
+	}
+	return manifest, nil
+}
+
+// CheckCompatible refuses bundles whose data directories were written by a
+// different major version of Postgres or Neo4j than the ones given, since
+// neither can open the other's data files.
+func CheckCompatible(m *Manifest, postgresVersion, neo4jVersion string) error {
+	if major(m.Images.PostgresVersion) != major(postgresVersion) {
+		return fmt.Errorf("bundle data was written by Postgres %s, this SiloHound runs Postgres %s", m.Images.PostgresVersion, postgresVersion)
+	}
+	if major(m.Images.Neo4jVersion) != major(neo4jVersion) {
+		return fmt.Errorf("bundle data was written by Neo4j %s, this SiloHound runs Neo4j %s", m.Images.Neo4jVersion, neo4jVersion)
+	}
+	return nil
+}
+
+func major(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+func openTar(r io.Reader) (*tar.Reader, func(), error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a SiloHound project bundle: %w", err)
+	}
+	return tar.NewReader(gz), func() { gz.Close() }, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if hdr.Name != MANIFEST_NAME {
+		return nil, fmt.Errorf("not a SiloHound project bundle: first entry is %s", hdr.Name)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.FormatVersion > FORMAT_VERSION {
+		return nil, fmt.Errorf("bundle format %d is newer than this SiloHound supports (%d)", manifest.FormatVersion, FORMAT_VERSION)
+	}
+	return &manifest, nil
+}
+
+// checkName rejects archive names that could escape the extraction directory.
+func checkName(name string) error {
+	clean := path.Clean(name)
+	if name == "" || clean != name || path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") || name == MANIFEST_NAME {
+		return fmt.Errorf("invalid file name in bundle: %q", name)
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, dest string, entry FileEntry) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	if n != entry.Size {
+		return fmt.Errorf("%s: size mismatch (got %d, manifest says %d)", entry.Name, n, entry.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != entry.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch (got %s, manifest says %s)", entry.Name, sum, entry.SHA256)
+	}
+	return nil
+}
+
+func checksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,148 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+)
+
+func writeTestBundle(t *testing.T) (string, *Manifest) {
+	t.Helper()
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.tar.gz")
+	report := filepath.Join(dir, "report.html")
+	os.WriteFile(data, []byte("pretend archive"), 0644)
+	os.WriteFile(report, []byte("<html></html>"), 0644)
+
+	m := &Manifest{
+		CreatedAt:   time.Now().UTC(),
+		Project:     Project{Name: "Acme", Neo4jHeap: "4G", Neo4jPlugins: "apoc", CPULimit: 2, Neo4jMemory: "8g", Client: "Acme Corp", EngagementStart: "2025-07-01", Domains: "acme.local", Status: "reporting"},
+		Images:      Images{Neo4j: "neo4j@sha256:1", Postgres: "postgres@sha256:2", Neo4jVersion: "4.4", PostgresVersion: "16"},
+		Credentials: database.Credentials{AdminUser: "admin", AdminPassword: "pw"},
+	}
+	dest := filepath.Join(dir, "acme.silohound")
+	err := Write(dest, m, map[string]string{
+		DATA_FILE:                    data,
+		REPORTS_DIR + "/report.html": report,
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return dest, m
+}
+
+func TestRoundTrip(t *testing.T) {
+	src, _ := writeTestBundle(t)
+
+	if info, err := os.Stat(src); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("bundle mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	m, err := ReadManifest(src)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if m.Project.Name != "Acme" || m.Credentials.AdminPassword != "pw" || len(m.Files) != 2 {
+		t.Errorf("manifest mismatch: %+v", m)
+	}
+	if p := m.Project; p.Neo4jPlugins != "apoc" || p.CPULimit != 2 || p.Neo4jMemory != "8g" || p.Client != "Acme Corp" || p.EngagementStart != "2025-07-01" || p.Domains != "acme.local" || p.Status != "reporting" {
+		t.Errorf("project details mismatch: %+v", p)
+	}
+
+	out := t.TempDir()
+	if _, err := Extract(src, out); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, REPORTS_DIR, "report.html"))
+	if err != nil || string(got) != "<html></html>" {
+		t.Errorf("report = %q, %v", got, err)
+	}
+}
+
+// rewrite copies a bundle, letting edit change the manifest and file contents.
+func rewrite(t *testing.T, src string, edit func(m *Manifest, files map[string][]byte)) string {
+	t.Helper()
+	f, _ := os.Open(src)
+	defer f.Close()
+	gz, _ := gzip.NewReader(f)
+	tr := tar.NewReader(gz)
+	m, err := readManifest(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		b := make([]byte, hdr.Size)
+		tr.Read(b)
+		files[hdr.Name] = b
+	}
+	edit(m, files)
+
+	dest := filepath.Join(t.TempDir(), "edited.silohound")
+	out, _ := os.Create(dest)
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	b, _ := json.Marshal(m)
+	tw.WriteHeader(&tar.Header{Name: MANIFEST_NAME, Mode: 0600, Size: int64(len(b))})
+	tw.Write(b)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})
+		tw.Write(content)
+	}
+	tw.Close()
+	gw.Close()
+	return dest
+}
+
+func TestExtract_Tampered(t *testing.T) {
+	src, _ := writeTestBundle(t)
+	bad := rewrite(t, src, func(m *Manifest, files map[string][]byte) {
+		files[DATA_FILE] = []byte("pretend archivX")
+	})
+	if _, err := Extract(bad, t.TempDir()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Extract error = %v, want checksum mismatch", err)
+	}
+}
+
+func TestExtract_PathTraversal(t *testing.T) {
+	src, _ := writeTestBundle(t)
+	bad := rewrite(t, src, func(m *Manifest, files map[string][]byte) {
+		m.Files[0].Name = "../escape"
+		files["../escape"] = files[DATA_FILE]
+	})
+	out := filepath.Join(t.TempDir(), "out")
+	if _, err := Extract(bad, out); err == nil {
+		t.Error("Extract accepted a path outside the destination")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(out), "escape")); err == nil {
+		t.Error("file written outside the destination")
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	m := &Manifest{Images: Images{PostgresVersion: "16", Neo4jVersion: "4.4"}}
+	if err := CheckCompatible(m, "16", "4.4"); err != nil {
+		t.Errorf("same versions rejected: %v", err)
+	}
+	if err := CheckCompatible(m, "16.4", "4.3"); err != nil {
+		t.Errorf("same major versions rejected: %v", err)
+	}
+	if err := CheckCompatible(m, "17", "4.4"); err == nil {
+		t.Error("different Postgres major accepted")
+	}
+	if err := CheckCompatible(m, "16", "5"); err == nil {
+		t.Error("different Neo4j major accepted")
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+)
+
+// Crash is an unexpected container exit seen by the supervisor. LogFile is
+// relative to the project path; Restarted is false if the supervisor gave up
+// or the restart failed, with the reason in Error.
+type Crash struct {
+	ID        int
+	Project   string
+	Role      string
+	Container string
+	ExitCode  int
+	OOM       bool
+	LogFile   string
+	Attempts  int
+	Restarted bool
+	Error     string
+	CrashedAt time.Time
+}
+
+const crashColumns = "id, project, role, container, exit_code, oom, log_file, attempts, restarted, error, crashed_at"
+
+// AddCrash records a crash and returns its ID.
+func (d *Database) AddCrash(c Crash) (int, error) {
+	res, err := d.db.Exec("INSERT INTO crashes (project, role, container, exit_code, oom, log_file, attempts, restarted, error, crashed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		c.Project, c.Role, c.Container, c.ExitCode, c.OOM, c.LogFile, c.Attempts, c.Restarted, c.Error, c.CrashedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// ListCrashes returns a project's crash history, oldest first.
+func (d *Database) ListCrashes(project string) ([]Crash, error) {
+	rows, err := d.db.Query("SELECT "+crashColumns+" FROM crashes WHERE project = ? ORDER BY crashed_at, id", project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crashes []Crash
+	for rows.Next() {
+		var c Crash
+		if err := rows.Scan(&c.ID, &c.Project, &c.Role, &c.Container, &c.ExitCode, &c.OOM, &c.LogFile, &c.Attempts, &c.Restarted, &c.Error, &c.CrashedAt); err != nil {
+			return nil, err
+		}
+		crashes = append(crashes, c)
+	}
+	return crashes, rows.Err()
+}
@@ -2,22 +2,167 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Mortimus/SiloHound/internal/secrets"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Project struct {
-	ID        int
-	Name      string
-	Path      string
-	CreatedAt time.Time
+	ID            int
+	Name          string
+	Path          string
+	CreatedAt     time.Time
+	BHPort        int
+	Neo4jHTTPPort int
+	Neo4jBoltPort int
+	BHImage       string
+	Neo4jImage    string
+	PostgresImage string
+	Neo4jHeap     string
+	// Sizing the project was last started with; empty or zero means the
+	// default, or no limit
+	Neo4jPageCache string
+	CPULimit       float64
+	PostgresMemory string
+	Neo4jMemory    string
+	BHMemory       string
+	Storage        string // bind or volume
+	// TLS endpoint for the BloodHound UI; TLSPort is 0 until one was set up
+	TLSBind  string
+	TLSPort  int
+	TLSAllow string // Comma-separated client IPs and CIDR ranges
+	// Neo4jVersion is the Neo4j major version the graph store is on
+	Neo4jVersion string
+	Neo4jPlugins string // Comma-separated, empty for none
+	Tags         string // Comma-separated, lower case
+	// SSHHost is the host whose Docker engine runs the project, reached
+	// over SSH; empty for the local engine
+	SSHHost string
+	// Engagement details, see Metadata
+	Client          string
+	EngagementStart string
+	EngagementEnd   string
+	Domains         string
+	Notes           string
+	Status          string
+}
+
+// Lifecycle states of an engagement. Projects start out active.
+const (
+	STATUS_ACTIVE    = "active"
+	STATUS_PAUSED    = "paused"
+	STATUS_REPORTING = "reporting"
+	STATUS_ARCHIVED  = "archived"
+)
+
+// Statuses lists the lifecycle states in order.
+var Statuses = []string{STATUS_ACTIVE, STATUS_PAUSED, STATUS_REPORTING, STATUS_ARCHIVED}
+
+// Metadata is what the team records about the engagement behind a project.
+type Metadata struct {
+	Client string
+	// Engagement window as YYYY-MM-DD; empty when not set
+	Start   string
+	End     string
+	Tags    string // Comma-separated, lower case
+	Domains string // Comma-separated domains in scope, lower case
+	Notes   string
+	Status  string // See STATUS_ACTIVE
+}
+
+// Resources is the stored sizing of a project's containers.
+type Resources struct {
+	Neo4jHeap      string
+	Neo4jPageCache string
+	CPULimit       float64 // CPUs per container
+	PostgresMemory string  // Container memory limits
+	Neo4jMemory    string
+	BHMemory       string
+}
+
+func (p *Project) Resources() Resources {
+	return Resources{
+		Neo4jHeap:      p.Neo4jHeap,
+		Neo4jPageCache: p.Neo4jPageCache,
+		CPULimit:       p.CPULimit,
+		PostgresMemory: p.PostgresMemory,
+		Neo4jMemory:    p.Neo4jMemory,
+		BHMemory:       p.BHMemory,
+	}
+}
+
+func (p *Project) Metadata() Metadata {
+	return Metadata{
+		Client:  p.Client,
+		Start:   p.EngagementStart,
+		End:     p.EngagementEnd,
+		Tags:    p.Tags,
+		Domains: p.Domains,
+		Notes:   p.Notes,
+		Status:  p.Status,
+	}
+}
+
+// HasTag reports whether the project carries tag, ignoring case.
+func (p *Project) HasTag(tag string) bool {
+	for _, t := range strings.Split(p.Tags, ",") {
+		if t != "" && strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials are the per-project service passwords. They are stored
+// encrypted in the projects table.
+type Credentials struct {
+	PostgresPassword string `json:"postgres_password"`
+	Neo4jPassword    string `json:"neo4j_password"`
+	AdminUser        string `json:"admin_user"`
+	AdminPassword    string `json:"admin_password"`
+}
+
+// LegacyCredentials returns the hardcoded passwords used before per-project
+// credentials existed. Data directories initialized by older versions still
+// expect them until they are rotated.
+func LegacyCredentials() Credentials {
+	return Credentials{
+		PostgresPassword: "bloodhoundcommunityedition",
+		Neo4jPassword:    "bloodhoundcommunityedition",
+		AdminUser:        "admin",
+		AdminPassword:    "admin",
+	}
+}
+
+// GenerateCredentials creates a fresh set of random credentials.
+func GenerateCredentials() (Credentials, error) {
+	var c Credentials
+	var err error
+	c.AdminUser = "admin"
+	if c.PostgresPassword, err = secrets.GeneratePassword(32, false); err != nil {
+		return c, err
+	}
+	if c.Neo4jPassword, err = secrets.GeneratePassword(32, false); err != nil {
+		return c, err
+	}
+	if c.AdminPassword, err = secrets.GeneratePassword(24, true); err != nil {
+		return c, err
+	}
+	return c, nil
 }
 
 type Database struct {
-	db *sql.DB
+	db  *sql.DB
+	key []byte
+	// Migration describes the schema upgrade done when the database was
+	// opened, if any.
+	Migration MigrationResult
 }
 
 func InitDB() (*Database, error) {
@@ -31,29 +176,23 @@ func InitDB() (*Database, error) {
 		return nil, err
 	}
 
+	key, err := secrets.LoadOrCreateKey(filepath.Join(filepath.Dir(dbPath), "secret.key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credential key: %w", err)
+	}
+
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := migrate(db); err != nil {
+	result, err := migrate(db, dbPath)
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &Database{db: db}, nil
-}
-
-func migrate(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS projects (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		path TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(query)
-	return err
+	return &Database{db: db, key: key, Migration: result}, nil
 }
 
 func (d *Database) Close() error {
@@ -66,20 +205,19 @@ func (d *Database) AddProject(name, path string) error {
 }
 
 func (d *Database) GetProject(name string) (*Project, error) {
-	row := d.db.QueryRow("SELECT id, name, path, created_at FROM projects WHERE name = ?", name)
-	var p Project
-	err := row.Scan(&p.ID, &p.Name, &p.Path, &p.CreatedAt)
+	row := d.db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE name = ?", name)
+	p, err := scanProject(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &p, nil
+	return p, nil
 }
 
 func (d *Database) ListProjects() ([]Project, error) {
-	rows, err := d.db.Query("SELECT id, name, path, created_at FROM projects")
+	rows, err := d.db.Query("SELECT " + projectColumns + " FROM projects")
 	if err != nil {
 		return nil, err
 	}
@@ -87,16 +225,39 @@ func (d *Database) ListProjects() ([]Project, error) {
 
 	var projects []Project
 	for rows.Next() {
-		var p Project
-		if err := rows.Scan(&p.ID, &p.Name, &p.Path, &p.CreatedAt); err != nil {
+		p, err := scanProject(rows)
+		if err != nil {
 			return nil, err
 		}
-		projects = append(projects, p)
+		projects = append(projects, *p)
 	}
 	return projects, nil
 }
 
+const projectColumns = "id, name, path, created_at, bh_port, neo4j_http_port, neo4j_bolt_port, bh_image, neo4j_image, psql_image, neo4j_heap, neo4j_pagecache, cpu_limit, psql_memory, neo4j_memory, bh_memory, storage, tls_bind, tls_port, tls_allow, neo4j_version, neo4j_plugins, tags, ssh_host, client, engagement_start, engagement_end, domains, notes, status"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProject(row rowScanner) (*Project, error) {
+	var p Project
+	err := row.Scan(&p.ID, &p.Name, &p.Path, &p.CreatedAt, &p.BHPort, &p.Neo4jHTTPPort, &p.Neo4jBoltPort, &p.BHImage, &p.Neo4jImage, &p.PostgresImage, &p.Neo4jHeap,
+		&p.Neo4jPageCache, &p.CPULimit, &p.PostgresMemory, &p.Neo4jMemory, &p.BHMemory, &p.Storage,
+		&p.TLSBind, &p.TLSPort, &p.TLSAllow, &p.Neo4jVersion, &p.Neo4jPlugins, &p.Tags, &p.SSHHost,
+		&p.Client, &p.EngagementStart, &p.EngagementEnd, &p.Domains, &p.Notes, &p.Status)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 func (d *Database) DeleteProject(name string) error {
+	for _, table := range []string{"snapshots", "crashes"} {
+		if _, err := d.db.Exec("DELETE FROM "+table+" WHERE project = ?", name); err != nil {
+			return err
+		}
+	}
 	_, err := d.db.Exec("DELETE FROM projects WHERE name = ?", name)
 	return err
 }
@@ -105,3 +266,110 @@ func (d *Database) UpdateProjectPath(name, newPath string) error {
 	_, err := d.db.Exec("UPDATE projects SET path = ? WHERE name = ?", newPath, name)
 	return err
 }
+
+func (d *Database) UpdateProjectPorts(name string, bhPort, neo4jHTTPPort, neo4jBoltPort int) error {
+	_, err := d.db.Exec("UPDATE projects SET bh_port = ?, neo4j_http_port = ?, neo4j_bolt_port = ? WHERE name = ?", bhPort, neo4jHTTPPort, neo4jBoltPort, name)
+	return err
+}
+
+// UpdateProjectResources records the heap, page cache and container limits
+// the project was last started with, so a resume uses the same sizing.
+func (d *Database) UpdateProjectResources(name string, r Resources) error {
+	_, err := d.db.Exec("UPDATE projects SET neo4j_heap = ?, neo4j_pagecache = ?, cpu_limit = ?, psql_memory = ?, neo4j_memory = ?, bh_memory = ? WHERE name = ?",
+		r.Neo4jHeap, r.Neo4jPageCache, r.CPULimit, r.PostgresMemory, r.Neo4jMemory, r.BHMemory, name)
+	return err
+}
+
+// UpdateProjectTLS records the project's TLS endpoint settings.
+func (d *Database) UpdateProjectTLS(name, bind string, port int, allow string) error {
+	_, err := d.db.Exec("UPDATE projects SET tls_bind = ?, tls_port = ?, tls_allow = ? WHERE name = ?", bind, port, allow, name)
+	return err
+}
+
+// UpdateProjectStorage records where the project's data lives.
+func (d *Database) UpdateProjectStorage(name, storage string) error {
+	_, err := d.db.Exec("UPDATE projects SET storage = ? WHERE name = ?", storage, name)
+	return err
+}
+
+// UpdateProjectNeo4jVersion records the Neo4j major version of the project's
+// graph store.
+func (d *Database) UpdateProjectNeo4jVersion(name, version string) error {
+	_, err := d.db.Exec("UPDATE projects SET neo4j_version = ? WHERE name = ?", version, name)
+	return err
+}
+
+// UpdateProjectNeo4jPlugins records the Neo4j plugins the project enables.
+func (d *Database) UpdateProjectNeo4jPlugins(name, plugins string) error {
+	_, err := d.db.Exec("UPDATE projects SET neo4j_plugins = ? WHERE name = ?", plugins, name)
+	return err
+}
+
+// UpdateProjectTags replaces the project's tags.
+func (d *Database) UpdateProjectTags(name, tags string) error {
+	_, err := d.db.Exec("UPDATE projects SET tags = ? WHERE name = ?", tags, name)
+	return err
+}
+
+// UpdateProjectMetadata replaces the project's engagement details.
+func (d *Database) UpdateProjectMetadata(name string, m Metadata) error {
+	_, err := d.db.Exec("UPDATE projects SET client = ?, engagement_start = ?, engagement_end = ?, tags = ?, domains = ?, notes = ?, status = ? WHERE name = ?",
+		m.Client, m.Start, m.End, m.Tags, m.Domains, m.Notes, m.Status, name)
+	return err
+}
+
+// UpdateProjectSSHHost records the remote host the project runs on.
+func (d *Database) UpdateProjectSSHHost(name, host string) error {
+	_, err := d.db.Exec("UPDATE projects SET ssh_host = ? WHERE name = ?", host, name)
+	return err
+}
+
+// UpdateProjectImages pins the image references a project starts from.
+func (d *Database) UpdateProjectImages(name, bhImage, neo4jImage, psqlImage string) error {
+	_, err := d.db.Exec("UPDATE projects SET bh_image = ?, neo4j_image = ?, psql_image = ? WHERE name = ?", bhImage, neo4jImage, psqlImage, name)
+	return err
+}
+
+// SetCredentials encrypts and stores the credentials for a project.
+func (d *Database) SetCredentials(name string, c Credentials) error {
+	sealed, err := d.sealCredentials(c)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec("UPDATE projects SET credentials = ? WHERE name = ?", sealed, name)
+	return err
+}
+
+// GetCredentials returns the decrypted credentials for a project, or nil if
+// none have been stored yet.
+func (d *Database) GetCredentials(name string) (*Credentials, error) {
+	var sealed []byte
+	err := d.db.QueryRow("SELECT credentials FROM projects WHERE name = ?", name).Scan(&sealed)
+	if err == sql.ErrNoRows || (err == nil && len(sealed) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.openCredentials(sealed)
+}
+
+func (d *Database) sealCredentials(c Credentials) ([]byte, error) {
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Encrypt(d.key, plain)
+}
+
+func (d *Database) openCredentials(sealed []byte) (*Credentials, error) {
+	plain, err := secrets.Decrypt(d.key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var c Credentials
+	if err := json.Unmarshal(plain, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -1,8 +1,12 @@
 package database
 
 import (
+	"database/sql"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDatabase_Projects(t *testing.T) {
@@ -62,6 +66,140 @@ func TestDatabase_Projects(t *testing.T) {
 		t.Errorf("Path not updated, got %s", p.Path)
 	}
 
+	// Test Ports
+	err = db.UpdateProjectPorts("TestProj", 18181, 17474, 17687)
+	if err != nil {
+		t.Errorf("UpdateProjectPorts failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.BHPort != 18181 || p.Neo4jHTTPPort != 17474 || p.Neo4jBoltPort != 17687 {
+		t.Errorf("Ports not updated, got %+v", p)
+	}
+
+	// Test Images
+	err = db.UpdateProjectImages("TestProj", "bh@sha256:1", "neo4j@sha256:2", "postgres@sha256:3")
+	if err != nil {
+		t.Errorf("UpdateProjectImages failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.BHImage != "bh@sha256:1" || p.Neo4jImage != "neo4j@sha256:2" || p.PostgresImage != "postgres@sha256:3" {
+		t.Errorf("Images not updated, got %+v", p)
+	}
+
+	// Test Resources
+	res := Resources{Neo4jHeap: "6G", Neo4jPageCache: "3G", CPULimit: 2.5, PostgresMemory: "1G", Neo4jMemory: "10G", BHMemory: "2G"}
+	if err := db.UpdateProjectResources("TestProj", res); err != nil {
+		t.Errorf("UpdateProjectResources failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Resources() != res {
+		t.Errorf("Resources not updated, got %+v", p.Resources())
+	}
+
+	// Test Storage
+	if p.Storage != "bind" {
+		t.Errorf("Expected default bind storage, got %q", p.Storage)
+	}
+	if err := db.UpdateProjectStorage("TestProj", "volume"); err != nil {
+		t.Errorf("UpdateProjectStorage failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Storage != "volume" {
+		t.Errorf("Storage not updated, got %q", p.Storage)
+	}
+
+	// Test TLS settings
+	if err := db.UpdateProjectTLS("TestProj", "0.0.0.0", 8443, "10.0.0.0/24"); err != nil {
+		t.Errorf("UpdateProjectTLS failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.TLSBind != "0.0.0.0" || p.TLSPort != 8443 || p.TLSAllow != "10.0.0.0/24" {
+		t.Errorf("TLS settings not updated: %q %d %q", p.TLSBind, p.TLSPort, p.TLSAllow)
+	}
+
+	// Test Neo4j version
+	if p.Neo4jVersion != "4" {
+		t.Errorf("Expected default Neo4j version 4, got %q", p.Neo4jVersion)
+	}
+	if err := db.UpdateProjectNeo4jVersion("TestProj", "5"); err != nil {
+		t.Errorf("UpdateProjectNeo4jVersion failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Neo4jVersion != "5" {
+		t.Errorf("Neo4j version not updated, got %q", p.Neo4jVersion)
+	}
+
+	// Test Neo4j plugins
+	if p.Neo4jPlugins != "apoc" {
+		t.Errorf("Expected default Neo4j plugins apoc, got %q", p.Neo4jPlugins)
+	}
+	if err := db.UpdateProjectNeo4jPlugins("TestProj", "apoc,gds"); err != nil {
+		t.Errorf("UpdateProjectNeo4jPlugins failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Neo4jPlugins != "apoc,gds" {
+		t.Errorf("Neo4j plugins not updated, got %q", p.Neo4jPlugins)
+	}
+
+	// Test tags
+	if err := db.UpdateProjectTags("TestProj", "q3,acme"); err != nil {
+		t.Errorf("UpdateProjectTags failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Tags != "q3,acme" || !p.HasTag("ACME") || p.HasTag("q") {
+		t.Errorf("Tags not updated or matched wrongly, got %q", p.Tags)
+	}
+
+	// Test SSH host
+	if err := db.UpdateProjectSSHHost("TestProj", "op@lab"); err != nil {
+		t.Errorf("UpdateProjectSSHHost failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.SSHHost != "op@lab" {
+		t.Errorf("SSH host not updated, got %q", p.SSHHost)
+	}
+
+	// Test Metadata
+	if p.Status != STATUS_ACTIVE {
+		t.Errorf("Expected new projects to be active, got %q", p.Status)
+	}
+	meta := Metadata{Client: "Acme Corp", Start: "2025-07-01", End: "2025-07-31", Tags: "q3", Domains: "acme.local,corp.acme.com", Notes: "VPN via jump01", Status: STATUS_REPORTING}
+	if err := db.UpdateProjectMetadata("TestProj", meta); err != nil {
+		t.Errorf("UpdateProjectMetadata failed: %v", err)
+	}
+	p, _ = db.GetProject("TestProj")
+	if p.Metadata() != meta {
+		t.Errorf("Metadata not updated, got %+v", p.Metadata())
+	}
+
+	// Test Credentials
+	c, err := db.GetCredentials("TestProj")
+	if err != nil {
+		t.Errorf("GetCredentials failed: %v", err)
+	}
+	if c != nil {
+		t.Errorf("Expected no credentials yet, got %+v", c)
+	}
+	creds, err := GenerateCredentials()
+	if err != nil {
+		t.Fatalf("GenerateCredentials failed: %v", err)
+	}
+	if err := db.SetCredentials("TestProj", creds); err != nil {
+		t.Errorf("SetCredentials failed: %v", err)
+	}
+	c, err = db.GetCredentials("TestProj")
+	if err != nil {
+		t.Errorf("GetCredentials failed: %v", err)
+	}
+	if c == nil || *c != creds {
+		t.Errorf("Credentials mismatch: got %+v, want %+v", c, creds)
+	}
+	var raw []byte
+	db.db.QueryRow("SELECT credentials FROM projects WHERE name = ?", "TestProj").Scan(&raw)
+	if strings.Contains(string(raw), creds.AdminPassword) {
+		t.Errorf("Credentials stored in plaintext")
+	}
+
 	// Test List
 	list, err := db.ListProjects()
 	if err != nil {
@@ -81,3 +219,294 @@ func TestDatabase_Projects(t *testing.T) {
 		t.Errorf("Project should be nil after delete, got %+v", p)
 	}
 }
+
+func TestDatabase_MigrateLegacySchema(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "silohound_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	// Create a database the way the first release did
+	dbPath := filepath.Join(tmpDir, ".silohound", "projects.db")
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE projects (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		path TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO projects (name, path) VALUES ('Old', '/tmp/old');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB failed on legacy schema: %v", err)
+	}
+	defer db.Close()
+
+	p, err := db.GetProject("Old")
+	if err != nil {
+		t.Fatalf("GetProject failed: %v", err)
+	}
+	if p == nil || p.Path != "/tmp/old" {
+		t.Fatalf("Legacy project not readable: %+v", p)
+	}
+	if p.BHPort != 0 {
+		t.Errorf("Expected unassigned port, got %d", p.BHPort)
+	}
+	if p.Status != STATUS_ACTIVE || p.Client != "" {
+		t.Errorf("Expected empty metadata for a legacy project, got %+v", p.Metadata())
+	}
+
+	// The unversioned database is backed up before its first migration
+	m := db.Migration
+	if m.From != 0 || m.To != SchemaVersion() || m.Backup == "" {
+		t.Fatalf("Unexpected migration result: %+v", m)
+	}
+	backup, err := sql.Open("sqlite3", m.Backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	var path string
+	if err := backup.QueryRow("SELECT path FROM projects WHERE name = 'Old'").Scan(&path); err != nil || path != "/tmp/old" {
+		t.Errorf("Backup lacks the legacy project: %q, %v", path, err)
+	}
+}
+
+func TestDatabase_SchemaVersions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "silohound_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	// A new database is created at the latest version without a backup
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	if m := db.Migration; m.From != 0 || m.To != SchemaVersion() || m.Backup != "" {
+		t.Errorf("Unexpected migration result for a new database: %+v", m)
+	}
+	if err := db.AddProject("Keep", "/tmp/keep"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopening at the same version migrates nothing
+	if db, err = InitDB(); err != nil {
+		t.Fatalf("InitDB failed on reopen: %v", err)
+	}
+	if db.Migration.Migrated() {
+		t.Errorf("Reopen migrated again: %+v", db.Migration)
+	}
+	db.Close()
+
+	// A failing migration is rolled back and leaves the version alone
+	latest := SchemaVersion()
+	orig := migrations
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{latest + 1, "broken", func(tx *sql.Tx) error {
+		if _, err := tx.Exec("ALTER TABLE projects ADD COLUMN half_done TEXT"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("SELECT * FROM no_such_table")
+		return err
+	}})
+	_, err = InitDB()
+	migrations = orig
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("Expected the broken migration to fail, got %v", err)
+	}
+	if db, err = InitDB(); err != nil {
+		t.Fatalf("InitDB failed after a failed migration: %v", err)
+	}
+	if v, err := schemaVersion(db.db); err != nil || v != latest {
+		t.Errorf("Schema version = %d, %v; want %d", v, err, latest)
+	}
+	if _, err := db.db.Exec("SELECT half_done FROM projects"); err == nil {
+		t.Error("Failed migration was not rolled back")
+	}
+	backups, _ := filepath.Glob(filepath.Join(tmpDir, ".silohound", "backups", "*.db"))
+	if len(backups) != 1 {
+		t.Errorf("Expected a backup before the failed migration, got %v", backups)
+	}
+
+	// A database from a newer build is refused
+	if _, err := db.db.Exec("INSERT INTO schema_version (version, name) VALUES (?, 'future')", latest+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := InitDB(); err == nil || !strings.Contains(err.Error(), "upgrade SiloHound") {
+		t.Errorf("Expected a newer schema to be refused, got %v", err)
+	}
+}
+
+func TestDatabase_Snapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddProject("Snap", "/tmp/snap"); err != nil {
+		t.Fatal(err)
+	}
+	creds := &Credentials{PostgresPassword: "pg", Neo4jPassword: "neo", AdminUser: "admin", AdminPassword: "bh"}
+	first := Snapshot{Project: "Snap", Label: "before-import", File: "snapshots/a.tar.gz", Size: 42, SHA256: "abc", CreatedAt: time.Now().Add(-time.Hour), Credentials: creds}
+	id, err := db.AddSnapshot(first)
+	if err != nil {
+		t.Fatalf("AddSnapshot failed: %v", err)
+	}
+	if _, err := db.AddSnapshot(Snapshot{Project: "Snap", Label: "later", File: "snapshots/b.tar.gz", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("AddSnapshot failed: %v", err)
+	}
+
+	got, err := db.GetSnapshot(id)
+	if err != nil || got == nil {
+		t.Fatalf("GetSnapshot = %+v, %v", got, err)
+	}
+	if got.Label != first.Label || got.Size != 42 || got.SHA256 != "abc" || got.File != first.File {
+		t.Errorf("snapshot round trip mismatch: %+v", got)
+	}
+	if got.Credentials == nil || *got.Credentials != *creds {
+		t.Errorf("snapshot credentials = %+v, want %+v", got.Credentials, creds)
+	}
+
+	snaps, err := db.ListSnapshots("Snap")
+	if err != nil || len(snaps) != 2 || snaps[0].ID != id {
+		t.Fatalf("ListSnapshots = %+v, %v; want 2, oldest first", snaps, err)
+	}
+	if snaps[1].Credentials != nil {
+		t.Errorf("snapshot without credentials got %+v", snaps[1].Credentials)
+	}
+
+	if err := db.DeleteSnapshot(id); err != nil {
+		t.Fatalf("DeleteSnapshot failed: %v", err)
+	}
+	if s, _ := db.GetSnapshot(id); s != nil {
+		t.Errorf("snapshot still present after delete: %+v", s)
+	}
+
+	// Deleting the project drops its remaining snapshots
+	if err := db.DeleteProject("Snap"); err != nil {
+		t.Fatal(err)
+	}
+	if snaps, _ := db.ListSnapshots("Snap"); len(snaps) != 0 {
+		t.Errorf("snapshots left after project delete: %+v", snaps)
+	}
+}
+
+func TestDatabase_Crashes(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddProject("Crashy", "/tmp/crashy"); err != nil {
+		t.Fatal(err)
+	}
+	oom := Crash{Project: "Crashy", Role: "neo4j", Container: "SiloHound_Crashy_Neo4j", ExitCode: 137, OOM: true, LogFile: "crashes/neo4j.log", Attempts: 1, Restarted: true, CrashedAt: time.Now().Add(-time.Minute)}
+	if _, err := db.AddCrash(oom); err != nil {
+		t.Fatalf("AddCrash failed: %v", err)
+	}
+	if _, err := db.AddCrash(Crash{Project: "Crashy", Role: "bh", Container: "SiloHound_Crashy_BH", ExitCode: 1, Attempts: 5, Error: "gave up", CrashedAt: time.Now()}); err != nil {
+		t.Fatalf("AddCrash failed: %v", err)
+	}
+
+	crashes, err := db.ListCrashes("Crashy")
+	if err != nil || len(crashes) != 2 {
+		t.Fatalf("ListCrashes = %+v, %v; want 2", crashes, err)
+	}
+	if got := crashes[0]; got.Role != "neo4j" || !got.OOM || !got.Restarted || got.ExitCode != 137 || got.LogFile != oom.LogFile {
+		t.Errorf("first crash = %+v, want the OOM", got)
+	}
+	if got := crashes[1]; got.Restarted || got.Error != "gave up" || got.Attempts != 5 {
+		t.Errorf("second crash = %+v", got)
+	}
+
+	if err := db.DeleteProject("Crashy"); err != nil {
+		t.Fatal(err)
+	}
+	if crashes, _ := db.ListCrashes("Crashy"); len(crashes) != 0 {
+		t.Errorf("crashes left after project delete: %+v", crashes)
+	}
+}
+
+func TestDatabase_Events(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddProject("Audited", "/tmp/audited"); err != nil {
+		t.Fatal(err)
+	}
+	start := Event{Project: "Audited", Operation: "start", User: "alice", Args: "-name Audited", Outcome: OUTCOME_OK, Duration: 42 * time.Second, StartedAt: time.Now().Add(-time.Hour)}
+	if _, err := db.AddEvent(start); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if _, err := db.AddEvent(Event{Project: "Audited", Operation: "audit", User: "bob", Outcome: OUTCOME_FAILED, Error: "no such file", StartedAt: time.Now()}); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if _, err := db.AddEvent(Event{Project: "Other", Operation: "stop", User: "alice", Outcome: OUTCOME_OK, StartedAt: time.Now()}); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+
+	events, err := db.ListEvents("Audited")
+	if err != nil || len(events) != 2 {
+		t.Fatalf("ListEvents = %+v, %v; want 2", events, err)
+	}
+	if e := events[0]; e.Operation != "start" || e.User != "alice" || e.Duration != 42*time.Second || e.Args != "-name Audited" {
+		t.Errorf("Unexpected first event: %+v", e)
+	}
+	if e := events[1]; e.Outcome != OUTCOME_FAILED || e.Error != "no such file" {
+		t.Errorf("Unexpected second event: %+v", e)
+	}
+	if all, _ := db.ListEvents(""); len(all) != 3 {
+		t.Errorf("Expected 3 events across projects, got %d", len(all))
+	}
+
+	// The history is append-only and outlives the project
+	if _, err := db.db.Exec("UPDATE events SET outcome = 'ok'"); err == nil {
+		t.Error("Events could be updated")
+	}
+	if _, err := db.db.Exec("DELETE FROM events"); err == nil {
+		t.Error("Events could be deleted")
+	}
+	if err := db.DeleteProject("Audited"); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := db.ListEvents("Audited"); len(events) != 2 {
+		t.Errorf("Expected the history to survive the project, got %d events", len(events))
+	}
+}
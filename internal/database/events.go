@@ -0,0 +1,67 @@
+package database
+
+import (
+	"time"
+)
+
+// Outcomes of a recorded operation.
+const (
+	OUTCOME_OK     = "ok"
+	OUTCOME_FAILED = "failed"
+)
+
+// Event is one operation in a project's history. Args are the command line
+// with secrets redacted; Error is set when the operation failed. Events
+// cannot be changed or deleted once added.
+type Event struct {
+	ID        int
+	Project   string
+	Operation string
+	User      string // OS user who ran the operation
+	Args      string
+	Outcome   string
+	Error     string
+	Duration  time.Duration
+	StartedAt time.Time
+}
+
+const eventColumns = "id, project, operation, os_user, args, outcome, error, duration_ms, started_at"
+
+// AddEvent appends an event to the history and returns its ID.
+func (d *Database) AddEvent(e Event) (int, error) {
+	res, err := d.db.Exec("INSERT INTO events (project, operation, os_user, args, outcome, error, duration_ms, started_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Project, e.Operation, e.User, e.Args, e.Outcome, e.Error, e.Duration.Milliseconds(), e.StartedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// ListEvents returns a project's history, or that of every project if
+// project is empty, oldest first.
+func (d *Database) ListEvents(project string) ([]Event, error) {
+	query := "SELECT " + eventColumns + " FROM events"
+	var args []any
+	if project != "" {
+		query += " WHERE project = ?"
+		args = append(args, project)
+	}
+	rows, err := d.db.Query(query+" ORDER BY started_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var ms int64
+		if err := rows.Scan(&e.ID, &e.Project, &e.Operation, &e.User, &e.Args, &e.Outcome, &e.Error, &ms, &e.StartedAt); err != nil {
+			return nil, err
+		}
+		e.Duration = time.Duration(ms) * time.Millisecond
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,304 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// migration upgrades the schema by one version. Migrations run in order,
+// each in its own transaction together with the bump of schema_version, so a
+// failed migration leaves the database at the previous version.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations lists every schema version in order. Append new migrations to
+// the end; never edit or reorder ones that have been released.
+var migrations = []migration{
+	{1, "projects, snapshots and crashes", migrateLegacy},
+	{2, "project metadata", migrateMetadata},
+	{3, "operation history", migrateEvents},
+	{4, "snapshot credentials", migrateSnapshotCredentials},
+}
+
+// SchemaVersion is the schema version this build creates and understands.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// MigrationResult describes a schema upgrade. Backup is the copy of the
+// database taken before migrating, empty when nothing had to be migrated or
+// the database was new.
+type MigrationResult struct {
+	From   int
+	To     int
+	Backup string
+}
+
+// Migrated reports whether the schema was upgraded.
+func (r MigrationResult) Migrated() bool {
+	return r.From != r.To
+}
+
+// migrate brings the database at dbPath up to SchemaVersion. A database that
+// already holds data is backed up first. A database written by a newer build
+// is refused rather than touched.
+func migrate(db *sql.DB, dbPath string) (MigrationResult, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	result := MigrationResult{From: current, To: current}
+	latest := SchemaVersion()
+	if current > latest {
+		return result, fmt.Errorf("database %s is at schema version %d, but this SiloHound only supports up to %d; upgrade SiloHound", dbPath, current, latest)
+	}
+	if current == latest {
+		return result, nil
+	}
+
+	empty, err := isEmpty(db)
+	if err != nil {
+		return result, err
+	}
+	if !empty {
+		if result.Backup, err = backupDB(db, dbPath, current); err != nil {
+			return result, fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			if result.Backup != "" {
+				err = fmt.Errorf("%w (backup at %s)", err, result.Backup)
+			}
+			return result, err
+		}
+		result.To = m.version
+	}
+	return result, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("schema migration %d (%s): %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var v sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&v); err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
+
+// isEmpty reports whether the database has no tables besides schema_version,
+// i.e. it was just created.
+func isEmpty(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')").Scan(&n)
+	return n == 0, err
+}
+
+// backupDB writes a consistent copy of the database to the backups folder
+// next to it and returns its path.
+func backupDB(db *sql.DB, dbPath string, version int) (string, error) {
+	dir := filepath.Join(filepath.Dir(dbPath), "backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, fmt.Sprintf("projects-v%d-%s.db", version, time.Now().Format("20060102-150405")))
+	if _, err := os.Stat(dest); err == nil {
+		return "", fmt.Errorf("%s already exists", dest)
+	}
+	if _, err := db.Exec("VACUUM INTO ?", dest); err != nil {
+		return "", err
+	}
+	return dest, os.Chmod(dest, 0600)
+}
+
+// migrateLegacy is the schema as it stood before versioning. Databases from
+// that time may be at any point of it, so every step is idempotent.
+func migrateLegacy(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS projects (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		path TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Columns added after the initial release. Older databases get them
+	// appended in place so existing projects keep working.
+	columns := []struct {
+		name string
+		def  string
+	}{
+		{"bh_port", "INTEGER NOT NULL DEFAULT 0"},
+		{"neo4j_http_port", "INTEGER NOT NULL DEFAULT 0"},
+		{"neo4j_bolt_port", "INTEGER NOT NULL DEFAULT 0"},
+		{"credentials", "BLOB"},
+		{"bh_image", "TEXT NOT NULL DEFAULT ''"},
+		{"neo4j_image", "TEXT NOT NULL DEFAULT ''"},
+		{"psql_image", "TEXT NOT NULL DEFAULT ''"},
+		{"neo4j_heap", "TEXT NOT NULL DEFAULT ''"},
+		{"neo4j_pagecache", "TEXT NOT NULL DEFAULT ''"},
+		{"cpu_limit", "REAL NOT NULL DEFAULT 0"},
+		{"psql_memory", "TEXT NOT NULL DEFAULT ''"},
+		{"neo4j_memory", "TEXT NOT NULL DEFAULT ''"},
+		{"bh_memory", "TEXT NOT NULL DEFAULT ''"},
+		{"storage", "TEXT NOT NULL DEFAULT 'bind'"},
+		{"tls_bind", "TEXT NOT NULL DEFAULT ''"},
+		{"tls_port", "INTEGER NOT NULL DEFAULT 0"},
+		{"tls_allow", "TEXT NOT NULL DEFAULT ''"},
+		{"neo4j_version", "TEXT NOT NULL DEFAULT '4'"},
+		{"neo4j_plugins", "TEXT NOT NULL DEFAULT 'apoc'"},
+		{"tags", "TEXT NOT NULL DEFAULT ''"},
+		{"ssh_host", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := ensureColumn(tx, "projects", c.name, c.def); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+	CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project TEXT NOT NULL,
+		label TEXT NOT NULL,
+		file TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS crashes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project TEXT NOT NULL,
+		role TEXT NOT NULL,
+		container TEXT NOT NULL,
+		exit_code INTEGER NOT NULL,
+		oom BOOLEAN NOT NULL DEFAULT 0,
+		log_file TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		restarted BOOLEAN NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		crashed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	return err
+}
+
+// migrateMetadata adds the engagement details of a project.
+func migrateMetadata(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE projects ADD COLUMN client TEXT NOT NULL DEFAULT '';
+	ALTER TABLE projects ADD COLUMN engagement_start TEXT NOT NULL DEFAULT '';
+	ALTER TABLE projects ADD COLUMN engagement_end TEXT NOT NULL DEFAULT '';
+	ALTER TABLE projects ADD COLUMN domains TEXT NOT NULL DEFAULT '';
+	ALTER TABLE projects ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+	ALTER TABLE projects ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+	`)
+	return err
+}
+
+// migrateEvents adds the operation history. Triggers keep it append-only;
+// events also outlive the project they belong to.
+func migrateEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		os_user TEXT NOT NULL,
+		args TEXT NOT NULL DEFAULT '',
+		outcome TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		started_at DATETIME NOT NULL
+	);
+	CREATE INDEX events_project ON events (project, started_at);
+	CREATE TRIGGER events_no_update BEFORE UPDATE ON events
+	BEGIN
+		SELECT RAISE(ABORT, 'events are append-only');
+	END;
+	CREATE TRIGGER events_no_delete BEFORE DELETE ON events
+	BEGIN
+		SELECT RAISE(ABORT, 'events are append-only');
+	END;
+	`)
+	return err
+}
+
+// migrateSnapshotCredentials stores the credentials a snapshot's data was
+// created with. Snapshots taken before have none.
+func migrateSnapshotCredentials(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE snapshots ADD COLUMN credentials BLOB")
+	return err
+}
+
+func ensureColumn(tx *sql.Tx, table, column, def string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+	return err
+}
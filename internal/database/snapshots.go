@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Snapshot is a point-in-time archive of a project's data directories. File
+// is relative to the project path so snapshots survive -move. Credentials
+// are the ones the archived databases accept; they are stored encrypted and
+// are nil for snapshots taken before they were recorded.
+type Snapshot struct {
+	ID          int
+	Project     string
+	Label       string
+	File        string
+	Size        int64
+	SHA256      string
+	CreatedAt   time.Time
+	Credentials *Credentials
+}
+
+const snapshotColumns = "id, project, label, file, size, sha256, created_at, credentials"
+
+func (d *Database) scanSnapshot(row rowScanner) (*Snapshot, error) {
+	var s Snapshot
+	var sealed []byte
+	if err := row.Scan(&s.ID, &s.Project, &s.Label, &s.File, &s.Size, &s.SHA256, &s.CreatedAt, &sealed); err != nil {
+		return nil, err
+	}
+	if len(sealed) > 0 {
+		c, err := d.openCredentials(sealed)
+		if err != nil {
+			return nil, err
+		}
+		s.Credentials = c
+	}
+	return &s, nil
+}
+
+// AddSnapshot records a snapshot and returns its ID.
+func (d *Database) AddSnapshot(s Snapshot) (int, error) {
+	var sealed []byte
+	if s.Credentials != nil {
+		var err error
+		if sealed, err = d.sealCredentials(*s.Credentials); err != nil {
+			return 0, err
+		}
+	}
+	res, err := d.db.Exec("INSERT INTO snapshots (project, label, file, size, sha256, created_at, credentials) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		s.Project, s.Label, s.File, s.Size, s.SHA256, s.CreatedAt, sealed)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetSnapshot returns the snapshot with the given ID, or nil if there is none.
+func (d *Database) GetSnapshot(id int) (*Snapshot, error) {
+	s, err := d.scanSnapshot(d.db.QueryRow("SELECT "+snapshotColumns+" FROM snapshots WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListSnapshots returns a project's snapshots, oldest first.
+func (d *Database) ListSnapshots(project string) ([]Snapshot, error) {
+	rows, err := d.db.Query("SELECT "+snapshotColumns+" FROM snapshots WHERE project = ? ORDER BY created_at, id", project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []Snapshot
+	for rows.Next() {
+		s, err := d.scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, *s)
+	}
+	return snaps, rows.Err()
+}
+
+func (d *Database) DeleteSnapshot(id int) error {
+	_, err := d.db.Exec("DELETE FROM snapshots WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,579 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRuntime is an in-memory Runtime for tests. Containers never run
+// anything: exec calls and toolbox commands are answered by ExecHandler, and
+// images are "pulled" by inventing a digest for the reference.
+type FakeRuntime struct {
+	// ExecHandler answers Exec calls and the command of containers waited on
+	// with ContainerWait. Without a handler every command succeeds silently.
+	ExecHandler func(c ContainerInfo, cmd []string) *ExecResult
+	// Engine is returned by Info, or InfoErr if that is set.
+	Engine  EngineInfo
+	InfoErr error
+
+	mu         sync.Mutex
+	nextID     int
+	containers map[string]*fakeContainer
+	networks   map[string]NetworkInfo
+	volumes    map[string]VolumeInfo
+	images     map[string]ImageInfo
+	pulls      []string
+	execs      []ExecCall
+	watchers   []fakeWatcher
+}
+
+// ExecCall is an Exec recorded by the FakeRuntime.
+type ExecCall struct {
+	ContainerID string
+	Cmd         []string
+	Env         []string
+}
+
+type fakeWatcher struct {
+	ctx    context.Context
+	labels map[string]string
+	events chan Event
+}
+
+type fakeContainer struct {
+	info ContainerInfo
+	spec ContainerSpec
+	logs string
+}
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		Engine:     EngineInfo{Name: "fake", Version: "1.0", APIVersion: "1.45", CPUs: 4, MemTotal: 8 << 30},
+		containers: make(map[string]*fakeContainer),
+		networks:   make(map[string]NetworkInfo),
+		volumes:    make(map[string]VolumeInfo),
+		images:     make(map[string]ImageInfo),
+	}
+}
+
+func (f *FakeRuntime) newID() string {
+	f.nextID++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("fake-%d", f.nextID)))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddImage makes ref available locally as if it had been pulled.
+func (f *FakeRuntime) AddImage(ref string) ImageInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addImage(ref)
+}
+
+func (f *FakeRuntime) addImage(ref string) ImageInfo {
+	if info, ok := f.images[ref]; ok {
+		return info
+	}
+	sum := sha256.Sum256([]byte(ref))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	info := ImageInfo{ID: digest, RepoTags: []string{ref}}
+	if !strings.Contains(ref, "@") {
+		info.RepoDigests = []string{imageRepository(ref) + "@" + digest}
+	}
+	f.images[ref] = info
+	return info
+}
+
+// AddContainer registers an existing container, e.g. one left behind by an
+// older SiloHound, and returns its ID.
+func (f *FakeRuntime) AddContainer(info ContainerInfo) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ID == "" {
+		info.ID = f.newID()
+	}
+	if info.Running {
+		info.State = "running"
+	} else if info.State == "" {
+		info.State = "exited"
+	}
+	f.containers[info.ID] = &fakeContainer{info: info}
+	return info.ID
+}
+
+// Containers returns a snapshot of all known containers.
+func (f *FakeRuntime) Containers() []ContainerInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []ContainerInfo
+	for _, c := range f.containers {
+		out = append(out, c.info)
+	}
+	return out
+}
+
+// Spec returns the spec a container was created from.
+func (f *FakeRuntime) Spec(id string) (ContainerSpec, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.containers[id]
+	if !ok {
+		return ContainerSpec{}, false
+	}
+	return c.spec, true
+}
+
+// SetLogs replaces the log output of a container.
+func (f *FakeRuntime) SetLogs(id, logs string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.containers[id]; ok {
+		c.logs = logs
+	}
+}
+
+// Pulls returns every reference passed to ImagePull, in order.
+func (f *FakeRuntime) Pulls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.pulls...)
+}
+
+// Execs returns every Exec call, in order.
+func (f *FakeRuntime) Execs() []ExecCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ExecCall(nil), f.execs...)
+}
+
+func (f *FakeRuntime) Close() error { return nil }
+
+func (f *FakeRuntime) Info(ctx context.Context) (EngineInfo, error) {
+	if f.InfoErr != nil {
+		return EngineInfo{}, f.InfoErr
+	}
+	return f.Engine, nil
+}
+
+func (f *FakeRuntime) NetworkList(ctx context.Context, labels map[string]string) ([]NetworkInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []NetworkInfo
+	for _, n := range f.networks {
+		if matchLabels(n.Labels, labels) {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeRuntime) NetworkCreate(ctx context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range f.networks {
+		if n.Name == name {
+			return fmt.Errorf("network %s already exists", name)
+		}
+	}
+	id := f.newID()
+	f.networks[id] = NetworkInfo{ID: id, Name: name, Labels: labels}
+	return nil
+}
+
+// AddNetwork registers an existing network and returns its ID.
+func (f *FakeRuntime) AddNetwork(name string, labels map[string]string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID()
+	f.networks[id] = NetworkInfo{ID: id, Name: name, Labels: labels}
+	return id
+}
+
+func (f *FakeRuntime) NetworkRemove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, n := range f.networks {
+		if n.ID == id || n.Name == id {
+			delete(f.networks, key)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: network %s", ErrNotFound, id)
+}
+
+func (f *FakeRuntime) VolumeList(ctx context.Context, labels map[string]string) ([]VolumeInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []VolumeInfo
+	for _, v := range f.volumes {
+		if matchLabels(v.Labels, labels) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeRuntime) VolumeCreate(ctx context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.volumes[name]; ok {
+		return fmt.Errorf("volume %s already exists", name)
+	}
+	f.volumes[name] = VolumeInfo{Name: name, Labels: labels}
+	return nil
+}
+
+func (f *FakeRuntime) VolumeRemove(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.volumes[name]; !ok {
+		return fmt.Errorf("%w: volume %s", ErrNotFound, name)
+	}
+	for _, c := range f.containers {
+		for _, m := range c.spec.Mounts {
+			if m.Volume && m.Source == name {
+				return fmt.Errorf("volume %s is in use by %s", name, c.info.Name)
+			}
+		}
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+func (f *FakeRuntime) ImagePull(ctx context.Context, ref string, progress io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pulls = append(f.pulls, ref)
+	f.addImage(ref)
+	fmt.Fprintf(progress, "Pulled %s\n", ref)
+	return nil
+}
+
+func (f *FakeRuntime) ImageInspect(ctx context.Context, ref string) (ImageInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lookupImage(ref)
+}
+
+func (f *FakeRuntime) lookupImage(ref string) (ImageInfo, error) {
+	if info, ok := f.images[ref]; ok {
+		return info, nil
+	}
+	for _, info := range f.images {
+		if info.ID == ref {
+			return info, nil
+		}
+		for _, d := range info.RepoDigests {
+			if d == ref {
+				return info, nil
+			}
+		}
+	}
+	return ImageInfo{}, fmt.Errorf("%w: image %s", ErrNotFound, ref)
+}
+
+func (f *FakeRuntime) ImageTag(ctx context.Context, source, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := f.lookupImage(source)
+	if err != nil {
+		return err
+	}
+	info.RepoTags = append(append([]string(nil), info.RepoTags...), target)
+	f.images[target] = info
+	return nil
+}
+
+// ImageSave writes the image metadata as JSON; ImageLoad reads it back.
+func (f *FakeRuntime) ImageSave(ctx context.Context, ref string, w io.Writer) error {
+	f.mu.Lock()
+	info, err := f.lookupImage(ref)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Ref  string
+		Info ImageInfo
+	}{ref, info})
+}
+
+func (f *FakeRuntime) ImageLoad(ctx context.Context, r io.Reader, progress io.Writer) error {
+	var saved struct {
+		Ref  string
+		Info ImageInfo
+	}
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[saved.Ref] = saved.Info
+	return nil
+}
+
+func (f *FakeRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	img, err := f.lookupImage(spec.Image)
+	if err != nil {
+		return "", err
+	}
+	if spec.Name != "" {
+		for _, c := range f.containers {
+			if c.info.Name == spec.Name {
+				return "", fmt.Errorf("container name %s is already in use", spec.Name)
+			}
+		}
+	}
+
+	id := f.newID()
+	f.containers[id] = &fakeContainer{
+		spec: spec,
+		info: ContainerInfo{
+			ID:      id,
+			Name:    spec.Name,
+			Image:   spec.Image,
+			ImageID: img.ID,
+			Labels:  spec.Labels,
+			State:   "created",
+			Created: time.Now(),
+			Ports:   spec.Ports,
+		},
+	}
+	return id, nil
+}
+
+func (f *FakeRuntime) container(id string) (*fakeContainer, error) {
+	if c, ok := f.containers[id]; ok {
+		return c, nil
+	}
+	for _, c := range f.containers {
+		if c.info.Name == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: container %s", ErrNotFound, id)
+}
+
+func (f *FakeRuntime) ContainerStart(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	c.info.Running = true
+	c.info.State = "running"
+	c.info.StartedAt = time.Now()
+	f.emit(c, EVENT_START)
+	return nil
+}
+
+func (f *FakeRuntime) ContainerStop(ctx context.Context, id string, timeout time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	f.emit(c, EVENT_KILL)
+	f.exit(c, 0)
+	f.emit(c, EVENT_STOP)
+	return nil
+}
+
+// Crash makes a running container exit on its own with code, as if its
+// process died (or was OOM-killed), without a stop request.
+func (f *FakeRuntime) Crash(id string, code int, oom bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	if oom {
+		f.emit(c, EVENT_OOM)
+	}
+	f.exit(c, code)
+	return nil
+}
+
+// emit sends a container event to every watcher whose labels match.
+func (f *FakeRuntime) emit(c *fakeContainer, action string) {
+	ev := Event{Action: action, ContainerID: c.info.ID, Name: c.info.Name, Labels: c.info.Labels, Time: time.Now()}
+	if action == EVENT_DIE {
+		ev.ExitCode = c.info.ExitCode
+	}
+	for _, w := range f.watchers {
+		if w.ctx.Err() == nil && matchLabels(c.info.Labels, w.labels) {
+			w.events <- ev
+		}
+	}
+}
+
+// exit marks a container as stopped, removing it if it was created with
+// AutoRemove.
+func (f *FakeRuntime) exit(c *fakeContainer, code int) {
+	c.info.Running = false
+	c.info.State = "exited"
+	c.info.ExitCode = code
+	f.emit(c, EVENT_DIE)
+	if c.spec.AutoRemove {
+		delete(f.containers, c.info.ID)
+	}
+}
+
+func (f *FakeRuntime) ContainerRemove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	delete(f.containers, c.info.ID)
+	return nil
+}
+
+func (f *FakeRuntime) ContainerList(ctx context.Context, all bool, labels map[string]string) ([]ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []ContainerInfo
+	for _, c := range f.containers {
+		if (all || c.info.Running) && matchLabels(c.info.Labels, labels) {
+			out = append(out, c.info)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeRuntime) ContainerListByName(ctx context.Context, all bool, names []string) ([]ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []ContainerInfo
+	for _, c := range f.containers {
+		if (all || c.info.Running) && slices.Contains(names, c.info.Name) {
+			out = append(out, c.info)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeRuntime) ContainerInspect(ctx context.Context, id string) (ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+	return c.info, nil
+}
+
+// ContainerWait "runs" the container's command through ExecHandler, records
+// its output as the container logs and stops the container.
+func (f *FakeRuntime) ContainerWait(ctx context.Context, id string) (int, error) {
+	f.mu.Lock()
+	c, err := f.container(id)
+	f.mu.Unlock()
+	if err != nil {
+		return -1, err
+	}
+
+	res := f.handle(c.info, c.spec.Cmd)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c.logs += res.Stdout + res.Stderr
+	f.exit(c, res.ExitCode)
+	return res.ExitCode, nil
+}
+
+func (f *FakeRuntime) ContainerLogs(ctx context.Context, id string, opts LogOptions, stdout, stderr io.Writer) error {
+	f.mu.Lock()
+	c, err := f.container(id)
+	var logs string
+	if err == nil {
+		logs = c.logs
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(stdout, logs); err != nil {
+		return err
+	}
+
+	// Following streams new output until the container stops
+	for opts.Follow {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+		f.mu.Lock()
+		running := c.info.Running
+		more := c.logs
+		if strings.HasPrefix(c.logs, logs) {
+			more = c.logs[len(logs):]
+		}
+		logs = c.logs
+		f.mu.Unlock()
+		if _, err := io.WriteString(stdout, more); err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Events delivers events emitted after the call. The channel is buffered so
+// emitting never blocks the fake.
+func (f *FakeRuntime) Events(ctx context.Context, labels map[string]string) (<-chan Event, <-chan error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := fakeWatcher{ctx: ctx, labels: labels, events: make(chan Event, 256)}
+	f.watchers = append(f.watchers, w)
+	errs := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		errs <- ctx.Err()
+	}()
+	return w.events, errs
+}
+
+func (f *FakeRuntime) Exec(ctx context.Context, id string, cmd, env []string) (*ExecResult, error) {
+	f.mu.Lock()
+	f.execs = append(f.execs, ExecCall{ContainerID: id, Cmd: cmd, Env: env})
+	c, err := f.container(id)
+	var info ContainerInfo
+	if err == nil {
+		info = c.info
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Running {
+		return nil, fmt.Errorf("container %s is not running", id)
+	}
+	return f.handle(info, cmd), nil
+}
+
+func (f *FakeRuntime) handle(c ContainerInfo, cmd []string) *ExecResult {
+	if f.ExecHandler == nil {
+		return &ExecResult{}
+	}
+	if res := f.ExecHandler(c, cmd); res != nil {
+		return res
+	}
+	return &ExecResult{}
+}
@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Images holds the image references a project runs. Once a project has been
+// created these are digest references (repo@sha256:...) so the exact same
+// images are used on every start.
+type Images struct {
+	BloodHound string
+	Neo4j      string
+	Postgres   string
+}
+
+// DefaultImages returns the floating tags new projects are created from.
+func DefaultImages() Images {
+	return Images{
+		BloodHound: BLOODHOUND,
+		Neo4j:      NEO4J,
+		Postgres:   POSTGRESQL,
+	}
+}
+
+// IsZero reports whether no images have been pinned yet.
+func (i Images) IsZero() bool {
+	return i.BloodHound == "" && i.Neo4j == "" && i.Postgres == ""
+}
+
+// List returns the images in startup order.
+func (i Images) List() []string {
+	return []string{i.Postgres, i.Neo4j, i.BloodHound}
+}
+
+// ResolveDigest returns a digest reference (repo@sha256:...) for a local
+// image. Images without a repo digest for their own repository, such as ones
+// built or retagged locally, are pinned to the local image ID instead.
+func (m *Manager) ResolveDigest(imageName string) (string, error) {
+	inspect, err := m.rt.ImageInspect(m.ctx, imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", imageName, err)
+	}
+
+	repo := imageRepository(imageName)
+	for _, d := range inspect.RepoDigests {
+		if imageRepository(d) == repo {
+			return d, nil
+		}
+	}
+	if inspect.ID == "" {
+		return "", fmt.Errorf("image %s has neither a digest for %s nor an image ID", imageName, repo)
+	}
+	return inspect.ID, nil
+}
+
+// ResolveImages pins every image in imgs to its local digest.
+func (m *Manager) ResolveImages(imgs Images) (Images, error) {
+	var out Images
+	var err error
+	if out.BloodHound, err = m.ResolveDigest(imgs.BloodHound); err != nil {
+		return Images{}, err
+	}
+	if out.Neo4j, err = m.ResolveDigest(imgs.Neo4j); err != nil {
+		return Images{}, err
+	}
+	if out.Postgres, err = m.ResolveDigest(imgs.Postgres); err != nil {
+		return Images{}, err
+	}
+	return out, nil
+}
+
+// dockerHubRegistries are the names Docker Hub references may carry.
+var dockerHubRegistries = []string{"docker.io", "index.docker.io", "registry-1.docker.io"}
+
+// imageRepository strips the tag or digest from an image reference and
+// normalizes Docker Hub repositories to their short form, so "neo4j",
+// "library/neo4j" and "docker.io/library/neo4j" are the same repository.
+// Docker reports RepoDigests in the short form, Podman in the long one.
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	// A colon after the last slash is a tag, anything before is a registry port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+
+	// The first path component is a registry if it looks like a host name
+	registry, path, found := strings.Cut(ref, "/")
+	if !found || (!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		registry, path = "docker.io", ref
+	}
+	if !slices.Contains(dockerHubRegistries, registry) {
+		return ref
+	}
+	// Official images live under library/ on Docker Hub
+	return strings.TrimPrefix(path, "library/")
+}
+
+// ReferenceTag returns the tag of an image reference ("4.4" for
+// neo4j:4.4), or an empty string for digest-only and untagged references.
+func ReferenceTag(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+// PinnedAlias returns the local tag a digest reference is saved under in an
+// offline bundle. Docker drops repo digests on save/load, so the alias is the
+// only way to find a pinned image again on a machine without registry access.
+func PinnedAlias(ref string) string {
+	i := strings.Index(ref, "@sha256:")
+	if i < 0 {
+		return ""
+	}
+	return "silohound/pinned:sha256-" + ref[i+len("@sha256:"):]
+}
+
+// LocalImage returns the reference under which ref is available locally: ref
+// itself, or its pinned alias when it was loaded from an offline bundle. The
+// boolean is false if neither exists.
+func (m *Manager) LocalImage(ref string) (string, bool, error) {
+	exists, err := m.ImageExists(ref)
+	if err != nil || exists {
+		return ref, exists, err
+	}
+	alias := PinnedAlias(ref)
+	if alias == "" {
+		return ref, false, nil
+	}
+	exists, err = m.ImageExists(alias)
+	if err != nil || !exists {
+		return ref, false, err
+	}
+	return alias, true, nil
+}
+
+// ImageID returns the local image ID for ref.
+func (m *Manager) ImageID(ref string) (string, error) {
+	inspect, err := m.rt.ImageInspect(m.ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return inspect.ID, nil
+}
+
+func (m *Manager) TagImage(source, target string) error {
+	return m.rt.ImageTag(m.ctx, source, target)
+}
+
+// SaveImage writes a `docker save` style tar of ref to w.
+func (m *Manager) SaveImage(ref string, w io.Writer) error {
+	return m.rt.ImageSave(m.ctx, ref, w)
+}
+
+// LoadImages loads a `docker save` style tar into the container engine.
+func (m *Manager) LoadImages(r io.Reader) error {
+	if err := m.rt.ImageLoad(m.ctx, r, m.progress()); err != nil {
+		return fmt.Errorf("image load failed: %w", err)
+	}
+	return nil
+}
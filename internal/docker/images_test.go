@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"context"
+	"testing"
+)
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"docker.io/specterops/bloodhound:latest":          "specterops/bloodhound",
+		"specterops/bloodhound@sha256:abc":                "specterops/bloodhound",
+		"docker.io/library/neo4j:4.4":                     "neo4j",
+		"library/neo4j:4.4":                               "neo4j",
+		"index.docker.io/library/postgres:16":             "postgres",
+		"ghcr.io/library/neo4j:4.4":                       "ghcr.io/library/neo4j",
+		"localhost/neo4j:dev":                             "localhost/neo4j",
+		"neo4j@sha256:abc":                                "neo4j",
+		"registry.local:5000/team/postgres:16":            "registry.local:5000/team/postgres",
+		"registry.local:5000/team/postgres@sha256:abcdef": "registry.local:5000/team/postgres",
+	}
+	for ref, want := range tests {
+		if got := imageRepository(ref); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestResolveDigest(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+	neo4j := rt.AddImage("neo4j:4.4")
+	rt.ImageTag(context.Background(), "neo4j:4.4", "docker.io/library/neo4j:4.4")
+	rt.ImageTag(context.Background(), "neo4j:4.4", "team/neo4j:dev")
+
+	// Docker reports the short form, Podman the long one
+	for _, ref := range []string{"neo4j:4.4", "docker.io/library/neo4j:4.4"} {
+		got, err := mgr.ResolveDigest(ref)
+		if err != nil || got != neo4j.RepoDigests[0] {
+			t.Errorf("ResolveDigest(%q) = %q, %v; want %q", ref, got, err, neo4j.RepoDigests[0])
+		}
+	}
+
+	// A retagged image has no digest of its own and is pinned by ID, not
+	// to the digest of another repository
+	if got, err := mgr.ResolveDigest("team/neo4j:dev"); err != nil || got != neo4j.ID {
+		t.Errorf("ResolveDigest(team/neo4j:dev) = %q, %v; want image ID %q", got, err, neo4j.ID)
+	}
+}
+
+func TestReferenceTag(t *testing.T) {
+	tests := map[string]string{
+		NEO4J:                                  "4.4",
+		POSTGRESQL:                             "16",
+		"neo4j:5.26@sha256:abc":                "5.26",
+		"neo4j@sha256:abc":                     "",
+		"registry.local:5000/team/postgres":    "",
+		"registry.local:5000/team/postgres:15": "15",
+	}
+	for ref, want := range tests {
+		if got := ReferenceTag(ref); got != want {
+			t.Errorf("ReferenceTag(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
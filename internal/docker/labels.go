@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"fmt"
+)
+
+const (
+	LABEL_PREFIX    = "io.silohound."
+	LABEL_MANAGED   = "io.silohound.managed"
+	LABEL_PROJECT   = "io.silohound.project"
+	LABEL_ROLE      = "io.silohound.role"
+	LABEL_VERSION   = "io.silohound.version"
+	LABEL_DATA_PATH = "io.silohound.data-path"
+
+	ROLE_POSTGRES   = "psql"
+	ROLE_NEO4J      = "neo4j"
+	ROLE_BLOODHOUND = "bh"
+	ROLE_NETWORK    = "network"
+	ROLE_TOOLBOX    = "toolbox"
+)
+
+// Version is recorded on every container and network the Manager creates.
+// main overrides it with the SiloHound build version.
+var Version = "dev"
+
+// legacyNames maps roles to the container name suffixes used before labels
+// were introduced.
+var legacyNames = map[string]string{
+	ROLE_POSTGRES:   "PSQL",
+	ROLE_NEO4J:      "Neo4j",
+	ROLE_BLOODHOUND: "BH",
+}
+
+func projectLabels(projectName, role, dataPath string) map[string]string {
+	return map[string]string{
+		LABEL_MANAGED:   "true",
+		LABEL_PROJECT:   projectName,
+		LABEL_ROLE:      role,
+		LABEL_VERSION:   Version,
+		LABEL_DATA_PATH: dataPath,
+	}
+}
+
+func projectFilter(projectName string) map[string]string {
+	return map[string]string{LABEL_PROJECT: projectName}
+}
+
+func containerName(projectName, role string) string {
+	return fmt.Sprintf("SiloHound_%s_%s", projectName, legacyNames[role])
+}
+
+func networkName(projectName string) string {
+	return fmt.Sprintf("SiloHound_%s_Network", projectName)
+}
+
+// isLegacyContainer reports whether c is an unlabeled container created by an
+// older SiloHound for projectName. Only exact names match, so project "Acme"
+// never claims "SiloHound_Acme_Test_Neo4j".
+func isLegacyContainer(c ContainerInfo, projectName string) bool {
+	if _, ok := c.Labels[LABEL_PROJECT]; ok {
+		return false
+	}
+	for role := range legacyNames {
+		if c.Name == containerName(projectName, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// projectContainers lists the containers owned by projectName: everything
+// carrying its project label plus any unlabeled legacy containers with its
+// exact names that have not been migrated yet.
+func (m *Manager) projectContainers(projectName string, all bool) ([]ContainerInfo, error) {
+	labeled, err := m.rt.ContainerList(m.ctx, all, projectFilter(projectName))
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := m.legacyContainers(projectName, all)
+	if err != nil {
+		return nil, err
+	}
+	return append(labeled, legacy...), nil
+}
+
+// legacyContainers looks up projectName's unlabeled containers by their
+// exact legacy names.
+func (m *Manager) legacyContainers(projectName string, all bool) ([]ContainerInfo, error) {
+	var names []string
+	for role := range legacyNames {
+		names = append(names, containerName(projectName, role))
+	}
+	containers, err := m.rt.ContainerListByName(m.ctx, all, names)
+	if err != nil {
+		return nil, err
+	}
+	var out []ContainerInfo
+	for _, c := range containers {
+		if isLegacyContainer(c, projectName) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// MigrateLegacyContainers removes unlabeled containers and the unlabeled
+// network left behind by older SiloHound versions for projectName, so they
+// are recreated with ownership labels on the next start. Project data lives
+// in bind mounts, so nothing is lost. It returns the number of containers
+// migrated.
+func (m *Manager) MigrateLegacyContainers(projectName string) (int, error) {
+	containers, err := m.legacyContainers(projectName, true)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, c := range containers {
+		fmt.Printf("Migrating legacy container %s to labeled ownership...\n", c.Name)
+		m.removeContainer(c.ID)
+		migrated++
+	}
+
+	networks, err := m.rt.NetworkList(m.ctx, nil)
+	if err != nil {
+		return migrated, err
+	}
+	for _, n := range networks {
+		if n.Name != networkName(projectName) {
+			continue
+		}
+		if _, ok := n.Labels[LABEL_PROJECT]; ok {
+			continue
+		}
+		m.debugf("removing legacy network %s", n.Name)
+		if err := m.rt.NetworkRemove(m.ctx, n.ID); err != nil {
+			m.debugf("could not remove legacy network %s: %v", n.Name, err)
+		}
+	}
+
+	return migrated, nil
+}
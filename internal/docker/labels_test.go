@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestIsLegacyContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       ContainerInfo
+		project string
+		want    bool
+	}{
+		{"exact legacy name", ContainerInfo{Name: "SiloHound_Acme_Neo4j"}, "Acme", true},
+		{"other project sharing prefix", ContainerInfo{Name: "SiloHound_Acme_Test_Neo4j"}, "Acme", false},
+		{"labeled container", ContainerInfo{
+			Name:   "SiloHound_Acme_BH",
+			Labels: map[string]string{LABEL_PROJECT: "Acme"},
+		}, "Acme", false},
+		{"unrelated container", ContainerInfo{Name: "postgres"}, "Acme", false},
+	}
+
+	for _, tt := range tests {
+		if got := isLegacyContainer(tt.c, tt.project); got != tt.want {
+			t.Errorf("%s: isLegacyContainer() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
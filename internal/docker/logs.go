@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogRoles are the service roles that have logs, in startup order.
+var LogRoles = []string{ROLE_POSTGRES, ROLE_NEO4J, ROLE_BLOODHOUND}
+
+// RoleLogs writes the logs of the project's container for role.
+func (m *Manager) RoleLogs(projectName, role string, opts LogOptions, stdout, stderr io.Writer) error {
+	id, err := m.ContainerID(projectName, role)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("no %s container found for project %s", role, projectName)
+	}
+	return m.rt.ContainerLogs(m.ctx, id, opts, stdout, stderr)
+}
+
+// StreamLogs writes the logs of the given roles to w. With more than one
+// role every line is prefixed with its role; followed logs are interleaved
+// as they arrive, otherwise each role is written in turn. Roles without a
+// container are skipped with a note unless none has one.
+func (m *Manager) StreamLogs(projectName string, roles []string, opts LogOptions, w io.Writer) error {
+	if len(roles) == 1 {
+		return m.RoleLogs(projectName, roles[0], opts, w, w)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(roles))
+	for i, role := range roles {
+		lw := &lineWriter{mu: &mu, w: w, prefix: fmt.Sprintf("%-5s | ", role)}
+		run := func() {
+			errs[i] = m.RoleLogs(projectName, role, opts, lw, lw)
+			lw.Flush()
+		}
+		if opts.Follow {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run()
+			}()
+		} else {
+			run()
+		}
+	}
+	wg.Wait()
+
+	var failed int
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%-5s | <%v>\n", roles[i], err)
+		}
+	}
+	if failed == len(roles) {
+		return fmt.Errorf("no logs available for project %s", projectName)
+	}
+	return nil
+}
+
+// lineWriter prefixes every complete line and writes it under a shared lock,
+// so concurrent streams never interleave mid-line.
+type lineWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := l.emit(l.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[i+1:]
+	}
+}
+
+// Flush writes a trailing partial line.
+func (l *lineWriter) Flush() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+	err := l.emit(append(l.buf, '\n'))
+	l.buf = nil
+	return err
+}
+
+func (l *lineWriter) emit(line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.w, l.prefix); err != nil {
+		return err
+	}
+	_, err := l.w.Write(line)
+	return err
+}
@@ -0,0 +1,351 @@
+package docker
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFakeManager(t *testing.T) (*Manager, *FakeRuntime) {
+	t.Helper()
+	rt := NewFakeRuntime()
+	rt.AddImage(POSTGRESQL)
+	return NewManager(context.Background(), rt, false), rt
+}
+
+func TestManager_StopProjectContainers(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme"), Running: true})
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Neo4j", Running: true}) // legacy, unlabeled
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Test_PSQL", Labels: projectLabels("Acme_Test", ROLE_POSTGRES, "/data/acme-test"), Running: true})
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Test_Neo4j", Running: true}) // legacy, other project
+
+	running, err := mgr.IsRunning("Acme")
+	if err != nil || !running {
+		t.Fatalf("IsRunning(Acme) = %v, %v; want true", running, err)
+	}
+
+	if err := mgr.StopProjectContainers("Acme"); err != nil {
+		t.Fatalf("StopProjectContainers failed: %v", err)
+	}
+
+	var left []string
+	for _, c := range rt.Containers() {
+		left = append(left, c.Name)
+	}
+	if len(left) != 2 {
+		t.Fatalf("remaining containers = %v, want only Acme_Test", left)
+	}
+	for _, name := range left {
+		if !strings.HasPrefix(name, "SiloHound_Acme_Test_") {
+			t.Errorf("container %s of another project was stopped", name)
+		}
+	}
+
+	if running, _ := mgr.IsRunning("Acme"); running {
+		t.Error("IsRunning(Acme) = true after stop")
+	}
+}
+
+func TestManager_MigrateLegacyContainers(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_BH"})
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme")})
+	rt.AddNetwork(networkName("Acme"), nil)
+
+	migrated, err := mgr.MigrateLegacyContainers("Acme")
+	if err != nil {
+		t.Fatalf("MigrateLegacyContainers failed: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("migrated = %d, want 1", migrated)
+	}
+	if got := len(rt.Containers()); got != 1 {
+		t.Errorf("%d containers left, want the labeled one", got)
+	}
+
+	netName, err := mgr.EnsureNetwork("Acme", "/data/acme")
+	if err != nil {
+		t.Fatalf("EnsureNetwork failed: %v", err)
+	}
+	networks, _ := rt.NetworkList(context.Background(), projectFilter("Acme"))
+	if len(networks) != 1 || networks[0].Name != netName {
+		t.Fatalf("networks = %+v, want one labeled %s", networks, netName)
+	}
+	if _, err := mgr.EnsureNetwork("Acme", "/data/acme"); err != nil {
+		t.Errorf("EnsureNetwork is not idempotent: %v", err)
+	}
+}
+
+func TestManager_StopProjectContainer(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme"), Running: true})
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Neo4j", Running: true}) // legacy, unlabeled
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Test_PSQL", Labels: projectLabels("Acme_Test", ROLE_POSTGRES, "/data/acme-test"), Running: true})
+
+	for _, role := range []string{ROLE_POSTGRES, ROLE_NEO4J} {
+		if err := mgr.StopProjectContainer("Acme", role); err != nil {
+			t.Fatalf("StopProjectContainer(%s) failed: %v", role, err)
+		}
+	}
+	if left := rt.Containers(); len(left) != 1 || left[0].Name != "SiloHound_Acme_Test_PSQL" {
+		t.Errorf("remaining containers = %+v, want only Acme_Test's", left)
+	}
+}
+
+func TestNamePattern(t *testing.T) {
+	re := regexp.MustCompile(namePattern("SiloHound_Acme.1_PSQL"))
+	for name, want := range map[string]bool{
+		"SiloHound_Acme.1_PSQL":     true,
+		"/SiloHound_Acme.1_PSQL":    true,
+		"SiloHound_AcmeX1_PSQL":     false,
+		"SiloHound_Acme.1_PSQL_old": false,
+		"old_SiloHound_Acme.1_PSQL": false,
+	} {
+		if got := re.MatchString(name); got != want {
+			t.Errorf("namePattern matches %q = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestManager_SpawnPostgres(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+	var probed bool
+	rt.ExecHandler = func(c ContainerInfo, cmd []string) *ExecResult {
+		if cmd[0] == "pg_isready" {
+			probed = true
+		}
+		return nil
+	}
+
+	id, err := mgr.SpawnPostgres("Acme", "/data/acme", STORAGE_BIND, networkName("Acme"), POSTGRESQL, "secret", Limits{CPUs: 1.5, Memory: GiB})
+	if err != nil {
+		t.Fatalf("SpawnPostgres failed: %v", err)
+	}
+	if !probed {
+		t.Error("readiness probe was not run")
+	}
+
+	spec, _ := rt.Spec(id)
+	if spec.Labels[LABEL_PROJECT] != "Acme" || spec.Labels[LABEL_ROLE] != ROLE_POSTGRES {
+		t.Errorf("labels = %v", spec.Labels)
+	}
+	if len(spec.Mounts) != 1 || spec.Mounts[0].Source != "/data/acme/"+PSQLFOLDER {
+		t.Errorf("mounts = %+v", spec.Mounts)
+	}
+	if spec.Network != networkName("Acme") || len(spec.Aliases) != 1 || spec.Aliases[0] != "app-db" {
+		t.Errorf("network = %s %v", spec.Network, spec.Aliases)
+	}
+	if spec.Limits.CPUs != 1.5 || spec.Limits.Memory != GiB {
+		t.Errorf("limits = %+v", spec.Limits)
+	}
+
+	got, err := mgr.ContainerID("Acme", ROLE_POSTGRES)
+	if err != nil || got != id {
+		t.Errorf("ContainerID = %q, %v; want %q", got, err, id)
+	}
+}
+
+func TestManager_RunToolbox(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+	rt.ExecHandler = func(c ContainerInfo, cmd []string) *ExecResult {
+		if strings.Contains(strings.Join(cmd, " "), "fail") {
+			return &ExecResult{Stderr: "boom", ExitCode: 2}
+		}
+		return &ExecResult{Stdout: "ok\n"}
+	}
+
+	out, err := mgr.RunToolbox("/data/acme", []string{"echo", "ok"})
+	if err != nil || out != "ok\n" {
+		t.Errorf("RunToolbox = %q, %v", out, err)
+	}
+
+	if _, err := mgr.RunToolbox("/data/acme", []string{"fail"}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("RunToolbox error = %v, want exit code failure with output", err)
+	}
+	if n := len(rt.Containers()); n != 0 {
+		t.Errorf("%d toolbox containers left behind", n)
+	}
+}
+
+func TestManager_InspectProject(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme"), Running: true})
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_BH"}) // legacy, stopped
+	rt.AddContainer(ContainerInfo{Name: "SiloHound_Other_Neo4j", Labels: projectLabels("Other", ROLE_NEO4J, "/data/other"), Running: true})
+
+	got, err := mgr.InspectProject("Acme")
+	if err != nil {
+		t.Fatalf("InspectProject failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d containers, want 2: %+v", len(got), got)
+	}
+	if !got[ROLE_POSTGRES].Running {
+		t.Error("postgres should be running")
+	}
+	if bh, ok := got[ROLE_BLOODHOUND]; !ok || bh.Running {
+		t.Errorf("legacy BloodHound container = %+v, %v; want stopped entry", bh, ok)
+	}
+}
+
+func TestManager_Volumes(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	for i := 0; i < 2; i++ {
+		if err := mgr.EnsureVolumes("Acme", "/data/acme"); err != nil {
+			t.Fatalf("EnsureVolumes failed: %v", err)
+		}
+	}
+	if err := mgr.EnsureVolumes("Acme_Test", "/data/acme-test"); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := mgr.ProjectVolumes("Acme")
+	if err != nil || len(volumes) != 2 {
+		t.Fatalf("ProjectVolumes = %+v, %v; want 2", volumes, err)
+	}
+
+	id, err := mgr.SpawnPostgres("Acme", "/data/acme", STORAGE_VOLUME, networkName("Acme"), POSTGRESQL, "secret", Limits{})
+	if err != nil {
+		t.Fatalf("SpawnPostgres failed: %v", err)
+	}
+	spec, _ := rt.Spec(id)
+	if len(spec.Mounts) != 1 || !spec.Mounts[0].Volume || spec.Mounts[0].Source != volumeName("Acme", ROLE_POSTGRES) {
+		t.Errorf("mounts = %+v, want the Postgres volume", spec.Mounts)
+	}
+
+	var toolboxMounts []Mount
+	rt.ExecHandler = func(c ContainerInfo, cmd []string) *ExecResult {
+		if c.Labels[LABEL_ROLE] == ROLE_TOOLBOX {
+			spec, _ := rt.Spec(c.ID)
+			toolboxMounts = spec.Mounts
+		}
+		return nil
+	}
+	if _, err := mgr.RunDataToolbox("Acme", STORAGE_VOLUME, "/data/acme", []string{"true"}); err != nil {
+		t.Fatalf("RunDataToolbox failed: %v", err)
+	}
+	if len(toolboxMounts) != 3 || toolboxMounts[1].Target != TOOLBOX_VOLUME_ROOT+"/"+PSQLFOLDER {
+		t.Errorf("toolbox mounts = %+v", toolboxMounts)
+	}
+
+	// In-use volumes cannot be removed
+	if err := mgr.RemoveProjectVolumes("Acme"); err == nil {
+		t.Error("RemoveProjectVolumes removed a volume in use")
+	}
+	mgr.StopProjectContainers("Acme")
+	if err := mgr.RemoveProjectVolumes("Acme"); err != nil {
+		t.Fatalf("RemoveProjectVolumes failed: %v", err)
+	}
+	if v, _ := mgr.ProjectVolumes("Acme"); len(v) != 0 {
+		t.Errorf("volumes left: %+v", v)
+	}
+	if v, _ := mgr.ProjectVolumes("Acme_Test"); len(v) != 2 {
+		t.Errorf("other project's volumes touched: %+v", v)
+	}
+}
+
+func TestManager_StreamLogs(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+
+	psql := rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme"), Running: true})
+	rt.SetLogs(psql, "ready\nlistening")
+	bh := rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_BH", Running: true}) // legacy
+	rt.SetLogs(bh, "started\n")
+
+	var out strings.Builder
+	if err := mgr.StreamLogs("Acme", LogRoles, LogOptions{Tail: "all"}, &out); err != nil {
+		t.Fatalf("StreamLogs failed: %v", err)
+	}
+	want := "psql  | ready\npsql  | listening\nbh    | started\nneo4j | <no neo4j container found for project Acme>\n"
+	if got := out.String(); got != want {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	if err := mgr.StreamLogs("Acme", []string{ROLE_POSTGRES}, LogOptions{}, &out); err != nil || out.String() != "ready\nlistening" {
+		t.Errorf("single role logs = %q, %v; want unprefixed output", out.String(), err)
+	}
+	if err := mgr.StreamLogs("Other", LogRoles, LogOptions{}, &out); err == nil {
+		t.Error("StreamLogs succeeded for a project without containers")
+	}
+}
+
+func TestManager_Supervise(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+	spawn := func() string {
+		id, err := mgr.SpawnPostgres("Acme", "/data/acme", STORAGE_BIND, networkName("Acme"), POSTGRESQL, "secret", Limits{})
+		if err != nil {
+			t.Fatalf("SpawnPostgres failed: %v", err)
+		}
+		return id
+	}
+	first := spawn()
+
+	crashes := make(chan Crash, 1)
+	cfg := DefaultSupervisorConfig()
+	cfg.Backoff = time.Millisecond
+	cfg.Respawn = func(role string) error {
+		if role != ROLE_POSTGRES {
+			t.Errorf("respawned %s", role)
+		}
+		spawn()
+		return nil
+	}
+	cfg.OnCrash = func(c Crash) { crashes <- c }
+	done := make(chan error, 1)
+	go func() { done <- mgr.Supervise("Acme", cfg) }()
+
+	// Wait for the supervisor to subscribe
+	for i := 0; ; i++ {
+		rt.mu.Lock()
+		n := len(rt.watchers)
+		rt.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 200 {
+			t.Fatal("supervisor never subscribed to events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rt.SetLogs(first, "FATAL: out of memory\n")
+	time.Sleep(20 * time.Millisecond)
+	rt.Crash(first, 137, true)
+
+	select {
+	case c := <-crashes:
+		if c.Role != ROLE_POSTGRES || c.ExitCode != 137 || !c.OOM || c.Attempts != 1 || c.RestartErr != nil {
+			t.Errorf("crash = %+v", c)
+		}
+		if !strings.Contains(c.Logs, "out of memory") {
+			t.Errorf("crash logs = %q", c.Logs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("crash was not reported")
+	}
+	if id, _ := mgr.ContainerID("Acme", ROLE_POSTGRES); id == "" || id == first {
+		t.Errorf("postgres container after restart = %q", id)
+	}
+
+	// A requested stop is not a crash and ends supervision
+	mgr.StopProjectContainers("Acme")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Supervise = %v, want nil after stop", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor kept running after the project stopped")
+	}
+	if len(crashes) != 0 {
+		t.Errorf("stop reported as crash: %+v", <-crashes)
+	}
+}
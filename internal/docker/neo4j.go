@@ -0,0 +1,258 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	NEO4J_5 = "docker.io/library/neo4j:5"
+
+	// Neo4j major versions a project can run. Existing projects and new ones
+	// default to 4 (neo4j:4.4).
+	NEO4J_MAJOR_4       = "4"
+	NEO4J_MAJOR_5       = "5"
+	DEFAULT_NEO4J_MAJOR = NEO4J_MAJOR_4
+)
+
+var neo4jImages = map[string]string{
+	NEO4J_MAJOR_4: NEO4J,
+	NEO4J_MAJOR_5: NEO4J_5,
+}
+
+// ValidNeo4jMajor reports whether major is a supported Neo4j major version.
+func ValidNeo4jMajor(major string) bool {
+	_, ok := neo4jImages[major]
+	return ok
+}
+
+// Neo4jImage returns the floating image tag for a Neo4j major version.
+func Neo4jImage(major string) string {
+	if img, ok := neo4jImages[major]; ok {
+		return img
+	}
+	return NEO4J
+}
+
+// DefaultImagesFor returns the floating tags a project on the given Neo4j
+// major version is created or upgraded from.
+func DefaultImagesFor(neo4jMajor string) Images {
+	imgs := DefaultImages()
+	imgs.Neo4j = Neo4jImage(neo4jMajor)
+	return imgs
+}
+
+// Plugins a project can enable. APOC extended needs APOC core, so enabling
+// it enables both.
+const (
+	PLUGIN_APOC          = "apoc"
+	PLUGIN_APOC_EXTENDED = "apoc-extended"
+	PLUGIN_GDS           = "gds"
+	PLUGINS_NONE         = "none"
+
+	// DEFAULT_PLUGINS is what projects ran with before plugins were
+	// configurable.
+	DEFAULT_PLUGINS = PLUGIN_APOC
+
+	// PLUGINS_DIR is where the Neo4j image loads plugin jars from.
+	PLUGINS_DIR = "/plugins"
+)
+
+// pluginOrder lists the plugins in the order they are reported.
+var pluginOrder = []string{PLUGIN_APOC, PLUGIN_APOC_EXTENDED, PLUGIN_GDS}
+
+// imagePlugins maps a plugin to the name the Neo4j image downloads it by, per
+// major version. The 4.4 "apoc" plugin is the full APOC, extended included.
+var imagePlugins = map[string]map[string]string{
+	NEO4J_MAJOR_4: {PLUGIN_APOC: "apoc", PLUGIN_APOC_EXTENDED: "apoc", PLUGIN_GDS: "graph-data-science"},
+	NEO4J_MAJOR_5: {PLUGIN_APOC: "apoc", PLUGIN_APOC_EXTENDED: "apoc-extended", PLUGIN_GDS: "graph-data-science"},
+}
+
+// pluginProcedures are the procedure namespaces a plugin needs unrestricted.
+var pluginProcedures = map[string]string{
+	PLUGIN_APOC:          "apoc.*",
+	PLUGIN_APOC_EXTENDED: "apoc.*",
+	PLUGIN_GDS:           "gds.*",
+}
+
+// ParsePlugins parses a comma-separated plugin list. "none" or an empty
+// string enable no plugins. The result is in canonical order without
+// duplicates.
+func ParsePlugins(s string) ([]string, error) {
+	want := map[string]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch p {
+		case "", PLUGINS_NONE:
+		case PLUGIN_APOC, PLUGIN_GDS:
+			want[p] = true
+		case PLUGIN_APOC_EXTENDED:
+			want[PLUGIN_APOC] = true
+			want[p] = true
+		default:
+			return nil, fmt.Errorf("unknown Neo4j plugin %q (expected %s or %s)", p, strings.Join(pluginOrder, ", "), PLUGINS_NONE)
+		}
+	}
+	var out []string
+	for _, p := range pluginOrder {
+		if want[p] {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// VendoredPlugins finds plugin jars in dir and returns the host path of the
+// jar for each plugin it recognizes. A missing dir has no plugins.
+func VendoredPlugins(dir string) (map[string]string, error) {
+	jars, err := filepath.Glob(filepath.Join(dir, "*.jar"))
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]string{}
+	for _, jar := range jars {
+		if info, err := os.Stat(jar); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if p := jarPlugin(filepath.Base(jar)); p != "" {
+			found[p] = jar
+		}
+	}
+	return found, nil
+}
+
+// jarPlugin tells which plugin a jar file provides from its release name,
+// e.g. apoc-5.20.0-core.jar or neo4j-graph-data-science-2.6.7.jar.
+func jarPlugin(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "graph-data-science"):
+		return PLUGIN_GDS
+	case !strings.HasPrefix(name, "apoc"):
+		return ""
+	case strings.Contains(name, "extended") || strings.HasSuffix(name, "-all.jar"):
+		return PLUGIN_APOC_EXTENDED
+	default:
+		return PLUGIN_APOC
+	}
+}
+
+// Neo4jOptions configures a Neo4j container.
+type Neo4jOptions struct {
+	Version string // Major version of the image, see NEO4J_MAJOR_4
+	Memory  Neo4jMemory
+	Plugins []string // Enabled plugins, see ParsePlugins
+	// Vendored maps plugins to jar files on the host. They are mounted into
+	// the container instead of being downloaded at startup.
+	Vendored map[string]string
+}
+
+// downloads returns the image plugin names the container has to fetch: the
+// enabled plugins that are not vendored. Vendored 4.4 full APOC also covers
+// APOC core.
+func (o Neo4jOptions) downloads() []string {
+	names := imagePlugins[NEO4J_MAJOR_4]
+	if o.Version == NEO4J_MAJOR_5 {
+		names = imagePlugins[NEO4J_MAJOR_5]
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range o.Plugins {
+		if o.Vendored[p] != "" {
+			continue
+		}
+		if p == PLUGIN_APOC && o.Version != NEO4J_MAJOR_5 && o.Vendored[PLUGIN_APOC_EXTENDED] != "" {
+			continue
+		}
+		if name := names[p]; !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// pluginMounts mounts the vendored jars of enabled plugins read-only into
+// the image's plugin folder.
+func (o Neo4jOptions) pluginMounts() []Mount {
+	var mounts []Mount
+	for _, p := range o.Plugins {
+		if jar := o.Vendored[p]; jar != "" {
+			mounts = append(mounts, Mount{Source: jar, Target: path.Join(PLUGINS_DIR, filepath.Base(jar)), ReadOnly: true})
+		}
+	}
+	return mounts
+}
+
+// neo4jEnv builds the container environment. Neo4j 5 renamed the memory
+// settings and the plugin variable, so they depend on the major version.
+func neo4jEnv(opts Neo4jOptions, neo4jPass string) []string {
+	env := []string{fmt.Sprintf("NEO4J_AUTH=neo4j/%s", neo4jPass)}
+
+	memPrefix := "NEO4J_dbms_memory_"
+	pluginVar := "NEO4J_labs_plugins"
+	if opts.Version == NEO4J_MAJOR_5 {
+		memPrefix = "NEO4J_server_memory_"
+		pluginVar = "NEO4J_PLUGINS"
+	}
+	if downloads := opts.downloads(); len(downloads) > 0 {
+		list, _ := json.Marshal(downloads)
+		env = append(env, fmt.Sprintf("%s=%s", pluginVar, list))
+	}
+
+	var procedures []string
+	for _, p := range opts.Plugins {
+		if p == PLUGIN_APOC {
+			env = append(env,
+				"NEO4J_apoc_export_file_enabled=true",
+				"NEO4J_apoc_import_file_enabled=true",
+				"NEO4J_apoc_import_file_use__neo4j__config=false",
+			)
+		}
+		if proc := pluginProcedures[p]; !slices.Contains(procedures, proc) {
+			procedures = append(procedures, proc)
+		}
+	}
+	if len(procedures) > 0 {
+		env = append(env, "NEO4J_dbms_security_procedures_unrestricted="+strings.Join(procedures, ","))
+	}
+
+	// Inject heap size if provided
+	if opts.Memory.Heap != "" {
+		env = append(env, fmt.Sprintf("%sheap_initial__size=%s", memPrefix, opts.Memory.Heap))
+		env = append(env, fmt.Sprintf("%sheap_max__size=%s", memPrefix, opts.Memory.Heap))
+	}
+	if opts.Memory.PageCache != "" {
+		env = append(env, fmt.Sprintf("%spagecache_size=%s", memPrefix, opts.Memory.PageCache))
+	}
+	return env
+}
+
+// RunNeo4jAdmin runs a shell script in a throwaway container of the given
+// Neo4j image with the project's graph store mounted at /data. The image's
+// entrypoint runs it as the neo4j user, so files it writes keep the store's
+// ownership.
+func (m *Manager) RunNeo4jAdmin(projectName, wd, storage, imageName, script string) (string, error) {
+	spec := ContainerSpec{
+		Image: imageName,
+		Cmd:   []string{"sh", "-c", script},
+		Labels: map[string]string{
+			LABEL_MANAGED:   "true",
+			LABEL_PROJECT:   projectName,
+			LABEL_ROLE:      ROLE_TOOLBOX,
+			LABEL_VERSION:   Version,
+			LABEL_DATA_PATH: wd,
+		},
+		Mounts: []Mount{dataMount(projectName, wd, storage, ROLE_NEO4J, "/data")},
+	}
+	out, err := m.runTask(spec)
+	if err != nil {
+		return out, fmt.Errorf("neo4j-admin in %s: %w", imageName, err)
+	}
+	return strings.TrimSpace(out), nil
+}
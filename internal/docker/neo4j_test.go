@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeo4jEnv(t *testing.T) {
+	mem := Neo4jMemory{Heap: "2G", PageCache: "1G"}
+	tests := []struct {
+		version string
+		want    []string
+		absent  string
+	}{
+		{NEO4J_MAJOR_4, []string{"NEO4J_labs_plugins=[\"apoc\"]", "NEO4J_dbms_memory_heap_max__size=2G", "NEO4J_dbms_memory_pagecache_size=1G"}, "NEO4J_server_"},
+		{NEO4J_MAJOR_5, []string{"NEO4J_PLUGINS=[\"apoc\"]", "NEO4J_server_memory_heap_max__size=2G", "NEO4J_server_memory_pagecache_size=1G"}, "NEO4J_dbms_memory_"},
+	}
+	for _, tt := range tests {
+		env := strings.Join(neo4jEnv(Neo4jOptions{Version: tt.version, Memory: mem, Plugins: []string{PLUGIN_APOC}}, "secret"), "\n")
+		if !strings.Contains(env, "NEO4J_AUTH=neo4j/secret") {
+			t.Errorf("Neo4j %s env lacks auth:\n%s", tt.version, env)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(env, w) {
+				t.Errorf("Neo4j %s env lacks %s:\n%s", tt.version, w, env)
+			}
+		}
+		if strings.Contains(env, tt.absent) {
+			t.Errorf("Neo4j %s env contains %s:\n%s", tt.version, tt.absent, env)
+		}
+	}
+}
+
+func TestDefaultImagesFor(t *testing.T) {
+	if got := DefaultImagesFor(NEO4J_MAJOR_5).Neo4j; got != NEO4J_5 {
+		t.Errorf("DefaultImagesFor(5).Neo4j = %s", got)
+	}
+	if got := DefaultImagesFor("").Neo4j; got != NEO4J {
+		t.Errorf("DefaultImagesFor(\"\").Neo4j = %s, want %s", got, NEO4J)
+	}
+	if ValidNeo4jMajor("4.4") || !ValidNeo4jMajor(NEO4J_MAJOR_4) {
+		t.Error("ValidNeo4jMajor accepts the wrong versions")
+	}
+}
+
+func TestParsePlugins(t *testing.T) {
+	tests := map[string][]string{
+		"":                  nil,
+		"none":              nil,
+		"apoc":              {PLUGIN_APOC},
+		"GDS, apoc":         {PLUGIN_APOC, PLUGIN_GDS},
+		"apoc-extended":     {PLUGIN_APOC, PLUGIN_APOC_EXTENDED},
+		"gds,gds,apoc,none": {PLUGIN_APOC, PLUGIN_GDS},
+	}
+	for in, want := range tests {
+		got, err := ParsePlugins(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParsePlugins(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParsePlugins("apoc,bloom"); err == nil {
+		t.Error("ParsePlugins accepted an unknown plugin")
+	}
+}
+
+func TestNeo4jPlugins(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"apoc-5.20.0-core.jar", "neo4j-graph-data-science-2.6.7.jar", "notes.txt", "other.jar"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vendored, err := VendoredPlugins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		PLUGIN_APOC: filepath.Join(dir, "apoc-5.20.0-core.jar"),
+		PLUGIN_GDS:  filepath.Join(dir, "neo4j-graph-data-science-2.6.7.jar"),
+	}
+	if !reflect.DeepEqual(vendored, want) {
+		t.Errorf("VendoredPlugins = %v, want %v", vendored, want)
+	}
+	if got, err := VendoredPlugins(filepath.Join(dir, "missing")); err != nil || len(got) != 0 {
+		t.Errorf("VendoredPlugins(missing dir) = %v, %v", got, err)
+	}
+
+	// Vendored jars are mounted; only the rest is downloaded
+	opts := Neo4jOptions{Version: NEO4J_MAJOR_5, Plugins: []string{PLUGIN_APOC, PLUGIN_APOC_EXTENDED, PLUGIN_GDS}, Vendored: vendored}
+	env := strings.Join(neo4jEnv(opts, "secret"), "\n")
+	if !strings.Contains(env, `NEO4J_PLUGINS=["apoc-extended"]`) {
+		t.Errorf("env downloads the wrong plugins:\n%s", env)
+	}
+	if !strings.Contains(env, "NEO4J_dbms_security_procedures_unrestricted=apoc.*,gds.*") {
+		t.Errorf("env lacks unrestricted procedures:\n%s", env)
+	}
+	mounts := opts.pluginMounts()
+	if len(mounts) != 2 || mounts[0].Target != "/plugins/apoc-5.20.0-core.jar" || !mounts[0].ReadOnly {
+		t.Errorf("pluginMounts = %+v", mounts)
+	}
+
+	// Neo4j 4.4 has no separate extended plugin and no plugins means no settings
+	opts = Neo4jOptions{Version: NEO4J_MAJOR_4, Plugins: []string{PLUGIN_APOC, PLUGIN_APOC_EXTENDED, PLUGIN_GDS}}
+	if got := opts.downloads(); !reflect.DeepEqual(got, []string{"apoc", "graph-data-science"}) {
+		t.Errorf("4.4 downloads = %v", got)
+	}
+	env = strings.Join(neo4jEnv(Neo4jOptions{Version: NEO4J_MAJOR_4}, "secret"), "\n")
+	if strings.Contains(env, "plugins") || strings.Contains(env, "procedures") {
+		t.Errorf("env without plugins still configures them:\n%s", env)
+	}
+}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"strings"
+)
+
+// STATEMENT_ENV carries a statement to a database client's stdin. Statements
+// that set passwords must not be exec arguments, which every user on the
+// host can read with ps.
+const STATEMENT_ENV = "SILOHOUND_STATEMENT"
+
+// ChangeNeo4jPassword changes the neo4j user's password from current to next
+// in a running Neo4j container.
+func (m *Manager) ChangeNeo4jPassword(containerID, current, next string) error {
+	statement := "ALTER CURRENT USER SET PASSWORD FROM '" + escapeCypher(current) + "' TO '" + escapeCypher(next) + "';"
+	// cypher-shell reads the login from its environment
+	return m.execStatement(containerID, "cypher-shell -d system", statement, "NEO4J_USERNAME=neo4j", "NEO4J_PASSWORD="+current)
+}
+
+// ChangePostgresPassword sets the password of a Postgres user in a running
+// Postgres container.
+func (m *Manager) ChangePostgresPassword(containerID, user, next string) error {
+	statement := "ALTER USER " + user + " WITH PASSWORD '" + strings.ReplaceAll(next, "'", "''") + "';"
+	return m.execStatement(containerID, "psql -q -v ON_ERROR_STOP=1 -U "+user+" -d bloodhound", statement)
+}
+
+// execStatement pipes statement into client. The statement travels in the
+// environment and is printed by the shell's builtin printf, so it never
+// appears in a process's arguments.
+func (m *Manager) execStatement(containerID, client, statement string, env ...string) error {
+	script := `printf '%s\n' "$` + STATEMENT_ENV + `" | ` + client
+	return m.ExecEnv(containerID, []string{"sh", "-c", script}, append(env, STATEMENT_ENV+"="+statement))
+}
+
+// escapeCypher escapes s for a single-quoted Cypher string literal.
+func escapeCypher(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+}
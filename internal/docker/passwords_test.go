@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestManager_ChangePasswordsKeepSecretsOutOfArgs(t *testing.T) {
+	mgr, rt := newFakeManager(t)
+	neo4jID := rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_Neo4j", Labels: projectLabels("Acme", ROLE_NEO4J, "/data/acme"), Running: true})
+	psqlID := rt.AddContainer(ContainerInfo{Name: "SiloHound_Acme_PSQL", Labels: projectLabels("Acme", ROLE_POSTGRES, "/data/acme"), Running: true})
+
+	const current, next, pg = "old'Neo4jSecret", `new\Neo4jSecret`, "newPostgresSecret"
+	if err := mgr.ChangeNeo4jPassword(neo4jID, current, next); err != nil {
+		t.Fatalf("ChangeNeo4jPassword failed: %v", err)
+	}
+	if err := mgr.ChangePostgresPassword(psqlID, "bloodhound", pg); err != nil {
+		t.Fatalf("ChangePostgresPassword failed: %v", err)
+	}
+
+	execs := rt.Execs()
+	if len(execs) != 2 {
+		t.Fatalf("execs = %+v, want 2", execs)
+	}
+	for _, e := range execs {
+		for _, arg := range e.Cmd {
+			for _, secret := range []string{current, next, pg} {
+				if strings.Contains(arg, secret) {
+					t.Errorf("exec argument %q contains a password", arg)
+				}
+			}
+		}
+	}
+
+	want := STATEMENT_ENV + `=ALTER CURRENT USER SET PASSWORD FROM 'old\'Neo4jSecret' TO 'new\\Neo4jSecret';`
+	if !slices.Contains(execs[0].Env, want) || !slices.Contains(execs[0].Env, "NEO4J_PASSWORD="+current) {
+		t.Errorf("Neo4j exec env = %q, want statement %q and the current password", execs[0].Env, want)
+	}
+	if want := STATEMENT_ENV + "=ALTER USER bloodhound WITH PASSWORD '" + pg + "';"; !slices.Contains(execs[1].Env, want) {
+		t.Errorf("Postgres exec env = %q, want %q", execs[1].Env, want)
+	}
+}
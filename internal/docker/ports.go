@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	DEFAULT_BH_PORT         = 8181
+	DEFAULT_NEO4J_HTTP_PORT = 7474
+	DEFAULT_NEO4J_BOLT_PORT = 7687
+)
+
+// Ports holds the host ports a project publishes on 127.0.0.1.
+type Ports struct {
+	BloodHound int
+	Neo4jHTTP  int
+	Neo4jBolt  int
+}
+
+// DefaultPorts returns the ports SiloHound used before per-project allocation.
+func DefaultPorts() Ports {
+	return Ports{
+		BloodHound: DEFAULT_BH_PORT,
+		Neo4jHTTP:  DEFAULT_NEO4J_HTTP_PORT,
+		Neo4jBolt:  DEFAULT_NEO4J_BOLT_PORT,
+	}
+}
+
+// IsZero reports whether no ports have been assigned yet.
+func (p Ports) IsZero() bool {
+	return p.BloodHound == 0 && p.Neo4jHTTP == 0 && p.Neo4jBolt == 0
+}
+
+// PortAvailable reports whether a TCP port can currently be bound on 127.0.0.1.
+func PortAvailable(port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// AllocatePorts fills in every zero port in requested. The legacy default is
+// preferred when it is free and not reserved by another project, otherwise the
+// OS picks a free ephemeral port. Explicitly requested ports are kept as-is.
+func AllocatePorts(requested Ports, reserved map[int]bool) (Ports, error) {
+	used := make(map[int]bool, len(reserved)+3)
+	for p := range reserved {
+		used[p] = true
+	}
+	for _, p := range []int{requested.BloodHound, requested.Neo4jHTTP, requested.Neo4jBolt} {
+		if p != 0 {
+			used[p] = true
+		}
+	}
+
+	pick := func(current, preferred int) (int, error) {
+		if current != 0 {
+			return current, nil
+		}
+		if !used[preferred] && PortAvailable(preferred) {
+			used[preferred] = true
+			return preferred, nil
+		}
+		// Ask the kernel for free ports until we get one nobody else claimed
+		for i := 0; i < 20; i++ {
+			port, err := freePort()
+			if err != nil {
+				return 0, err
+			}
+			if !used[port] {
+				used[port] = true
+				return port, nil
+			}
+		}
+		return 0, fmt.Errorf("unable to find a free port")
+	}
+
+	var err error
+	out := requested
+	if out.BloodHound, err = pick(requested.BloodHound, DEFAULT_BH_PORT); err != nil {
+		return Ports{}, err
+	}
+	if out.Neo4jHTTP, err = pick(requested.Neo4jHTTP, DEFAULT_NEO4J_HTTP_PORT); err != nil {
+		return Ports{}, err
+	}
+	if out.Neo4jBolt, err = pick(requested.Neo4jBolt, DEFAULT_NEO4J_BOLT_PORT); err != nil {
+		return Ports{}, err
+	}
+	return out, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
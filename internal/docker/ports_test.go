@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllocatePorts(t *testing.T) {
+	// Keep explicit ports untouched
+	got, err := AllocatePorts(Ports{BloodHound: 18181, Neo4jHTTP: 17474, Neo4jBolt: 17687}, nil)
+	if err != nil {
+		t.Fatalf("AllocatePorts failed: %v", err)
+	}
+	if got.BloodHound != 18181 || got.Neo4jHTTP != 17474 || got.Neo4jBolt != 17687 {
+		t.Errorf("Explicit ports changed: %+v", got)
+	}
+
+	// Reserved defaults must be skipped
+	reserved := map[int]bool{DEFAULT_BH_PORT: true, DEFAULT_NEO4J_HTTP_PORT: true, DEFAULT_NEO4J_BOLT_PORT: true}
+	got, err = AllocatePorts(Ports{}, reserved)
+	if err != nil {
+		t.Fatalf("AllocatePorts failed: %v", err)
+	}
+	seen := map[int]bool{}
+	for _, p := range []int{got.BloodHound, got.Neo4jHTTP, got.Neo4jBolt} {
+		if p == 0 {
+			t.Errorf("Port not allocated: %+v", got)
+		}
+		if reserved[p] {
+			t.Errorf("Allocated reserved port %d", p)
+		}
+		if seen[p] {
+			t.Errorf("Port %d allocated twice", p)
+		}
+		seen[p] = true
+	}
+}
+
+func TestPortAvailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	if PortAvailable(port) {
+		t.Errorf("Port %d is in use but reported available", port)
+	}
+}
@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrProbeUnsupported is returned by a probe that cannot run against the
+// container at all (for example a missing client binary). WaitUntilReady then
+// falls back to matching the container logs.
+var ErrProbeUnsupported = errors.New("readiness probe unsupported")
+
+// Probe checks whether a service is ready to serve requests. It is called
+// repeatedly until it returns nil or the probe timeout expires.
+type Probe func(ctx context.Context, containerID string) error
+
+// ProbeConfig controls how long and how often readiness probes run. The
+// delay between attempts starts at Backoff and doubles up to MaxBackoff.
+type ProbeConfig struct {
+	Timeout        time.Duration // Overall time to wait for readiness
+	AttemptTimeout time.Duration // Time limit for a single probe attempt
+	Backoff        time.Duration
+	MaxBackoff     time.Duration
+}
+
+func DefaultProbeConfig() ProbeConfig {
+	return ProbeConfig{
+		Timeout:        3 * time.Minute,
+		AttemptTimeout: 5 * time.Second,
+		Backoff:        time.Second,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// SetProbeConfig overrides the readiness probe settings for one role. Roles
+// without an override use DefaultProbeConfig.
+func (m *Manager) SetProbeConfig(role string, cfg ProbeConfig) {
+	if m.probes == nil {
+		m.probes = make(map[string]ProbeConfig)
+	}
+	m.probes[role] = cfg
+}
+
+func (m *Manager) probeConfig(role string) ProbeConfig {
+	if cfg, ok := m.probes[role]; ok {
+		return cfg
+	}
+	return DefaultProbeConfig()
+}
+
+// nextBackoff doubles the delay, capped at max.
+func nextBackoff(cur, max time.Duration) time.Duration {
+	cur *= 2
+	if cur > max {
+		return max
+	}
+	return cur
+}
+
+// postgresProbe runs pg_isready inside the container over TCP. The temporary
+// server the postgres image runs during initdb only listens on the unix
+// socket, so this does not report ready before the real server is up.
+func (m *Manager) postgresProbe() Probe {
+	return func(ctx context.Context, containerID string) error {
+		res, err := m.ExecCapture(ctx, containerID, []string{"pg_isready", "-q", "-h", "127.0.0.1", "-U", "bloodhound", "-d", "bloodhound"})
+		if err != nil {
+			return err
+		}
+		switch code := res.ExitCode; code {
+		case 0:
+			return nil
+		case 126, 127:
+			return fmt.Errorf("%w: pg_isready not available (exit %d)", ErrProbeUnsupported, code)
+		default:
+			return fmt.Errorf("pg_isready exit code %d", code)
+		}
+	}
+}
+
+// neo4jProbe checks the HTTP discovery endpoint and completes a Bolt version
+// handshake on the published ports.
+func neo4jProbe(httpPort, boltPort int) Probe {
+	return func(ctx context.Context, containerID string) error {
+		if err := httpProbe(ctx, fmt.Sprintf("http://127.0.0.1:%d/", httpPort)); err != nil {
+			return fmt.Errorf("neo4j http: %w", err)
+		}
+		if err := boltHandshake(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(boltPort))); err != nil {
+			return fmt.Errorf("neo4j bolt: %w", err)
+		}
+		return nil
+	}
+}
+
+// bloodhoundProbe queries the unauthenticated version endpoint.
+func bloodhoundProbe(port int) Probe {
+	return func(ctx context.Context, containerID string) error {
+		return httpProbe(ctx, fmt.Sprintf("http://127.0.0.1:%d/api/version", port))
+	}
+}
+
+func httpProbe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// boltMagic is the preamble every Bolt connection starts with.
+var boltMagic = []byte{0x60, 0x60, 0xB0, 0x17}
+
+// boltHandshake offers Bolt 5.0, 4.4, 4.0 and 3 and succeeds if the server
+// agrees on any of them.
+func boltHandshake(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var req bytes.Buffer
+	req.Write(boltMagic)
+	for _, v := range []uint32{0x00000005, 0x00000404, 0x00000004, 0x00000003} {
+		binary.Write(&req, binary.BigEndian, v)
+	}
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(resp) == 0 {
+		return fmt.Errorf("server rejected all offered protocol versions")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNextBackoff(t *testing.T) {
+	if got := nextBackoff(time.Second, 10*time.Second); got != 2*time.Second {
+		t.Errorf("Expected 2s, got %s", got)
+	}
+	if got := nextBackoff(8*time.Second, 10*time.Second); got != 10*time.Second {
+		t.Errorf("Expected backoff capped at 10s, got %s", got)
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	ready := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":{"server_version":"v8"}}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if err := httpProbe(ctx, srv.URL+"/api/version"); err == nil {
+		t.Errorf("Expected probe to fail while service is unavailable")
+	}
+	ready = true
+	if err := httpProbe(ctx, srv.URL+"/api/version"); err != nil {
+		t.Errorf("Expected probe to pass, got %v", err)
+	}
+}
+
+func TestBoltHandshake(t *testing.T) {
+	serve := func(reply []byte) string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			defer l.Close()
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			buf := make([]byte, 20)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return
+			}
+			conn.Write(reply)
+		}()
+		return l.Addr().String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := boltHandshake(ctx, serve([]byte{0, 0, 4, 4})); err != nil {
+		t.Errorf("Expected handshake to succeed, got %v", err)
+	}
+	if err := boltHandshake(ctx, serve([]byte{0, 0, 0, 0})); err == nil {
+		t.Errorf("Expected handshake to fail when no version is agreed")
+	}
+}
@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	GiB = int64(1) << 30
+	MiB = int64(1) << 20
+
+	// Neo4j recommends keeping the heap well below 31G so compressed
+	// pointers stay enabled; beyond 16G GC pauses hurt more than they help.
+	MAX_AUTO_HEAP = 16 * GiB
+	MIN_AUTO_HEAP = 1 * GiB
+	MIN_PAGECACHE = 512 * MiB
+	// AUTO_RESERVE is kept free for the OS, Postgres and BloodHound.
+	AUTO_RESERVE = 2 * GiB
+)
+
+// Limits caps a container's resources. Zero values mean unlimited.
+type Limits struct {
+	CPUs   float64
+	Memory int64 // Bytes
+}
+
+// Neo4jMemory is the Neo4j memory configuration. An empty PageCache leaves
+// Neo4j's own default (half of the remaining RAM).
+type Neo4jMemory struct {
+	Heap      string
+	PageCache string
+}
+
+// AutoNeo4jMemory sizes the Neo4j heap and page cache from the memory
+// available to containers and the size of the graph store. The page cache
+// gets room for the whole store plus growth, the heap gets the rest up to
+// MAX_AUTO_HEAP.
+func AutoNeo4jMemory(hostMem, graphBytes int64) Neo4jMemory {
+	budget := hostMem*7/10 - AUTO_RESERVE
+	if budget < MIN_AUTO_HEAP+MIN_PAGECACHE {
+		budget = MIN_AUTO_HEAP + MIN_PAGECACHE
+	}
+
+	want := max(graphBytes, 0)*12/10 + MIN_PAGECACHE
+	pageCache := min(want, budget/2)
+	heap := min(max(budget-pageCache, MIN_AUTO_HEAP), MAX_AUTO_HEAP)
+	// A capped heap frees memory a big store can still use
+	pageCache = max(pageCache, min(want, budget-heap))
+
+	return Neo4jMemory{Heap: FormatJVMSize(heap), PageCache: FormatJVMSize(pageCache)}
+}
+
+// ParseSize parses JVM-style sizes such as 512M, 2G or 2g (bytes without a
+// suffix).
+func ParseSize(s string) (int64, error) {
+	v := strings.TrimSuffix(strings.TrimSpace(strings.ToUpper(s)), "B")
+	mult := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = MiB
+		case 'G':
+			mult = GiB
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// FormatJVMSize formats bytes the way Neo4j settings expect, rounded down
+// to whole megabytes.
+func FormatJVMSize(n int64) string {
+	if n%GiB == 0 {
+		return fmt.Sprintf("%dG", n/GiB)
+	}
+	return fmt.Sprintf("%dM", n/MiB)
+}
@@ -0,0 +1,59 @@
+package docker
+
+import "testing"
+
+func TestAutoNeo4jMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		host, graph int64
+		heap, cache string
+	}{
+		{"small host", 4 * GiB, 0, "1G", "512M"},
+		{"laptop", 16 * GiB, 2 * GiB, "6451M", "2969M"},
+		{"big forest", 64 * GiB, 40 * GiB, "16G", "27443M"},
+	}
+	for _, tt := range tests {
+		got := AutoNeo4jMemory(tt.host, tt.graph)
+		if got.Heap != tt.heap || got.PageCache != tt.cache {
+			t.Errorf("%s: AutoNeo4jMemory = %+v, want heap %s page cache %s", tt.name, got, tt.heap, tt.cache)
+		}
+	}
+
+	// Whatever the inputs, the result must fit the budget and parse back
+	for _, host := range []int64{GiB, 8 * GiB, 128 * GiB} {
+		for _, graph := range []int64{0, GiB, 500 * GiB} {
+			m := AutoNeo4jMemory(host, graph)
+			heap, err1 := ParseSize(m.Heap)
+			cache, err2 := ParseSize(m.PageCache)
+			if err1 != nil || err2 != nil || heap < MIN_AUTO_HEAP || heap > MAX_AUTO_HEAP || cache < MIN_PAGECACHE {
+				t.Errorf("AutoNeo4jMemory(%d, %d) = %+v", host, graph, m)
+			}
+			if host >= 8*GiB && heap+cache > host {
+				t.Errorf("AutoNeo4jMemory(%d, %d) = %+v exceeds host memory", host, graph, m)
+			}
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"2G": 2 * GiB, "2g": 2 * GiB, "512M": 512 * MiB, "1024": 1024, "1GB": GiB}
+	for in, want := range tests {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "G", "-1G", "lots", "auto"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) accepted", in)
+		}
+	}
+}
+
+func TestFormatJVMSize(t *testing.T) {
+	if got := FormatJVMSize(4 * GiB); got != "4G" {
+		t.Errorf("FormatJVMSize(4GiB) = %s", got)
+	}
+	if got := FormatJVMSize(1536 * MiB); got != "1536M" {
+		t.Errorf("FormatJVMSize(1.5GiB) = %s", got)
+	}
+}
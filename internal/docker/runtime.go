@@ -0,0 +1,235 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/remote"
+)
+
+const (
+	RUNTIME_DOCKER = "docker"
+	RUNTIME_PODMAN = "podman"
+)
+
+// ErrNotFound is returned (possibly wrapped) by a Runtime when the requested
+// container, image or network does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Runtime is the container engine the Manager drives. It only covers the
+// operations SiloHound needs, expressed in engine-neutral types, so Docker,
+// Podman and in-memory fakes can be swapped freely.
+type Runtime interface {
+	Close() error
+	// Info reports the engine's version and the resources of the host it
+	// runs containers on.
+	Info(ctx context.Context) (EngineInfo, error)
+
+	NetworkList(ctx context.Context, labels map[string]string) ([]NetworkInfo, error)
+	NetworkCreate(ctx context.Context, name string, labels map[string]string) error
+	NetworkRemove(ctx context.Context, id string) error
+
+	VolumeList(ctx context.Context, labels map[string]string) ([]VolumeInfo, error)
+	VolumeCreate(ctx context.Context, name string, labels map[string]string) error
+	VolumeRemove(ctx context.Context, name string) error
+
+	ImagePull(ctx context.Context, ref string, progress io.Writer) error
+	ImageInspect(ctx context.Context, ref string) (ImageInfo, error)
+	ImageTag(ctx context.Context, source, target string) error
+	ImageSave(ctx context.Context, ref string, w io.Writer) error
+	ImageLoad(ctx context.Context, r io.Reader, progress io.Writer) error
+
+	ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error)
+	ContainerStart(ctx context.Context, id string) error
+	ContainerStop(ctx context.Context, id string, timeout time.Duration) error
+	ContainerRemove(ctx context.Context, id string) error
+	// ContainerList returns containers carrying all the given labels. When all
+	// is false only running containers are returned.
+	ContainerList(ctx context.Context, all bool, labels map[string]string) ([]ContainerInfo, error)
+	// ContainerListByName returns containers with any of the given names.
+	// Engines match names as patterns, so callers check the names exactly.
+	ContainerListByName(ctx context.Context, all bool, names []string) ([]ContainerInfo, error)
+	ContainerInspect(ctx context.Context, id string) (ContainerInfo, error)
+	// ContainerWait blocks until the container stops and returns its exit code.
+	ContainerWait(ctx context.Context, id string) (int, error)
+	// ContainerLogs writes the container's stdout and stderr to the given
+	// writers, following the log if opts.Follow is set.
+	ContainerLogs(ctx context.Context, id string, opts LogOptions, stdout, stderr io.Writer) error
+	// Exec runs cmd in a running container with env (KEY=value) added to its
+	// environment, which keeps secrets out of the command line.
+	Exec(ctx context.Context, id string, cmd, env []string) (*ExecResult, error)
+
+	// Events streams events of containers carrying all the given labels until
+	// ctx is cancelled. The error channel receives at most one error, after
+	// which the event channel is closed.
+	Events(ctx context.Context, labels map[string]string) (<-chan Event, <-chan error)
+}
+
+type EngineInfo struct {
+	Name          string // docker or podman
+	Version       string
+	APIVersion    string // API version the client negotiated or the engine serves
+	MinAPIVersion string
+	OS            string
+	CPUs          int
+	MemTotal      int64 // Bytes
+}
+
+type NetworkInfo struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+type VolumeInfo struct {
+	Name   string
+	Labels map[string]string
+}
+
+type ImageInfo struct {
+	ID          string
+	RepoTags    []string
+	RepoDigests []string
+}
+
+type Mount struct {
+	Source   string // Host path, or volume name if Volume is set
+	Target   string // Path inside the container
+	Volume   bool
+	ReadOnly bool
+}
+
+type PortBinding struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+}
+
+// ContainerSpec describes a container to create.
+type ContainerSpec struct {
+	Name       string
+	Image      string
+	Env        []string
+	Cmd        []string
+	User       string
+	Labels     map[string]string
+	Mounts     []Mount
+	Ports      []PortBinding
+	Network    string
+	Aliases    []string
+	AutoRemove bool
+	Limits     Limits
+}
+
+// ContainerInfo is what SiloHound needs to know about a container. Lists may
+// leave fields that are only available from inspect (Health, StartedAt,
+// ExitCode, Error) empty.
+type ContainerInfo struct {
+	ID        string
+	Name      string
+	Image     string // Image reference the container was created from
+	ImageID   string
+	Labels    map[string]string
+	State     string // created, running, exited, ...
+	Running   bool
+	ExitCode  int
+	Error     string
+	Health    string // healthy, unhealthy, starting or empty without a healthcheck
+	Created   time.Time
+	StartedAt time.Time
+	Ports     []PortBinding
+}
+
+// Container event actions the supervisor acts on.
+const (
+	EVENT_START = "start"
+	EVENT_KILL  = "kill"
+	EVENT_STOP  = "stop"
+	EVENT_DIE   = "die"
+	EVENT_OOM   = "oom"
+)
+
+type Event struct {
+	Action      string
+	ContainerID string
+	Name        string
+	Labels      map[string]string // Only io.silohound.* labels
+	ExitCode    int               // Set for die events
+	Time        time.Time
+}
+
+// newEvent builds an Event from the actor attributes both engines send.
+func newEvent(action, id string, attrs map[string]string, t time.Time) Event {
+	ev := Event{Action: action, ContainerID: id, Name: attrs["name"], Labels: map[string]string{}, Time: t}
+	if ev.Action == "died" { // Podman's name for die
+		ev.Action = EVENT_DIE
+	}
+	for k, v := range attrs {
+		if strings.HasPrefix(k, LABEL_PREFIX) {
+			ev.Labels[k] = v
+		}
+	}
+	for _, key := range []string{"exitCode", "containerExitCode"} {
+		if code, err := strconv.Atoi(attrs[key]); err == nil {
+			ev.ExitCode = code
+		}
+	}
+	return ev
+}
+
+type LogOptions struct {
+	Tail       string // Number of lines, or "all"
+	Since      string // Timestamp or relative duration (e.g. 10m)
+	Follow     bool
+	Timestamps bool
+}
+
+// NewRuntime connects to the named container engine. An empty name selects
+// $SILOHOUND_RUNTIME, falling back to Docker. For Podman, socket overrides
+// the libpod socket location. A non-empty sshHost reaches the Docker engine
+// on that host over SSH instead.
+func NewRuntime(name, socket, sshHost string) (Runtime, error) {
+	if name == "" {
+		name = os.Getenv("SILOHOUND_RUNTIME")
+	}
+	if sshHost != "" {
+		if name != "" && name != RUNTIME_DOCKER {
+			return nil, fmt.Errorf("remote hosts are only supported with the %s runtime", RUNTIME_DOCKER)
+		}
+		target, err := remote.ParseTarget(sshHost)
+		if err != nil {
+			return nil, err
+		}
+		return NewSSHDockerRuntime(target)
+	}
+	switch name {
+	case "", RUNTIME_DOCKER:
+		return NewDockerRuntime()
+	case RUNTIME_PODMAN:
+		return NewPodmanRuntime(socket)
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (expected %s or %s)", name, RUNTIME_DOCKER, RUNTIME_PODMAN)
+	}
+}
+
+func matchLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// namePattern is the name filter matching exactly one container name. Docker
+// reports names with a leading slash, Podman without.
+func namePattern(name string) string {
+	return "^/?" + regexp.QuoteMeta(name) + "$"
+}
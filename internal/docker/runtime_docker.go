@@ -0,0 +1,472 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/remote"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+// DockerRuntime implements Runtime with the Docker Engine SDK.
+type DockerRuntime struct {
+	cli *client.Client
+}
+
+// NewDockerRuntime connects using the standard DOCKER_HOST/DOCKER_* environment.
+func NewDockerRuntime() (*DockerRuntime, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return &DockerRuntime{cli: cli}, nil
+}
+
+// NewSSHDockerRuntime connects to the Docker engine on target by running
+// `docker system dial-stdio` there over SSH, the same way the docker CLI
+// handles ssh:// hosts.
+func NewSSHDockerRuntime(target remote.Target) (*DockerRuntime, error) {
+	cli, err := client.NewClientWithOpts(
+		// The host name is never resolved; every connection is dialed over SSH
+		client.WithHost("http://docker.example.com"),
+		client.WithDialContext(func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return target.DialDocker(ctx)
+		}),
+		client.WithAPIVersionNegotiation(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerRuntime{cli: cli}, nil
+}
+
+func (d *DockerRuntime) Close() error {
+	return d.cli.Close()
+}
+
+func labelFilter(labels map[string]string) filters.Args {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	return args
+}
+
+func dockerErr(err error) error {
+	if err != nil && client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
+
+func (d *DockerRuntime) Info(ctx context.Context) (EngineInfo, error) {
+	version, err := d.cli.ServerVersion(ctx)
+	if err != nil {
+		return EngineInfo{}, err
+	}
+	info, err := d.cli.Info(ctx)
+	if err != nil {
+		return EngineInfo{}, err
+	}
+	return EngineInfo{
+		Name:          RUNTIME_DOCKER,
+		Version:       version.Version,
+		APIVersion:    d.cli.ClientVersion(),
+		MinAPIVersion: version.MinAPIVersion,
+		OS:            info.OperatingSystem,
+		CPUs:          info.NCPU,
+		MemTotal:      info.MemTotal,
+	}, nil
+}
+
+func (d *DockerRuntime) NetworkList(ctx context.Context, labels map[string]string) ([]NetworkInfo, error) {
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{Filters: labelFilter(labels)})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]NetworkInfo, 0, len(networks))
+	for _, n := range networks {
+		out = append(out, NetworkInfo{ID: n.ID, Name: n.Name, Labels: n.Labels})
+	}
+	return out, nil
+}
+
+func (d *DockerRuntime) NetworkCreate(ctx context.Context, name string, labels map[string]string) error {
+	_, err := d.cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver: "bridge",
+		Labels: labels,
+	})
+	return err
+}
+
+func (d *DockerRuntime) NetworkRemove(ctx context.Context, id string) error {
+	return dockerErr(d.cli.NetworkRemove(ctx, id))
+}
+
+func (d *DockerRuntime) VolumeList(ctx context.Context, labels map[string]string) ([]VolumeInfo, error) {
+	resp, err := d.cli.VolumeList(ctx, volume.ListOptions{Filters: labelFilter(labels)})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]VolumeInfo, 0, len(resp.Volumes))
+	for _, v := range resp.Volumes {
+		out = append(out, VolumeInfo{Name: v.Name, Labels: v.Labels})
+	}
+	return out, nil
+}
+
+func (d *DockerRuntime) VolumeCreate(ctx context.Context, name string, labels map[string]string) error {
+	_, err := d.cli.VolumeCreate(ctx, volume.CreateOptions{Name: name, Labels: labels})
+	return err
+}
+
+func (d *DockerRuntime) VolumeRemove(ctx context.Context, name string) error {
+	return dockerErr(d.cli.VolumeRemove(ctx, name, false))
+}
+
+func (d *DockerRuntime) ImagePull(ctx context.Context, ref string, progress io.Writer) error {
+	reader, err := d.cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return dockerErr(err)
+	}
+	defer reader.Close()
+	return copyJSONStream(reader, progress)
+}
+
+func (d *DockerRuntime) ImageInspect(ctx context.Context, ref string) (ImageInfo, error) {
+	inspect, err := d.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return ImageInfo{}, dockerErr(err)
+	}
+	return ImageInfo{ID: inspect.ID, RepoTags: inspect.RepoTags, RepoDigests: inspect.RepoDigests}, nil
+}
+
+func (d *DockerRuntime) ImageTag(ctx context.Context, source, target string) error {
+	return dockerErr(d.cli.ImageTag(ctx, source, target))
+}
+
+func (d *DockerRuntime) ImageSave(ctx context.Context, ref string, w io.Writer) error {
+	reader, err := d.cli.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return dockerErr(err)
+	}
+	defer reader.Close()
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+func (d *DockerRuntime) ImageLoad(ctx context.Context, r io.Reader, progress io.Writer) error {
+	resp, err := d.cli.ImageLoad(ctx, r, client.ImageLoadWithQuiet(progress == io.Discard))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !resp.JSON {
+		_, err = io.Copy(progress, resp.Body)
+		return err
+	}
+	return copyJSONStream(resp.Body, progress)
+}
+
+func (d *DockerRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	config := &container.Config{
+		Image:        spec.Image,
+		Env:          spec.Env,
+		Cmd:          spec.Cmd,
+		User:         spec.User,
+		Labels:       spec.Labels,
+		ExposedPorts: nat.PortSet{},
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{},
+		AutoRemove:   spec.AutoRemove,
+		Resources: container.Resources{
+			NanoCPUs: int64(spec.Limits.CPUs * 1e9),
+			Memory:   spec.Limits.Memory,
+		},
+	}
+	for _, m := range spec.Mounts {
+		typ := mount.TypeBind
+		if m.Volume {
+			typ = mount.TypeVolume
+		}
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     typ,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	for _, p := range spec.Ports {
+		port := nat.Port(fmt.Sprintf("%d/tcp", p.ContainerPort))
+		config.ExposedPorts[port] = struct{}{}
+		hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], nat.PortBinding{
+			HostIP:   p.HostIP,
+			HostPort: strconv.Itoa(p.HostPort),
+		})
+	}
+
+	var netConfig *network.NetworkingConfig
+	if spec.Network != "" {
+		netConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				spec.Network: {Aliases: spec.Aliases},
+			},
+		}
+	}
+
+	resp, err := d.cli.ContainerCreate(ctx, config, hostConfig, netConfig, nil, spec.Name)
+	if err != nil {
+		return "", dockerErr(err)
+	}
+	return resp.ID, nil
+}
+
+func (d *DockerRuntime) ContainerStart(ctx context.Context, id string) error {
+	return dockerErr(d.cli.ContainerStart(ctx, id, container.StartOptions{}))
+}
+
+func (d *DockerRuntime) ContainerStop(ctx context.Context, id string, timeout time.Duration) error {
+	secs := int(timeout.Seconds())
+	return dockerErr(d.cli.ContainerStop(ctx, id, container.StopOptions{Timeout: &secs}))
+}
+
+func (d *DockerRuntime) ContainerRemove(ctx context.Context, id string) error {
+	return dockerErr(d.cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}))
+}
+
+func (d *DockerRuntime) ContainerList(ctx context.Context, all bool, labels map[string]string) ([]ContainerInfo, error) {
+	return d.listContainers(ctx, all, labelFilter(labels))
+}
+
+func (d *DockerRuntime) ContainerListByName(ctx context.Context, all bool, names []string) ([]ContainerInfo, error) {
+	args := filters.NewArgs()
+	for _, n := range names {
+		args.Add("name", namePattern(n))
+	}
+	return d.listContainers(ctx, all, args)
+}
+
+func (d *DockerRuntime) listContainers(ctx context.Context, all bool, args filters.Args) ([]ContainerInfo, error) {
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{All: all, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		info := ContainerInfo{
+			ID:      c.ID,
+			Image:   c.Image,
+			ImageID: c.ImageID,
+			Labels:  c.Labels,
+			State:   string(c.State),
+			Running: c.State == container.StateRunning,
+			Created: time.Unix(c.Created, 0),
+		}
+		if len(c.Names) > 0 {
+			info.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort == 0 {
+				continue
+			}
+			info.Ports = append(info.Ports, PortBinding{HostIP: p.IP, HostPort: int(p.PublicPort), ContainerPort: int(p.PrivatePort)})
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (d *DockerRuntime) ContainerInspect(ctx context.Context, id string) (ContainerInfo, error) {
+	inspect, err := d.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return ContainerInfo{}, dockerErr(err)
+	}
+
+	info := ContainerInfo{
+		ID:      inspect.ID,
+		Name:    strings.TrimPrefix(inspect.Name, "/"),
+		ImageID: inspect.Image,
+	}
+	info.Created, _ = time.Parse(time.RFC3339Nano, inspect.Created)
+	if inspect.Config != nil {
+		info.Image = inspect.Config.Image
+		info.Labels = inspect.Config.Labels
+	}
+	if inspect.State != nil {
+		info.State = string(inspect.State.Status)
+		info.Running = inspect.State.Running
+		info.ExitCode = inspect.State.ExitCode
+		info.Error = inspect.State.Error
+		info.StartedAt, _ = time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+		if inspect.State.Health != nil {
+			info.Health = string(inspect.State.Health.Status)
+		}
+	}
+	if inspect.NetworkSettings != nil {
+		for port, bindings := range inspect.NetworkSettings.Ports {
+			for _, b := range bindings {
+				hostPort, _ := strconv.Atoi(b.HostPort)
+				info.Ports = append(info.Ports, PortBinding{HostIP: b.HostIP, HostPort: hostPort, ContainerPort: port.Int()})
+			}
+		}
+	}
+	return info, nil
+}
+
+func (d *DockerRuntime) ContainerWait(ctx context.Context, id string) (int, error) {
+	statusCh, errCh := d.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return -1, dockerErr(err)
+	case status := <-statusCh:
+		return int(status.StatusCode), nil
+	}
+}
+
+func (d *DockerRuntime) ContainerLogs(ctx context.Context, id string, opts LogOptions, stdout, stderr io.Writer) error {
+	out, err := d.cli.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Follow:     opts.Follow,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return dockerErr(err)
+	}
+	defer out.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, out)
+	return err
+}
+
+func (d *DockerRuntime) Exec(ctx context.Context, id string, cmd, env []string) (*ExecResult, error) {
+	resp, err := d.cli.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          cmd,
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, dockerErr(err)
+	}
+
+	attach, err := d.cli.ContainerExecAttach(ctx, resp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer attach.Close()
+
+	stdout, stderr, err := copyStreams(ctx, attach.Reader, attach.Close)
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := d.cli.ContainerExecInspect(ctx, resp.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecResult{Stdout: stdout, Stderr: stderr, ExitCode: inspect.ExitCode}, nil
+}
+
+// copyStreams demultiplexes an attached stdout/stderr stream until EOF. If ctx
+// is cancelled first, abort is called to unblock the copy.
+func copyStreams(ctx context.Context, r io.Reader, abort func()) (string, string, error) {
+	var stdout, stderr strings.Builder
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, r)
+		done <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		abort()
+		<-done
+		return "", "", ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read exec output: %w", err)
+		}
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// copyJSONStream relays a JSON progress stream (pull, load) to w and turns an
+// embedded error message into an error.
+func copyJSONStream(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg struct {
+			Stream   string `json:"stream"`
+			Status   string `json:"status"`
+			Progress string `json:"progress"`
+			ID       string `json:"id"`
+			Error    string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+		switch {
+		case msg.Stream != "":
+			fmt.Fprint(w, msg.Stream)
+		case msg.Status != "":
+			if msg.ID != "" {
+				fmt.Fprintf(w, "%s: ", msg.ID)
+			}
+			fmt.Fprintf(w, "%s %s\n", msg.Status, msg.Progress)
+		}
+	}
+}
+
+func (d *DockerRuntime) Events(ctx context.Context, labels map[string]string) (<-chan Event, <-chan error) {
+	args := labelFilter(labels)
+	args.Add("type", string(events.ContainerEventType))
+	msgs, errs := d.cli.Events(ctx, events.ListOptions{Filters: args})
+
+	out := make(chan Event)
+	outErr := make(chan error, 1)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case m := <-msgs:
+				ev := newEvent(string(m.Action), m.Actor.ID, m.Actor.Attributes, time.Unix(0, m.TimeNano))
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					outErr <- ctx.Err()
+					return
+				}
+			case err := <-errs:
+				outErr <- err
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
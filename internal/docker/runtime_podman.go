@@ -0,0 +1,644 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// PODMAN_API is the libpod REST API version prefix. v4 endpoints are served
+// by Podman 4 and 5.
+const PODMAN_API = "/v4.0.0/libpod"
+
+// PodmanRuntime implements Runtime against the Podman libpod REST API on a
+// unix socket (podman system service).
+type PodmanRuntime struct {
+	socket string
+	http   *http.Client
+}
+
+// NewPodmanRuntime connects to the given socket. An empty socket selects
+// $CONTAINER_HOST, then the rootless user socket, then the rootful one.
+func NewPodmanRuntime(socket string) (*PodmanRuntime, error) {
+	if socket == "" {
+		socket = defaultPodmanSocket()
+	}
+	socket = strings.TrimPrefix(socket, "unix://")
+	if _, err := os.Stat(socket); err != nil {
+		return nil, fmt.Errorf("podman socket %s not available (start it with 'systemctl --user enable --now podman.socket'): %w", socket, err)
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &PodmanRuntime{socket: socket, http: &http.Client{Transport: transport}}, nil
+}
+
+func defaultPodmanSocket() string {
+	if host := os.Getenv("CONTAINER_HOST"); strings.HasPrefix(host, "unix://") {
+		return host
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return sock
+		}
+	}
+	return "/run/podman/podman.sock"
+}
+
+func (p *PodmanRuntime) Close() error {
+	p.http.CloseIdleConnections()
+	return nil
+}
+
+// request performs an API call and returns the response if the status is
+// below 300. Any other status is turned into an error, wrapping ErrNotFound
+// for 404s.
+func (p *PodmanRuntime) request(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	u := "http://podman" + PODMAN_API + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	msg := resp.Status
+	if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+		msg = apiErr.Message
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, msg)
+	}
+	return nil, fmt.Errorf("podman %s %s: %s", method, path, msg)
+}
+
+// call sends an optional JSON body and decodes the JSON response into out
+// when out is non-nil.
+func (p *PodmanRuntime) call(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+		contentType = "application/json"
+	}
+
+	resp, err := p.request(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *PodmanRuntime) Info(ctx context.Context) (EngineInfo, error) {
+	var info struct {
+		Host struct {
+			CPUs     int   `json:"cpus"`
+			MemTotal int64 `json:"memTotal"`
+			Distro   struct {
+				Distribution string `json:"distribution"`
+				Version      string `json:"version"`
+			} `json:"distribution"`
+		} `json:"host"`
+		Version struct {
+			Version    string `json:"Version"`
+			APIVersion string `json:"APIVersion"`
+		} `json:"version"`
+	}
+	if err := p.call(ctx, "GET", "/info", nil, nil, &info); err != nil {
+		return EngineInfo{}, err
+	}
+	return EngineInfo{
+		Name:       RUNTIME_PODMAN,
+		Version:    info.Version.Version,
+		APIVersion: info.Version.APIVersion,
+		OS:         strings.TrimSpace(info.Host.Distro.Distribution + " " + info.Host.Distro.Version),
+		CPUs:       info.Host.CPUs,
+		MemTotal:   info.Host.MemTotal,
+	}, nil
+}
+
+func podmanLabelFilter(labels map[string]string) url.Values {
+	q := url.Values{}
+	if len(labels) == 0 {
+		return q
+	}
+	var list []string
+	for k, v := range labels {
+		list = append(list, fmt.Sprintf("%s=%s", k, v))
+	}
+	b, _ := json.Marshal(map[string][]string{"label": list})
+	q.Set("filters", string(b))
+	return q
+}
+
+func (p *PodmanRuntime) NetworkList(ctx context.Context, labels map[string]string) ([]NetworkInfo, error) {
+	var networks []struct {
+		ID     string            `json:"id"`
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := p.call(ctx, "GET", "/networks/json", podmanLabelFilter(labels), nil, &networks); err != nil {
+		return nil, err
+	}
+	out := make([]NetworkInfo, 0, len(networks))
+	for _, n := range networks {
+		out = append(out, NetworkInfo{ID: n.ID, Name: n.Name, Labels: n.Labels})
+	}
+	return out, nil
+}
+
+func (p *PodmanRuntime) NetworkCreate(ctx context.Context, name string, labels map[string]string) error {
+	body := map[string]interface{}{
+		"name":   name,
+		"driver": "bridge",
+		"labels": labels,
+	}
+	return p.call(ctx, "POST", "/networks/create", nil, body, nil)
+}
+
+func (p *PodmanRuntime) NetworkRemove(ctx context.Context, id string) error {
+	return p.call(ctx, "DELETE", "/networks/"+url.PathEscape(id), nil, nil, nil)
+}
+
+func (p *PodmanRuntime) VolumeList(ctx context.Context, labels map[string]string) ([]VolumeInfo, error) {
+	var volumes []struct {
+		Name   string            `json:"Name"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := p.call(ctx, "GET", "/volumes/json", podmanLabelFilter(labels), nil, &volumes); err != nil {
+		return nil, err
+	}
+	out := make([]VolumeInfo, 0, len(volumes))
+	for _, v := range volumes {
+		out = append(out, VolumeInfo{Name: v.Name, Labels: v.Labels})
+	}
+	return out, nil
+}
+
+func (p *PodmanRuntime) VolumeCreate(ctx context.Context, name string, labels map[string]string) error {
+	body := map[string]interface{}{
+		"Name":  name,
+		"Label": labels,
+	}
+	return p.call(ctx, "POST", "/volumes/create", nil, body, nil)
+}
+
+func (p *PodmanRuntime) VolumeRemove(ctx context.Context, name string) error {
+	return p.call(ctx, "DELETE", "/volumes/"+url.PathEscape(name), nil, nil, nil)
+}
+
+func (p *PodmanRuntime) ImagePull(ctx context.Context, ref string, progress io.Writer) error {
+	q := url.Values{"reference": {ref}}
+	resp, err := p.request(ctx, "POST", "/images/pull", q, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return copyJSONStream(resp.Body, progress)
+}
+
+func (p *PodmanRuntime) ImageInspect(ctx context.Context, ref string) (ImageInfo, error) {
+	var inspect struct {
+		ID          string   `json:"Id"`
+		RepoTags    []string `json:"RepoTags"`
+		RepoDigests []string `json:"RepoDigests"`
+	}
+	if err := p.call(ctx, "GET", "/images/"+url.PathEscape(ref)+"/json", nil, nil, &inspect); err != nil {
+		return ImageInfo{}, err
+	}
+	id := inspect.ID
+	if !strings.Contains(id, ":") {
+		id = "sha256:" + id
+	}
+	return ImageInfo{ID: id, RepoTags: inspect.RepoTags, RepoDigests: inspect.RepoDigests}, nil
+}
+
+func (p *PodmanRuntime) ImageTag(ctx context.Context, source, target string) error {
+	repo, tag := target, "latest"
+	if i := strings.LastIndex(target, ":"); i > strings.LastIndex(target, "/") {
+		repo, tag = target[:i], target[i+1:]
+	}
+	q := url.Values{"repo": {repo}, "tag": {tag}}
+	return p.call(ctx, "POST", "/images/"+url.PathEscape(source)+"/tag", q, nil, nil)
+}
+
+func (p *PodmanRuntime) ImageSave(ctx context.Context, ref string, w io.Writer) error {
+	q := url.Values{"format": {"docker-archive"}}
+	resp, err := p.request(ctx, "GET", "/images/"+url.PathEscape(ref)+"/get", q, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (p *PodmanRuntime) ImageLoad(ctx context.Context, r io.Reader, progress io.Writer) error {
+	resp, err := p.request(ctx, "POST", "/images/load", nil, r, "application/x-tar")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var loaded struct {
+		Names []string `json:"Names"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&loaded); err != nil {
+		return err
+	}
+	for _, name := range loaded.Names {
+		fmt.Fprintf(progress, "Loaded image: %s\n", name)
+	}
+	return nil
+}
+
+func (p *PodmanRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	env := make(map[string]string, len(spec.Env))
+	for _, kv := range spec.Env {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+
+	type podmanMount struct {
+		Destination string   `json:"destination"`
+		Source      string   `json:"source"`
+		Type        string   `json:"type"`
+		Options     []string `json:"options"`
+	}
+	type namedVolume struct {
+		Name string `json:"Name"`
+		Dest string `json:"Dest"`
+	}
+	type portMapping struct {
+		HostIP        string `json:"host_ip,omitempty"`
+		HostPort      int    `json:"host_port"`
+		ContainerPort int    `json:"container_port"`
+		Protocol      string `json:"protocol"`
+	}
+	type networkOpts struct {
+		Aliases []string `json:"aliases,omitempty"`
+	}
+	type memoryLimit struct {
+		Limit int64 `json:"limit"`
+	}
+	type cpuLimit struct {
+		Quota  int64  `json:"quota"`
+		Period uint64 `json:"period"`
+	}
+	type resourceLimits struct {
+		Memory *memoryLimit `json:"memory,omitempty"`
+		CPU    *cpuLimit    `json:"cpu,omitempty"`
+	}
+
+	body := struct {
+		Name         string                 `json:"name"`
+		Image        string                 `json:"image"`
+		Env          map[string]string      `json:"env,omitempty"`
+		Command      []string               `json:"command,omitempty"`
+		User         string                 `json:"user,omitempty"`
+		Labels       map[string]string      `json:"labels,omitempty"`
+		Mounts       []podmanMount          `json:"mounts,omitempty"`
+		Volumes      []namedVolume          `json:"volumes,omitempty"`
+		PortMappings []portMapping          `json:"portmappings,omitempty"`
+		Networks     map[string]networkOpts `json:"Networks,omitempty"`
+		Remove       bool                   `json:"remove,omitempty"`
+		Resources    *resourceLimits        `json:"resource_limits,omitempty"`
+	}{
+		Name:    spec.Name,
+		Image:   spec.Image,
+		Env:     env,
+		Command: spec.Cmd,
+		User:    spec.User,
+		Labels:  spec.Labels,
+		Remove:  spec.AutoRemove,
+	}
+	for _, m := range spec.Mounts {
+		if m.Volume {
+			body.Volumes = append(body.Volumes, namedVolume{Name: m.Source, Dest: m.Target})
+			continue
+		}
+		opts := []string{"rbind"}
+		if m.ReadOnly {
+			opts = append(opts, "ro")
+		}
+		body.Mounts = append(body.Mounts, podmanMount{Destination: m.Target, Source: m.Source, Type: "bind", Options: opts})
+	}
+	for _, pb := range spec.Ports {
+		body.PortMappings = append(body.PortMappings, portMapping{HostIP: pb.HostIP, HostPort: pb.HostPort, ContainerPort: pb.ContainerPort, Protocol: "tcp"})
+	}
+	if spec.Network != "" {
+		body.Networks = map[string]networkOpts{spec.Network: {Aliases: spec.Aliases}}
+	}
+	if spec.Limits != (Limits{}) {
+		body.Resources = &resourceLimits{}
+		if spec.Limits.Memory > 0 {
+			body.Resources.Memory = &memoryLimit{Limit: spec.Limits.Memory}
+		}
+		if spec.Limits.CPUs > 0 {
+			// Same CFS quota Docker derives from --cpus
+			body.Resources.CPU = &cpuLimit{Quota: int64(spec.Limits.CPUs * 100000), Period: 100000}
+		}
+	}
+
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := p.call(ctx, "POST", "/containers/create", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (p *PodmanRuntime) ContainerStart(ctx context.Context, id string) error {
+	return p.call(ctx, "POST", "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+}
+
+func (p *PodmanRuntime) ContainerStop(ctx context.Context, id string, timeout time.Duration) error {
+	q := url.Values{"timeout": {strconv.Itoa(int(timeout.Seconds()))}}
+	return p.call(ctx, "POST", "/containers/"+url.PathEscape(id)+"/stop", q, nil, nil)
+}
+
+func (p *PodmanRuntime) ContainerRemove(ctx context.Context, id string) error {
+	q := url.Values{"force": {"true"}}
+	return p.call(ctx, "DELETE", "/containers/"+url.PathEscape(id), q, nil, nil)
+}
+
+func (p *PodmanRuntime) ContainerList(ctx context.Context, all bool, labels map[string]string) ([]ContainerInfo, error) {
+	return p.listContainers(ctx, all, podmanLabelFilter(labels))
+}
+
+func (p *PodmanRuntime) ContainerListByName(ctx context.Context, all bool, names []string) ([]ContainerInfo, error) {
+	var patterns []string
+	for _, n := range names {
+		patterns = append(patterns, namePattern(n))
+	}
+	q := url.Values{}
+	b, _ := json.Marshal(map[string][]string{"name": patterns})
+	q.Set("filters", string(b))
+	return p.listContainers(ctx, all, q)
+}
+
+func (p *PodmanRuntime) listContainers(ctx context.Context, all bool, q url.Values) ([]ContainerInfo, error) {
+	q.Set("all", strconv.FormatBool(all))
+
+	var containers []struct {
+		ID       string            `json:"Id"`
+		Names    []string          `json:"Names"`
+		Image    string            `json:"Image"`
+		ImageID  string            `json:"ImageID"`
+		Labels   map[string]string `json:"Labels"`
+		State    string            `json:"State"`
+		ExitCode int               `json:"ExitCode"`
+		Created  time.Time         `json:"Created"`
+		Ports    []struct {
+			HostIP        string `json:"host_ip"`
+			HostPort      int    `json:"host_port"`
+			ContainerPort int    `json:"container_port"`
+		} `json:"Ports"`
+	}
+	if err := p.call(ctx, "GET", "/containers/json", q, nil, &containers); err != nil {
+		return nil, err
+	}
+
+	out := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		info := ContainerInfo{
+			ID:       c.ID,
+			Image:    c.Image,
+			ImageID:  c.ImageID,
+			Labels:   c.Labels,
+			State:    c.State,
+			Running:  c.State == "running",
+			ExitCode: c.ExitCode,
+			Created:  c.Created,
+		}
+		if len(c.Names) > 0 {
+			info.Name = c.Names[0]
+		}
+		for _, pb := range c.Ports {
+			info.Ports = append(info.Ports, PortBinding{HostIP: pb.HostIP, HostPort: pb.HostPort, ContainerPort: pb.ContainerPort})
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (p *PodmanRuntime) ContainerInspect(ctx context.Context, id string) (ContainerInfo, error) {
+	var inspect struct {
+		ID        string    `json:"Id"`
+		Name      string    `json:"Name"`
+		Created   time.Time `json:"Created"`
+		Image     string    `json:"Image"`
+		ImageName string    `json:"ImageName"`
+		Config    struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+		State struct {
+			Status    string    `json:"Status"`
+			Running   bool      `json:"Running"`
+			ExitCode  int       `json:"ExitCode"`
+			Error     string    `json:"Error"`
+			StartedAt time.Time `json:"StartedAt"`
+			Health    *struct {
+				Status string `json:"Status"`
+			} `json:"Health"`
+		} `json:"State"`
+		NetworkSettings struct {
+			Ports map[string][]struct {
+				HostIP   string `json:"HostIp"`
+				HostPort string `json:"HostPort"`
+			} `json:"Ports"`
+		} `json:"NetworkSettings"`
+	}
+	if err := p.call(ctx, "GET", "/containers/"+url.PathEscape(id)+"/json", nil, nil, &inspect); err != nil {
+		return ContainerInfo{}, err
+	}
+
+	info := ContainerInfo{
+		ID:        inspect.ID,
+		Name:      inspect.Name,
+		Image:     inspect.ImageName,
+		ImageID:   inspect.Image,
+		Labels:    inspect.Config.Labels,
+		State:     inspect.State.Status,
+		Running:   inspect.State.Running,
+		ExitCode:  inspect.State.ExitCode,
+		Error:     inspect.State.Error,
+		Created:   inspect.Created,
+		StartedAt: inspect.State.StartedAt,
+	}
+	if inspect.State.Health != nil {
+		info.Health = inspect.State.Health.Status
+	}
+	for port, bindings := range inspect.NetworkSettings.Ports {
+		containerPort, _ := strconv.Atoi(strings.SplitN(port, "/", 2)[0])
+		for _, b := range bindings {
+			hostPort, _ := strconv.Atoi(b.HostPort)
+			info.Ports = append(info.Ports, PortBinding{HostIP: b.HostIP, HostPort: hostPort, ContainerPort: containerPort})
+		}
+	}
+	return info, nil
+}
+
+func (p *PodmanRuntime) ContainerWait(ctx context.Context, id string) (int, error) {
+	q := url.Values{"condition": {"stopped"}}
+	var code int
+	if err := p.call(ctx, "POST", "/containers/"+url.PathEscape(id)+"/wait", q, nil, &code); err != nil {
+		return -1, err
+	}
+	return code, nil
+}
+
+func (p *PodmanRuntime) ContainerLogs(ctx context.Context, id string, opts LogOptions, stdout, stderr io.Writer) error {
+	q := url.Values{
+		"stdout":     {"true"},
+		"stderr":     {"true"},
+		"follow":     {strconv.FormatBool(opts.Follow)},
+		"timestamps": {strconv.FormatBool(opts.Timestamps)},
+	}
+	if opts.Tail != "" {
+		q.Set("tail", opts.Tail)
+	}
+	if opts.Since != "" {
+		q.Set("since", opts.Since)
+	}
+
+	resp, err := p.request(ctx, "GET", "/containers/"+url.PathEscape(id)+"/logs", q, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, resp.Body)
+	return err
+}
+
+func (p *PodmanRuntime) Exec(ctx context.Context, id string, cmd, env []string) (*ExecResult, error) {
+	create := map[string]interface{}{
+		"Cmd":          cmd,
+		"Env":          env,
+		"AttachStdout": true,
+		"AttachStderr": true,
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := p.call(ctx, "POST", "/containers/"+url.PathEscape(id)+"/exec", nil, create, &created); err != nil {
+		return nil, err
+	}
+
+	start, _ := json.Marshal(map[string]bool{"Detach": false, "Tty": false})
+	resp, err := p.request(ctx, "POST", "/exec/"+created.ID+"/start", nil, bytes.NewReader(start), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr, err := copyStreams(ctx, resp.Body, func() { resp.Body.Close() })
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := p.call(ctx, "GET", "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return nil, err
+	}
+	return &ExecResult{Stdout: stdout, Stderr: stderr, ExitCode: inspect.ExitCode}, nil
+}
+
+func (p *PodmanRuntime) Events(ctx context.Context, labels map[string]string) (<-chan Event, <-chan error) {
+	out := make(chan Event)
+	outErr := make(chan error, 1)
+
+	var list []string
+	for k, v := range labels {
+		list = append(list, fmt.Sprintf("%s=%s", k, v))
+	}
+	b, _ := json.Marshal(map[string][]string{"label": list, "type": {"container"}})
+	q := url.Values{"stream": {"true"}, "filters": {string(b)}}
+
+	go func() {
+		defer close(out)
+		resp, err := p.request(ctx, "GET", "/events", q, nil, "")
+		if err != nil {
+			outErr <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var m struct {
+				Action   string
+				Status   string `json:"status"`
+				TimeNano int64  `json:"timeNano"`
+				Actor    struct {
+					ID         string
+					Attributes map[string]string
+				}
+			}
+			if err := dec.Decode(&m); err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				outErr <- err
+				return
+			}
+			action := m.Action
+			if action == "" {
+				action = m.Status
+			}
+			select {
+			case out <- newEvent(action, m.Actor.ID, m.Actor.Attributes, time.Unix(0, m.TimeNano)):
+			case <-ctx.Done():
+				outErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
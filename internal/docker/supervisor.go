@@ -0,0 +1,277 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrGaveUp is reported for a role that kept crashing after MaxRestarts
+// restarts in a row.
+var ErrGaveUp = errors.New("too many consecutive crashes, not restarting")
+
+// Crash is an unexpected exit of a project container.
+type Crash struct {
+	Role      string
+	Container string
+	ExitCode  int
+	OOM       bool
+	Time      time.Time
+	Logs      string // The last lines the container logged
+	Attempts  int    // Restart attempts made for this crash
+	// RestartErr is nil if the role is running again.
+	RestartErr error
+}
+
+// SupervisorConfig controls Supervise. The delay before a restart starts at
+// Backoff and doubles with every consecutive crash of the role, up to
+// MaxBackoff.
+type SupervisorConfig struct {
+	// Respawn starts a fresh container for role.
+	Respawn func(role string) error
+	// OnCrash is called once the restart attempts for a crash are over.
+	OnCrash     func(Crash)
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	MaxRestarts int           // Consecutive restarts of a role before giving up on it
+	StableAfter time.Duration // Uptime after which a role's crash count resets
+	LogLines    int           // Log lines kept for the crash report
+}
+
+func DefaultSupervisorConfig() SupervisorConfig {
+	return SupervisorConfig{
+		Backoff:     5 * time.Second,
+		MaxBackoff:  2 * time.Minute,
+		MaxRestarts: 5,
+		StableAfter: 10 * time.Minute,
+		LogLines:    200,
+	}
+}
+
+// supervised is the current container of a role.
+type supervised struct {
+	id       string
+	started  time.Time
+	crashes  int  // Consecutive crashes
+	stopping bool // A stop or kill was requested, so its exit is expected
+	oom      bool
+	logs     *logRing
+	logsDone chan struct{}
+	cancel   context.CancelFunc
+}
+
+// Supervise watches the project's running service containers and restarts
+// any that exit without being stopped. It returns nil once every container
+// was stopped on purpose or the Manager's context ends, and an error if the
+// event stream fails or every role was given up on.
+func (m *Manager) Supervise(projectName string, cfg SupervisorConfig) error {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	// Subscribe before listing so no exit falls between the two
+	events, errs := m.rt.Events(ctx, projectFilter(projectName))
+	containers, err := m.InspectProject(projectName)
+	if err != nil {
+		return err
+	}
+
+	roles := map[string]*supervised{}
+	for _, role := range LogRoles {
+		if c, ok := containers[role]; ok && c.Running {
+			roles[role] = m.follow(ctx, c.ID, cfg.LogLines)
+		}
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("project %s is not running", projectName)
+	}
+	defer func() {
+		for _, r := range roles {
+			r.cancel()
+		}
+	}()
+	fmt.Printf("Supervising %d container(s) of project %s. Press Ctrl+C to stop supervising.\n", len(roles), projectName)
+
+	for {
+		var ev Event
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("event stream ended: %w", err)
+		case ev = <-events:
+		}
+
+		role := ev.Labels[LABEL_ROLE]
+		r, known := roles[role]
+		if !isServiceRole(role) {
+			continue
+		}
+		switch ev.Action {
+		case EVENT_START:
+			if known && r.id == ev.ContainerID {
+				continue
+			}
+			next := m.follow(ctx, ev.ContainerID, cfg.LogLines)
+			if known {
+				r.cancel()
+				next.crashes = r.crashes
+			}
+			roles[role] = next
+		case EVENT_KILL, EVENT_STOP:
+			if known && r.id == ev.ContainerID {
+				r.stopping = true
+			}
+		case EVENT_OOM:
+			if known && r.id == ev.ContainerID {
+				r.oom = true
+			}
+		case EVENT_DIE:
+			if !known || r.id != ev.ContainerID {
+				continue
+			}
+			if r.stopping {
+				r.cancel()
+				delete(roles, role)
+				if len(roles) == 0 {
+					fmt.Printf("All containers of project %s were stopped. Supervisor exiting.\n", projectName)
+					return nil
+				}
+				continue
+			}
+			if !m.restartCrashed(ctx, role, r, ev, cfg) {
+				delete(roles, role)
+				if len(roles) == 0 {
+					return fmt.Errorf("gave up restarting project %s", projectName)
+				}
+			}
+		}
+	}
+}
+
+// restartCrashed handles an unexpected exit of role: it reports the crash and
+// restarts the role with backoff. It returns false if the role was given up.
+func (m *Manager) restartCrashed(ctx context.Context, role string, r *supervised, ev Event, cfg SupervisorConfig) bool {
+	// The log stream ends with the container; give it a moment to drain
+	select {
+	case <-r.logsDone:
+	case <-time.After(2 * time.Second):
+	}
+	r.cancel()
+
+	if time.Since(r.started) > cfg.StableAfter {
+		r.crashes = 0
+	}
+	crash := Crash{
+		Role:      role,
+		Container: ev.Name,
+		ExitCode:  ev.ExitCode,
+		OOM:       r.oom,
+		Time:      ev.Time,
+		Logs:      r.logs.String(),
+	}
+	how := fmt.Sprintf("exited with code %d", ev.ExitCode)
+	if crash.OOM {
+		how = "was killed for running out of memory"
+	}
+	fmt.Printf("Container %s (%s) %s.\n", ev.Name, role, how)
+
+	defer func() {
+		if cfg.OnCrash != nil {
+			cfg.OnCrash(crash)
+		}
+	}()
+	for {
+		r.crashes++
+		if r.crashes > cfg.MaxRestarts {
+			crash.RestartErr = ErrGaveUp
+			fmt.Printf("Giving up on %s after %d consecutive restarts.\n", role, cfg.MaxRestarts)
+			return false
+		}
+		delay := cfg.Backoff << (r.crashes - 1)
+		if delay > cfg.MaxBackoff || delay <= 0 {
+			delay = cfg.MaxBackoff
+		}
+		fmt.Printf("Restarting %s in %s (attempt %d of %d)...\n", role, delay, r.crashes, cfg.MaxRestarts)
+		select {
+		case <-ctx.Done():
+			crash.RestartErr = ctx.Err()
+			return false
+		case <-time.After(delay):
+		}
+
+		crash.Attempts++
+		crash.RestartErr = cfg.Respawn(role)
+		if crash.RestartErr == nil {
+			fmt.Printf("Restarted %s.\n", role)
+			return true
+		}
+		fmt.Printf("Failed to restart %s: %v\n", role, crash.RestartErr)
+	}
+}
+
+// follow starts collecting the last lines of a container's log.
+func (m *Manager) follow(ctx context.Context, id string, lines int) *supervised {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &supervised{id: id, started: time.Now(), logs: newLogRing(lines), logsDone: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(r.logsDone)
+		m.rt.ContainerLogs(ctx, id, LogOptions{Tail: fmt.Sprintf("%d", lines), Follow: true, Timestamps: true}, r.logs, r.logs)
+	}()
+	return r
+}
+
+func isServiceRole(role string) bool {
+	for _, r := range LogRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// logRing keeps the last n lines written to it.
+type logRing struct {
+	mu      sync.Mutex
+	n       int
+	lines   []string
+	partial []byte
+}
+
+func newLogRing(n int) *logRing {
+	return &logRing{n: n}
+}
+
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.lines = append(l.lines, string(l.partial[:i]))
+		l.partial = l.partial[i+1:]
+	}
+	if over := len(l.lines) - l.n; over > 0 {
+		l.lines = append([]string(nil), l.lines[over:]...)
+	}
+	return len(p), nil
+}
+
+func (l *logRing) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := strings.Join(l.lines, "\n")
+	if len(l.lines) > 0 {
+		out += "\n"
+	}
+	return out + string(l.partial)
+}
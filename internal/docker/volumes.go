@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+const (
+	// STORAGE_BIND keeps Postgres and Neo4j data in bind-mounted folders
+	// under the project path.
+	STORAGE_BIND = "bind"
+	// STORAGE_VOLUME keeps them in project-labeled named volumes, so no
+	// container-owned files ever land in the project path.
+	STORAGE_VOLUME = "volume"
+
+	// Toolbox mount points. The project path is always at /data; in volume
+	// mode the volumes appear under /volumes with the same layout as the
+	// bind folders, so archive paths are identical in both modes.
+	TOOLBOX_PROJECT_ROOT = "/data"
+	TOOLBOX_VOLUME_ROOT  = "/volumes"
+)
+
+// dataFolders maps the roles that store data to their folder below the
+// project path (bind mode) or the toolbox data root.
+var dataFolders = map[string]string{
+	ROLE_POSTGRES: PSQLFOLDER,
+	ROLE_NEO4J:    NEO4JFOLDER,
+}
+
+// ValidStorage reports whether mode is a known storage mode.
+func ValidStorage(mode string) bool {
+	return mode == STORAGE_BIND || mode == STORAGE_VOLUME
+}
+
+// DataRoot is the directory inside a data toolbox that holds PSQLFOLDER and
+// NEO4JFOLDER for the given storage mode.
+func DataRoot(storage string) string {
+	if storage == STORAGE_VOLUME {
+		return TOOLBOX_VOLUME_ROOT
+	}
+	return TOOLBOX_PROJECT_ROOT
+}
+
+func volumeName(projectName, role string) string {
+	return fmt.Sprintf("SiloHound_%s_%s_Data", projectName, legacyNames[role])
+}
+
+// dataMount returns the mount that gives a service container its data
+// directory at target.
+func dataMount(projectName, wd, storage, role, target string) Mount {
+	if storage == STORAGE_VOLUME {
+		return Mount{Source: volumeName(projectName, role), Target: target, Volume: true}
+	}
+	return Mount{Source: filepath.Join(wd, dataFolders[role]), Target: target}
+}
+
+// EnsureVolumes creates the project's data volumes if they do not exist.
+func (m *Manager) EnsureVolumes(projectName, wd string) error {
+	existing, err := m.ProjectVolumes(projectName)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, v := range existing {
+		have[v.Name] = true
+	}
+	for _, role := range []string{ROLE_POSTGRES, ROLE_NEO4J} {
+		name := volumeName(projectName, role)
+		if have[name] {
+			continue
+		}
+		m.debugf("Creating volume %s", name)
+		if err := m.rt.VolumeCreate(m.ctx, name, projectLabels(projectName, role, wd)); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ProjectVolumes lists the data volumes labeled for projectName.
+func (m *Manager) ProjectVolumes(projectName string) ([]VolumeInfo, error) {
+	return m.rt.VolumeList(m.ctx, projectFilter(projectName))
+}
+
+// RemoveProjectVolumes deletes the project's data volumes. The project's
+// containers must be stopped first.
+func (m *Manager) RemoveProjectVolumes(projectName string) error {
+	volumes, err := m.ProjectVolumes(projectName)
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		if err := m.rt.VolumeRemove(m.ctx, v.Name); err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// RunDataToolbox is RunToolbox with the project's data reachable below
+// DataRoot(storage): the project path is mounted at /data and, in volume
+// mode, the data volumes at /volumes/PSQLFOLDER and /volumes/NEO4JFOLDER.
+func (m *Manager) RunDataToolbox(projectName, storage, hostPath string, cmd []string) (string, error) {
+	mounts := []Mount{{Source: hostPath, Target: TOOLBOX_PROJECT_ROOT}}
+	if storage == STORAGE_VOLUME {
+		for _, role := range []string{ROLE_POSTGRES, ROLE_NEO4J} {
+			mounts = append(mounts, Mount{
+				Source: volumeName(projectName, role),
+				Target: path.Join(TOOLBOX_VOLUME_ROOT, dataFolders[role]),
+				Volume: true,
+			})
+		}
+	}
+	return m.runToolbox(hostPath, mounts, cmd)
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package doctor
+
+import (
+	"errors"
+	"os"
+)
+
+func diskFree(path string) (int64, uint64, error) {
+	return 0, 0, errors.New("free space check not supported on this platform")
+}
+
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package doctor
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskFree returns the bytes available to unprivileged users on the
+// filesystem holding path, and an ID for that filesystem.
+func diskFree(path string) (int64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var dev uint64
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		dev = uint64(sys.Dev)
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), dev, nil
+}
+
+func fileOwner(info os.FileInfo) (int, bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(sys.Uid), true
+}
@@ -0,0 +1,360 @@
+// Package doctor runs preflight checks on the container engine, the host and
+// project data paths, and suggests fixes for the problems that usually make
+// a project fail to start.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+type Status string
+
+const (
+	STATUS_PASS Status = "PASS"
+	STATUS_WARN Status = "WARN"
+	STATUS_FAIL Status = "FAIL"
+)
+
+// MIN_API_VERSION is the oldest Docker Engine API (Docker 20.10) SiloHound
+// is tested against.
+const MIN_API_VERSION = "1.41"
+
+const (
+	GiB = docker.GiB
+
+	MIN_FREE_DISK  = 2 * GiB  // Below this Postgres and Neo4j fail to start
+	WARN_FREE_DISK = 10 * GiB // A large collection import can use this much
+	// STACK_OVERHEAD is the memory Postgres, BloodHound and Neo4j's page
+	// cache need on top of the Neo4j heap.
+	STACK_OVERHEAD = 2 * GiB
+)
+
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string // Suggested fix; empty when the check passed
+}
+
+type Report struct {
+	Checks []Check
+}
+
+func (r *Report) add(name string, status Status, fix, format string, args ...any) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+// Count returns the number of checks with the given status.
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "[%s]\t%s\t%s\n", c.Status, c.Name, c.Detail)
+	}
+	tw.Flush()
+
+	first := true
+	for _, c := range r.Checks {
+		if c.Fix == "" {
+			continue
+		}
+		if first {
+			fmt.Fprintln(w, "\nSuggested fixes:")
+			first = false
+		}
+		fmt.Fprintf(w, "  - %s: %s\n", c.Name, c.Fix)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warning(s), %d failed.\n", r.Count(STATUS_PASS), r.Count(STATUS_WARN), r.Count(STATUS_FAIL))
+}
+
+// Options select what to check.
+type Options struct {
+	Projects []database.Project // Projects whose ports, paths and images are checked
+	Heap     string             // Neo4j heap for projects without a stored one
+	Images   []string           // Images every project needs when not pinned
+	// EngineErr is reported when there is no Manager because connecting to
+	// the runtime already failed.
+	EngineErr error
+}
+
+// Run performs every check. It never stops early: a dead engine skips only
+// the checks that need it. mgr may be nil if opts.EngineErr is set.
+func Run(mgr *docker.Manager, opts Options) *Report {
+	r := &Report{}
+
+	var engine docker.EngineInfo
+	engineErr := opts.EngineErr
+	if mgr != nil {
+		engine, engineErr = mgr.EngineInfo()
+	}
+	checkEngine(r, engine, engineErr)
+	if engineErr == nil {
+		checkMemory(r, engine, opts)
+	}
+
+	running := map[string]bool{}
+	if engineErr == nil {
+		for _, p := range opts.Projects {
+			running[p.Name], _ = mgr.IsRunning(p.Name)
+		}
+	}
+	checkPorts(r, opts.Projects, running)
+
+	dirs := []string{}
+	for _, p := range opts.Projects {
+		dirs = append(dirs, p.Path)
+		checkDataPath(r, p)
+	}
+	if len(dirs) == 0 {
+		if wd, err := os.Getwd(); err == nil {
+			dirs = append(dirs, wd)
+		}
+	}
+	checkDisk(r, dirs)
+
+	if engineErr == nil {
+		checkImages(r, mgr, opts)
+	}
+	return r
+}
+
+func checkEngine(r *Report, info docker.EngineInfo, err error) {
+	if err != nil {
+		r.add("Container engine", STATUS_FAIL,
+			"Start the Docker daemon (or 'systemctl --user enable --now podman.socket' with -runtime podman), check DOCKER_HOST, and make sure your user may use the socket (e.g. member of the docker group).",
+			"unreachable: %v", err)
+		return
+	}
+	detail := fmt.Sprintf("%s %s, API %s", info.Name, info.Version, info.APIVersion)
+	if info.Name == docker.RUNTIME_DOCKER && compareVersions(info.APIVersion, MIN_API_VERSION) < 0 {
+		r.add("Container engine", STATUS_WARN, "Upgrade Docker to 20.10 or later.",
+			"%s is older than API %s", detail, MIN_API_VERSION)
+		return
+	}
+	r.add("Container engine", STATUS_PASS, "", "%s", detail)
+}
+
+func checkMemory(r *Report, info docker.EngineInfo, opts Options) {
+	heapSetting := opts.Heap
+	heap, err := docker.ParseSize(heapSetting)
+	if err != nil {
+		r.add("Memory", STATUS_FAIL, "Use a size like 2G, 4096M or 8G for -neo4j-heap.", "invalid heap %q: %v", opts.Heap, err)
+		return
+	}
+	// Only the largest stored heap matters; projects are started one at a time
+	for _, p := range opts.Projects {
+		if h, err := docker.ParseSize(p.Neo4jHeap); err == nil && h > heap {
+			heap, heapSetting = h, fmt.Sprintf("%s (project %s)", p.Neo4jHeap, p.Name)
+		}
+	}
+	if info.MemTotal <= 0 {
+		r.add("Memory", STATUS_WARN, "", "engine did not report its memory; Neo4j heap %s", heapSetting)
+		return
+	}
+
+	detail := fmt.Sprintf("%s available to containers, Neo4j heap %s", formatBytes(info.MemTotal), heapSetting)
+	switch {
+	case info.MemTotal < heap:
+		r.add("Memory", STATUS_FAIL, "Lower -neo4j-heap or give the container engine (or its VM) more memory.", "%s exceeds the memory", detail)
+	case info.MemTotal < heap+STACK_OVERHEAD:
+		r.add("Memory", STATUS_WARN, fmt.Sprintf("Leave about %s above the heap for Postgres, BloodHound and the page cache.", formatBytes(STACK_OVERHEAD)), "%s leaves little headroom", detail)
+	default:
+		r.add("Memory", STATUS_PASS, "", "%s", detail)
+	}
+}
+
+// checkPorts reports the project ports that something else is listening on.
+// Ports held by the project's own running containers are fine.
+func checkPorts(r *Report, projects []database.Project, running map[string]bool) {
+	if len(projects) == 0 {
+		checkPortSet(r, "Ports (defaults)", docker.DefaultPorts(), STATUS_WARN,
+			"SiloHound picks free ports automatically for new projects; pass -bh-port/-neo4j-http-port/-neo4j-bolt-port to choose them.")
+		return
+	}
+	for _, p := range projects {
+		name := fmt.Sprintf("Ports (%s)", p.Name)
+		ports := docker.Ports{BloodHound: p.BHPort, Neo4jHTTP: p.Neo4jHTTPPort, Neo4jBolt: p.Neo4jBoltPort}
+		switch {
+		case running[p.Name]:
+			r.add(name, STATUS_PASS, "", "in use by the running project")
+		case ports.IsZero():
+			checkPortSet(r, name, docker.DefaultPorts(), STATUS_WARN,
+				"Not a problem: free ports are allocated on first start.")
+		default:
+			checkPortSet(r, name, ports, STATUS_FAIL,
+				fmt.Sprintf("Stop whatever holds the port, or start with -name %s and -bh-port/-neo4j-http-port/-neo4j-bolt-port to reassign.", p.Name))
+		}
+	}
+}
+
+func checkPortSet(r *Report, name string, ports docker.Ports, busyStatus Status, fix string) {
+	var busy []string
+	for _, port := range []int{ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt} {
+		if !docker.PortAvailable(port) {
+			busy = append(busy, strconv.Itoa(port))
+		}
+	}
+	if len(busy) > 0 {
+		r.add(name, busyStatus, fix, "already in use: %s", strings.Join(busy, ", "))
+		return
+	}
+	r.add(name, STATUS_PASS, "", "%d, %d, %d free", ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt)
+}
+
+// checkDataPath makes sure the project directory and the folders SiloHound
+// manages in it belong to the current user and are writable. The Postgres and
+// Neo4j data folders themselves are owned by the container users by design.
+func checkDataPath(r *Report, p database.Project) {
+	name := fmt.Sprintf("Data path (%s)", p.Name)
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		r.add(name, STATUS_FAIL, fmt.Sprintf("Restore the directory, or point the project at its new location with -name %s -move <path>.", p.Name), "%v", err)
+		return
+	}
+	if !info.IsDir() {
+		r.add(name, STATUS_FAIL, "", "%s is not a directory", p.Path)
+		return
+	}
+
+	uid := os.Getuid()
+	for _, dir := range []string{p.Path, filepath.Join(p.Path, "bloodhound-data"), filepath.Join(p.Path, "BloodHoundQueryLibrary")} {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		if owner, ok := fileOwner(fi); ok && uid >= 0 && owner != uid {
+			r.add(name, STATUS_FAIL, fmt.Sprintf("sudo chown %d:%d %s", uid, os.Getgid(), dir),
+				"%s is owned by uid %d, not you (uid %d)", dir, owner, uid)
+			return
+		}
+	}
+
+	probe, err := os.CreateTemp(p.Path, ".silohound-doctor-")
+	if err != nil {
+		r.add(name, STATUS_FAIL, fmt.Sprintf("Make %s writable for your user.", p.Path), "not writable: %v", err)
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	r.add(name, STATUS_PASS, "", "%s owned by you and writable", p.Path)
+}
+
+// checkDisk reports free space once per filesystem.
+func checkDisk(r *Report, dirs []string) {
+	seen := map[uint64]bool{}
+	for _, dir := range dirs {
+		free, fsID, err := diskFree(dir)
+		if err != nil {
+			continue
+		}
+		if seen[fsID] {
+			continue
+		}
+		seen[fsID] = true
+
+		name := fmt.Sprintf("Disk (%s)", dir)
+		switch {
+		case free < MIN_FREE_DISK:
+			r.add(name, STATUS_FAIL, "Free up space, or -move the project to a larger disk.", "only %s free", formatBytes(free))
+		case free < WARN_FREE_DISK:
+			r.add(name, STATUS_WARN, "Large collections need several GiB during import; consider freeing space.", "%s free", formatBytes(free))
+		default:
+			r.add(name, STATUS_PASS, "", "%s free", formatBytes(free))
+		}
+	}
+}
+
+func checkImages(r *Report, mgr *docker.Manager, opts Options) {
+	refs := map[string]bool{}
+	var order []string
+	addRef := func(ref string) {
+		if ref != "" && !refs[ref] {
+			refs[ref] = true
+			order = append(order, ref)
+		}
+	}
+	for _, p := range opts.Projects {
+		if p.BHImage == "" {
+			for _, ref := range opts.Images {
+				addRef(ref)
+			}
+			continue
+		}
+		addRef(p.BHImage)
+		addRef(p.Neo4jImage)
+		addRef(p.PostgresImage)
+	}
+	if len(opts.Projects) == 0 {
+		for _, ref := range opts.Images {
+			addRef(ref)
+		}
+	}
+
+	var missing []string
+	for _, ref := range order {
+		exists, err := mgr.ImageExists(ref)
+		if err != nil {
+			r.add("Images", STATUS_WARN, "", "failed to inspect %s: %v", ref, err)
+			return
+		}
+		if !exists {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		r.add("Images", STATUS_WARN, "Missing images are pulled on start; on offline machines load them first with -import-images.",
+			"%d of %d not present locally: %s", len(missing), len(order), strings.Join(missing, ", "))
+		return
+	}
+	r.add("Images", STATUS_PASS, "", "all %d present", len(order))
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= GiB:
+		return fmt.Sprintf("%.1f GiB", float64(n)/float64(GiB))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.0f MiB", float64(n)/float64(1<<20))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// compareVersions compares dotted numeric versions like 1.41 and 1.47.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
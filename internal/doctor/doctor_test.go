@@ -0,0 +1,106 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+func find(t *testing.T, r *Report, prefix string) Check {
+	t.Helper()
+	for _, c := range r.Checks {
+		if strings.HasPrefix(c.Name, prefix) {
+			return c
+		}
+	}
+	t.Fatalf("no check %q in %+v", prefix, r.Checks)
+	return Check{}
+}
+
+func TestRun_EngineUnreachable(t *testing.T) {
+	rt := docker.NewFakeRuntime()
+	rt.InfoErr = errors.New("dial unix /var/run/docker.sock: connect: permission denied")
+	mgr := docker.NewManager(context.Background(), rt, false)
+
+	r := Run(mgr, Options{Heap: "2G"})
+	if c := find(t, r, "Container engine"); c.Status != STATUS_FAIL || c.Fix == "" {
+		t.Errorf("engine check = %+v, want FAIL with a fix", c)
+	}
+	for _, c := range r.Checks {
+		if c.Name == "Images" || c.Name == "Memory" {
+			t.Errorf("check %s ran without an engine", c.Name)
+		}
+	}
+}
+
+func TestRun_Memory(t *testing.T) {
+	rt := docker.NewFakeRuntime()
+	rt.Engine.MemTotal = 4 * GiB
+	mgr := docker.NewManager(context.Background(), rt, false)
+
+	if c := find(t, Run(mgr, Options{Heap: "1G"}), "Memory"); c.Status != STATUS_PASS {
+		t.Errorf("1G heap on 4GiB = %+v, want PASS", c)
+	}
+	if c := find(t, Run(mgr, Options{Heap: "3G"}), "Memory"); c.Status != STATUS_WARN {
+		t.Errorf("3G heap on 4GiB = %+v, want WARN", c)
+	}
+	if c := find(t, Run(mgr, Options{Heap: "8G"}), "Memory"); c.Status != STATUS_FAIL {
+		t.Errorf("8G heap on 4GiB = %+v, want FAIL", c)
+	}
+	// A project's stored heap counts even when the flag is smaller
+	projects := []database.Project{{Name: "Big", Path: t.TempDir(), Neo4jHeap: "16G"}}
+	if c := find(t, Run(mgr, Options{Heap: "1G", Projects: projects}), "Memory"); c.Status != STATUS_FAIL || !strings.Contains(c.Detail, "Big") {
+		t.Errorf("stored 16G heap = %+v, want FAIL naming the project", c)
+	}
+}
+
+func TestRun_Ports(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	busy := l.Addr().(*net.TCPAddr).Port
+
+	mgr := docker.NewManager(context.Background(), docker.NewFakeRuntime(), false)
+	projects := []database.Project{{Name: "Acme", Path: t.TempDir(), BHPort: busy, Neo4jHTTPPort: 1, Neo4jBoltPort: 2}}
+	c := find(t, Run(mgr, Options{Heap: "1G", Projects: projects}), "Ports (Acme)")
+	if c.Status != STATUS_FAIL || !strings.Contains(c.Detail, strconv.Itoa(busy)) {
+		t.Errorf("busy port check = %+v, want FAIL", c)
+	}
+}
+
+func TestRun_DataPathAndImages(t *testing.T) {
+	rt := docker.NewFakeRuntime()
+	rt.AddImage(docker.POSTGRESQL)
+	mgr := docker.NewManager(context.Background(), rt, false)
+
+	projects := []database.Project{
+		{Name: "Here", Path: t.TempDir()},
+		{Name: "Gone", Path: filepath.Join(t.TempDir(), "missing")},
+	}
+	r := Run(mgr, Options{Heap: "1G", Projects: projects, Images: []string{docker.POSTGRESQL, docker.NEO4J}})
+
+	if c := find(t, r, "Data path (Here)"); c.Status != STATUS_PASS {
+		t.Errorf("existing path = %+v, want PASS", c)
+	}
+	if c := find(t, r, "Data path (Gone)"); c.Status != STATUS_FAIL || !strings.Contains(c.Fix, "-move") {
+		t.Errorf("missing path = %+v, want FAIL suggesting -move", c)
+	}
+	if c := find(t, r, "Images"); c.Status != STATUS_WARN || !strings.Contains(c.Detail, docker.NEO4J) || strings.Contains(c.Detail, docker.POSTGRESQL) {
+		t.Errorf("images = %+v, want WARN listing only %s", c, docker.NEO4J)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	if compareVersions("1.40", MIN_API_VERSION) >= 0 || compareVersions("1.47", MIN_API_VERSION) <= 0 || compareVersions("1.41", "1.41") != 0 {
+		t.Error("compareVersions ordering wrong")
+	}
+}
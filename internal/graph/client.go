@@ -326,3 +326,51 @@ func (c *Client) GetGroupStats() ([]GroupStat, error) {
 	}
 	return stats, nil
 }
+
+// CountGraph returns the number of nodes and relationships in the database.
+func (c *Client) CountGraph() (nodes, edges int64, err error) {
+	reqBody := neoRequest{Statements: []statement{
+		{Statement: "MATCH (n) RETURN count(n)"},
+		{Statement: "MATCH ()-[r]->() RETURN count(r)"},
+	}}
+	b, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", c.url+"/db/neo4j/tx/commit", bytes.NewBuffer(b))
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, 0, fmt.Errorf("neo4j returned status %d", resp.StatusCode)
+	}
+
+	var resBody struct {
+		Results []struct {
+			Data []struct {
+				Row []float64 `json:"row"`
+			} `json:"data"`
+		} `json:"results"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&resBody); err != nil {
+		return 0, 0, err
+	}
+	if len(resBody.Errors) > 0 {
+		return 0, 0, fmt.Errorf("neo4j error: %s", resBody.Errors[0].Message)
+	}
+
+	counts := make([]int64, 2)
+	for i := range counts {
+		if i >= len(resBody.Results) || len(resBody.Results[i].Data) == 0 || len(resBody.Results[i].Data[0].Row) == 0 {
+			return 0, 0, fmt.Errorf("neo4j returned no count")
+		}
+		counts[i] = int64(resBody.Results[i].Data[0].Row[0])
+	}
+	return counts[0], counts[1], nil
+}
@@ -0,0 +1,299 @@
+package offline
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+const (
+	MANIFEST_NAME  = "manifest.json"
+	FORMAT_VERSION = 1
+)
+
+// ImageStore is the subset of docker.Manager needed to build and load
+// bundles.
+type ImageStore interface {
+	SaveImage(ref string, w io.Writer) error
+	LoadImages(r io.Reader) error
+	ImageID(ref string) (string, error)
+	TagImage(source, target string) error
+}
+
+// Manifest describes the contents of an offline image bundle.
+type Manifest struct {
+	FormatVersion    int          `json:"format_version"`
+	SiloHoundVersion string       `json:"silohound_version"`
+	CreatedAt        time.Time    `json:"created_at"`
+	Images           []ImageEntry `json:"images"`
+}
+
+type ImageEntry struct {
+	Reference string `json:"reference"` // Reference requested (tag or pinned digest)
+	SavedAs   string `json:"saved_as"`  // Tag stored in the archive
+	ID        string `json:"id"`        // Local image ID, verified after load
+	File      string `json:"file"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// ExportImages saves refs into a single gzipped tarball at path. The archive
+// starts with manifest.json followed by one `docker save` tar per image.
+// Digest references are saved under their docker.PinnedAlias tag so they can
+// be found again after loading.
+func ExportImages(store ImageStore, refs []string, path string) (*Manifest, error) {
+	tmpDir, err := os.MkdirTemp("", "silohound-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest := &Manifest{
+		FormatVersion:    FORMAT_VERSION,
+		SiloHoundVersion: docker.Version,
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		entry := ImageEntry{Reference: ref, SavedAs: ref}
+		if alias := docker.PinnedAlias(ref); alias != "" {
+			// The alias may already exist if this machine loaded a bundle itself
+			if err := store.TagImage(ref, alias); err != nil {
+				if _, aliasErr := store.ImageID(alias); aliasErr != nil {
+					return nil, fmt.Errorf("failed to tag %s: %w", ref, err)
+				}
+			}
+			entry.SavedAs = alias
+		}
+
+		if entry.ID, err = store.ImageID(entry.SavedAs); err != nil {
+			return nil, fmt.Errorf("image %s not found locally: %w", ref, err)
+		}
+
+		entry.File = fmt.Sprintf("images/%02d.tar", len(manifest.Images))
+		fmt.Printf("Saving %s...\n", ref)
+		if entry.Size, entry.SHA256, err = saveToFile(store, entry.SavedAs, filepath.Join(tmpDir, filepath.Base(entry.File))); err != nil {
+			return nil, fmt.Errorf("failed to save %s: %w", ref, err)
+		}
+		manifest.Images = append(manifest.Images, entry)
+	}
+
+	if err := writeBundle(path, manifest, tmpDir); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func saveToFile(store ImageStore, ref, dest string) (int64, string, error) {
+	f, err := os.Create(dest)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, h)}
+	if err := store.SaveImage(ref, cw); err != nil {
+		return 0, "", err
+	}
+	return cw.n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeBundle(path string, manifest *Manifest, srcDir string) error {
+	tmpPath := path + ".partial"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: MANIFEST_NAME, Mode: 0644, Size: int64(len(b)), ModTime: manifest.CreatedAt}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+
+	for _, img := range manifest.Images {
+		f, err := os.Open(filepath.Join(srcDir, filepath.Base(img.File)))
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: img.File, Mode: 0644, Size: img.Size, ModTime: manifest.CreatedAt})
+		if err == nil {
+			_, err = io.Copy(tw, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// ImportImages verifies every image in the bundle at path against the
+// manifest checksums and loads it into the daemon.
+func ImportImages(store ImageStore, path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a SiloHound image bundle: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]ImageEntry, len(manifest.Images))
+	for _, img := range manifest.Images {
+		entries[img.File] = img
+	}
+
+	tmpDir, err := os.MkdirTemp("", "silohound-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	loaded := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := entries[hdr.Name]
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("Verifying %s...\n", entry.Reference)
+		tmpFile := filepath.Join(tmpDir, filepath.Base(entry.File))
+		if err := verifyToFile(tr, tmpFile, entry); err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("Loading %s...\n", entry.Reference)
+		if err := loadFile(store, tmpFile); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", entry.Reference, err)
+		}
+		os.Remove(tmpFile)
+
+		id, err := store.ImageID(entry.SavedAs)
+		if err != nil {
+			return nil, fmt.Errorf("%s missing after load: %w", entry.SavedAs, err)
+		}
+		if id != entry.ID {
+			return nil, fmt.Errorf("%s loaded with ID %s, manifest expects %s", entry.SavedAs, id, entry.ID)
+		}
+		loaded++
+	}
+
+	if loaded != len(manifest.Images) {
+		return nil, fmt.Errorf("bundle is incomplete: loaded %d of %d images", loaded, len(manifest.Images))
+	}
+	return manifest, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if hdr.Name != MANIFEST_NAME {
+		return nil, fmt.Errorf("not a SiloHound image bundle: first entry is %s", hdr.Name)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.FormatVersion > FORMAT_VERSION {
+		return nil, fmt.Errorf("bundle format %d is newer than this SiloHound supports (%d)", manifest.FormatVersion, FORMAT_VERSION)
+	}
+	return &manifest, nil
+}
+
+func verifyToFile(r io.Reader, dest string, entry ImageEntry) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	if n != entry.Size {
+		return fmt.Errorf("%s: size mismatch (got %d, manifest says %d)", entry.File, n, entry.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != entry.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch (got %s, manifest says %s)", entry.File, sum, entry.SHA256)
+	}
+	return nil
+}
+
+func loadFile(store ImageStore, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.LoadImages(f)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
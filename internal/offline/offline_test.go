@@ -0,0 +1,133 @@
+package offline
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeStore keeps images as ref -> content. Saved archives are "ref\ncontent"
+// so loading restores the tag, like docker load does.
+type fakeStore struct {
+	images map[string]string
+}
+
+func (f *fakeStore) SaveImage(ref string, w io.Writer) error {
+	content, ok := f.images[ref]
+	if !ok {
+		return fmt.Errorf("no such image %s", ref)
+	}
+	_, err := fmt.Fprintf(w, "%s\n%s", ref, content)
+	return err
+}
+
+func (f *fakeStore) LoadImages(r io.Reader) error {
+	br := bufio.NewReader(r)
+	ref, err := br.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	f.images[strings.TrimSuffix(ref, "\n")] = string(content)
+	return nil
+}
+
+func (f *fakeStore) ImageID(ref string) (string, error) {
+	content, ok := f.images[ref]
+	if !ok {
+		return "", fmt.Errorf("no such image %s", ref)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func (f *fakeStore) TagImage(source, target string) error {
+	content, ok := f.images[source]
+	if !ok {
+		return fmt.Errorf("no such image %s", source)
+	}
+	f.images[target] = content
+	return nil
+}
+
+func TestExportImportImages(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "silohound_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pinned := "specterops/bloodhound@sha256:0123456789abcdef"
+	src := &fakeStore{images: map[string]string{
+		"postgres:16": "pg-layers",
+		"neo4j:4.4":   "neo4j-layers",
+		pinned:        "bh-layers",
+	}}
+
+	bundle := filepath.Join(tmpDir, "images.tar.gz")
+	manifest, err := ExportImages(src, []string{"postgres:16", "neo4j:4.4", pinned, "neo4j:4.4"}, bundle)
+	if err != nil {
+		t.Fatalf("ExportImages failed: %v", err)
+	}
+	if len(manifest.Images) != 3 {
+		t.Fatalf("Expected 3 images (duplicates removed), got %d", len(manifest.Images))
+	}
+	if manifest.Images[2].SavedAs != "silohound/pinned:sha256-0123456789abcdef" {
+		t.Errorf("Pinned image saved as %s", manifest.Images[2].SavedAs)
+	}
+
+	dst := &fakeStore{images: map[string]string{}}
+	if _, err := ImportImages(dst, bundle); err != nil {
+		t.Fatalf("ImportImages failed: %v", err)
+	}
+	for _, ref := range []string{"postgres:16", "neo4j:4.4", "silohound/pinned:sha256-0123456789abcdef"} {
+		if _, ok := dst.images[ref]; !ok {
+			t.Errorf("%s not loaded", ref)
+		}
+	}
+}
+
+func TestImportImages_ChecksumMismatch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "silohound_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	content := "postgres:16\npg-layers"
+	if err := os.WriteFile(filepath.Join(tmpDir, "00.tar"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &Manifest{
+		FormatVersion: FORMAT_VERSION,
+		Images: []ImageEntry{{
+			Reference: "postgres:16",
+			SavedAs:   "postgres:16",
+			File:      "images/00.tar",
+			Size:      int64(len(content)),
+			SHA256:    strings.Repeat("0", 64),
+		}},
+	}
+	bundle := filepath.Join(tmpDir, "bad.tar.gz")
+	if err := writeBundle(bundle, manifest, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := &fakeStore{images: map[string]string{}}
+	_, err = ImportImages(dst, bundle)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected checksum mismatch, got %v", err)
+	}
+	if len(dst.images) != 0 {
+		t.Errorf("Corrupt image should not be loaded")
+	}
+}
@@ -0,0 +1,119 @@
+// Package plan runs an ordered list of steps that know how to undo
+// themselves, so a multi-step operation either completes or leaves nothing
+// half-done behind.
+package plan
+
+import (
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of a single step.
+type Status string
+
+const (
+	STATUS_PENDING     Status = "not run"
+	STATUS_DONE        Status = "done"
+	STATUS_FAILED      Status = "FAILED"
+	STATUS_ROLLED_BACK Status = "rolled back"
+	STATUS_UNDO_FAILED Status = "ROLLBACK FAILED"
+	STATUS_KEPT        Status = "left in place"
+)
+
+// Step is one unit of work. Undo is optional. It is also called for the step
+// that failed, so it must cope with a partially applied step.
+type Step struct {
+	Name string
+	Do   func() error
+	Undo func() error
+}
+
+type Result struct {
+	Name    string
+	Status  Status
+	Err     error // Why Do failed
+	UndoErr error // Why Undo failed
+}
+
+type Report struct {
+	Results []Result
+	Failed  int // Index of the failed step, -1 if all succeeded
+	Kept    bool
+}
+
+type Plan struct {
+	steps []Step
+}
+
+func New() *Plan {
+	return &Plan{}
+}
+
+func (p *Plan) Add(steps ...Step) {
+	p.steps = append(p.steps, steps...)
+}
+
+// Run executes the steps in order. When a step fails the steps that already
+// ran, and the failed one, are undone in reverse order unless keep is set.
+// The returned error describes the failed step; the report records the
+// outcome of every step either way.
+func (p *Plan) Run(keep bool) (*Report, error) {
+	report := &Report{Failed: -1, Kept: keep}
+	for _, s := range p.steps {
+		report.Results = append(report.Results, Result{Name: s.Name, Status: STATUS_PENDING})
+	}
+
+	for i, s := range p.steps {
+		if err := s.Do(); err != nil {
+			report.Failed = i
+			report.Results[i].Status = STATUS_FAILED
+			report.Results[i].Err = err
+			break
+		}
+		report.Results[i].Status = STATUS_DONE
+	}
+	if report.Failed < 0 {
+		return report, nil
+	}
+
+	for i := report.Failed; i >= 0; i-- {
+		res := &report.Results[i]
+		s := p.steps[i]
+		if s.Undo == nil {
+			continue
+		}
+		if keep {
+			if i != report.Failed {
+				res.Status = STATUS_KEPT
+			}
+			continue
+		}
+		if err := s.Undo(); err != nil {
+			res.UndoErr = err
+			if i != report.Failed {
+				res.Status = STATUS_UNDO_FAILED
+			}
+			continue
+		}
+		if i != report.Failed {
+			res.Status = STATUS_ROLLED_BACK
+		}
+	}
+
+	failed := report.Results[report.Failed]
+	return report, fmt.Errorf("%s: %w", failed.Name, failed.Err)
+}
+
+// Print writes one line per step, with the error of the failed step and of
+// any undo that did not succeed.
+func (r *Report) Print(w io.Writer) {
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "  %-28s %s\n", res.Name, res.Status)
+		if res.Err != nil {
+			fmt.Fprintf(w, "      error: %v\n", res.Err)
+		}
+		if res.UndoErr != nil {
+			fmt.Fprintf(w, "      rollback error: %v\n", res.UndoErr)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recorder builds steps that log what ran, in order.
+type recorder struct {
+	log []string
+}
+
+func (r *recorder) step(name string, fail bool) Step {
+	return Step{
+		Name: name,
+		Do: func() error {
+			r.log = append(r.log, "do "+name)
+			if fail {
+				return errors.New(name + " broke")
+			}
+			return nil
+		},
+		Undo: func() error {
+			r.log = append(r.log, "undo "+name)
+			return nil
+		},
+	}
+}
+
+func TestPlan_Success(t *testing.T) {
+	r := &recorder{}
+	p := New()
+	p.Add(r.step("a", false), r.step("b", false))
+
+	report, err := p.Run(false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Failed != -1 {
+		t.Errorf("Failed = %d, want -1", report.Failed)
+	}
+	if got := strings.Join(r.log, ","); got != "do a,do b" {
+		t.Errorf("log = %s", got)
+	}
+}
+
+func TestPlan_RollbackInReverse(t *testing.T) {
+	r := &recorder{}
+	p := New()
+	p.Add(r.step("a", false), Step{Name: "no-undo", Do: func() error { return nil }}, r.step("b", false), r.step("c", true), r.step("d", false))
+
+	report, err := p.Run(false)
+	if err == nil || !strings.Contains(err.Error(), "c broke") {
+		t.Fatalf("Run error = %v, want failure of c", err)
+	}
+	if got := strings.Join(r.log, ","); got != "do a,do b,do c,undo c,undo b,undo a" {
+		t.Errorf("log = %s", got)
+	}
+
+	want := []Status{STATUS_ROLLED_BACK, STATUS_DONE, STATUS_ROLLED_BACK, STATUS_FAILED, STATUS_PENDING}
+	for i, res := range report.Results {
+		if res.Status != want[i] {
+			t.Errorf("step %s status = %s, want %s", res.Name, res.Status, want[i])
+		}
+	}
+}
+
+func TestPlan_Keep(t *testing.T) {
+	r := &recorder{}
+	p := New()
+	p.Add(r.step("a", false), r.step("b", true))
+
+	report, err := p.Run(true)
+	if err == nil {
+		t.Fatal("Run succeeded, want failure")
+	}
+	if got := strings.Join(r.log, ","); got != "do a,do b" {
+		t.Errorf("log = %s, want no undo", got)
+	}
+	if report.Results[0].Status != STATUS_KEPT {
+		t.Errorf("status = %s, want %s", report.Results[0].Status, STATUS_KEPT)
+	}
+}
+
+func TestPlan_UndoFailure(t *testing.T) {
+	p := New()
+	p.Add(Step{
+		Name: "a",
+		Do:   func() error { return nil },
+		Undo: func() error { return errors.New("stuck") },
+	}, Step{
+		Name: "b",
+		Do:   func() error { return errors.New("broke") },
+	})
+
+	report, _ := p.Run(false)
+	if report.Results[0].Status != STATUS_UNDO_FAILED || report.Results[0].UndoErr == nil {
+		t.Errorf("result = %+v, want rollback failure", report.Results[0])
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+	if !strings.Contains(out.String(), "rollback error: stuck") || !strings.Contains(out.String(), "error: broke") {
+		t.Errorf("summary missing errors:\n%s", out.String())
+	}
+}
@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DialDocker runs `docker system dial-stdio` on the target and returns a
+// connection to its Docker API. The ssh process lives as long as the
+// connection, not ctx, since the HTTP client keeps connections for reuse.
+func (t Target) DialDocker(ctx context.Context) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return newCmdConn(t.command(nil, "docker", "system", "dial-stdio"))
+}
+
+// cmdConn is a net.Conn over the stdin and stdout of a command.
+type cmdConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *lockedBuffer
+	// stderrDone is closed once stderr reached EOF, i.e. ssh exited
+	stderrDone chan struct{}
+
+	closeOnce sync.Once
+}
+
+func newCmdConn(cmd *exec.Cmd) (*cmdConn, error) {
+	c := &cmdConn{cmd: cmd, stderr: &lockedBuffer{}, stderrDone: make(chan struct{})}
+	var err error
+	if c.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if c.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", cmd.Path, err)
+	}
+	go func() {
+		io.Copy(c.stderr, stderr)
+		close(c.stderrDone)
+	}()
+	return c, nil
+}
+
+func (c *cmdConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		// ssh reports why the connection ended on stderr
+		c.waitStderr()
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return n, fmt.Errorf("ssh: %s", msg)
+		}
+	}
+	return n, err
+}
+
+func (c *cmdConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *cmdConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.waitStderr()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *cmdConn) waitStderr() {
+	select {
+	case <-c.stderrDone:
+	case <-time.After(time.Second):
+	}
+}
+
+func (c *cmdConn) LocalAddr() net.Addr  { return cmdAddr{} }
+func (c *cmdConn) RemoteAddr() net.Addr { return cmdAddr{} }
+
+// Deadlines are not supported on pipes; the HTTP client's own timeouts apply.
+func (c *cmdConn) SetDeadline(time.Time) error      { return nil }
+func (c *cmdConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *cmdConn) SetWriteDeadline(time.Time) error { return nil }
+
+type cmdAddr struct{}
+
+func (cmdAddr) Network() string { return "ssh" }
+func (cmdAddr) String() string  { return "ssh" }
+
+// lockedBuffer collects a process's stderr while it runs.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package remote
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// stopWithParent makes the kernel terminate the ssh client when SiloHound
+// exits, including through log.Fatal, so no tunnel outlives the command.
+func stopWithParent(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,91 @@
+// Package remote reaches a container engine and its published ports on
+// another host over SSH. It runs the system ssh client, so keys, agents,
+// jump hosts and ~/.ssh/config all apply.
+package remote
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sshCommand is the ssh client that is run. Tests replace it.
+var sshCommand = "ssh"
+
+// Target is an SSH destination.
+type Target struct {
+	User string
+	Host string
+	Port int // 0 uses the ssh default or ~/.ssh/config
+}
+
+// ParseTarget parses host, user@host or ssh://user@host:port.
+func ParseTarget(s string) (Target, error) {
+	s = strings.TrimSpace(s)
+	var t Target
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return Target{}, fmt.Errorf("invalid SSH host %q: %w", s, err)
+		}
+		if u.Scheme != "ssh" {
+			return Target{}, fmt.Errorf("invalid SSH host %q: scheme must be ssh", s)
+		}
+		if u.Path != "" && u.Path != "/" {
+			return Target{}, fmt.Errorf("invalid SSH host %q: unexpected path %s", s, u.Path)
+		}
+		t.User, t.Host = u.User.Username(), u.Hostname()
+		if p := u.Port(); p != "" {
+			port, err := strconv.Atoi(p)
+			if err != nil || port < 1 || port > 65535 {
+				return Target{}, fmt.Errorf("invalid SSH host %q: bad port %s", s, p)
+			}
+			t.Port = port
+		}
+	} else if i := strings.LastIndex(s, "@"); i >= 0 {
+		t.User, t.Host = s[:i], s[i+1:]
+	} else {
+		t.Host = s
+	}
+
+	// A leading dash would be read as an ssh option
+	if t.Host == "" || strings.HasPrefix(t.Host, "-") || strings.HasPrefix(t.User, "-") || strings.ContainsAny(s, " \t\n") {
+		return Target{}, fmt.Errorf("invalid SSH host %q (expected host, user@host or ssh://user@host:port)", s)
+	}
+	return t, nil
+}
+
+func (t Target) String() string {
+	dest := t.destination()
+	if t.Port != 0 {
+		return fmt.Sprintf("ssh://%s:%d", dest, t.Port)
+	}
+	return dest
+}
+
+func (t Target) destination() string {
+	if t.User != "" {
+		return t.User + "@" + t.Host
+	}
+	return t.Host
+}
+
+// args returns the ssh arguments: options, the destination and the remote
+// command, if any. Prompts are disabled since nobody can answer them.
+func (t Target) args(opts []string, command ...string) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if t.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(t.Port))
+	}
+	args = append(args, opts...)
+	args = append(args, "--", t.destination())
+	return append(args, command...)
+}
+
+func (t Target) command(opts []string, command ...string) *exec.Cmd {
+	cmd := exec.Command(sshCommand, t.args(opts, command...)...)
+	stopWithParent(cmd)
+	return cmd
+}
@@ -0,0 +1,103 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := map[string]Target{
+		"lab":                       {Host: "lab"},
+		"op@10.0.0.5":               {User: "op", Host: "10.0.0.5"},
+		"ssh://op@lab.example:2222": {User: "op", Host: "lab.example", Port: 2222},
+		"ssh://lab":                 {Host: "lab"},
+	}
+	for in, want := range tests {
+		got, err := ParseTarget(in)
+		if err != nil || got != want {
+			t.Errorf("ParseTarget(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-oProxyCommand=x", "op@", "http://lab", "ssh://lab:99999", "lab host"} {
+		if _, err := ParseTarget(in); err == nil {
+			t.Errorf("ParseTarget(%q) accepted", in)
+		}
+	}
+
+	target, _ := ParseTarget("ssh://op@lab:2222")
+	if s := target.String(); s != "ssh://op@lab:2222" {
+		t.Errorf("String = %s", s)
+	}
+	want := []string{"-o", "BatchMode=yes", "-p", "2222", "-N", "--", "op@lab", "true"}
+	if got := target.args([]string{"-N"}, "true"); !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %v, want %v", got, want)
+	}
+}
+
+// fakeSSH replaces the ssh client with a script that records its arguments
+// and then runs script.
+func fakeSSH(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	bin := filepath.Join(dir, "ssh")
+	body := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n" + script + "\n"
+	if err := os.WriteFile(bin, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := sshCommand
+	sshCommand = bin
+	t.Cleanup(func() { sshCommand = old })
+	return argsFile
+}
+
+func TestDialDocker(t *testing.T) {
+	argsFile := fakeSSH(t, "exec cat")
+	target, _ := ParseTarget("op@lab")
+
+	conn, err := target.DialDocker(context.Background())
+	if err != nil {
+		t.Fatalf("DialDocker failed: %v", err)
+	}
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping\n" {
+		t.Errorf("Read = %q, %v", buf, err)
+	}
+	conn.Close()
+
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-- op@lab docker system dial-stdio") {
+		t.Errorf("ssh ran with %q", args)
+	}
+
+	// ssh's error message is reported when the connection drops
+	fakeSSH(t, "echo 'Permission denied (publickey).' >&2; exit 255")
+	conn, err = target.DialDocker(context.Background())
+	if err != nil {
+		t.Fatalf("DialDocker failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(buf); err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Errorf("Read error = %v, want ssh's message", err)
+	}
+}
+
+func TestOpenTunnelFailure(t *testing.T) {
+	fakeSSH(t, "echo 'bind: Address already in use' >&2; exit 255")
+	target, _ := ParseTarget("lab")
+	if _, err := target.OpenTunnel([]int{1}, time.Second); err == nil || !strings.Contains(err.Error(), "Address already in use") {
+		t.Errorf("OpenTunnel error = %v, want ssh's message", err)
+	}
+	if _, err := target.OpenTunnel([]int{0}, time.Second); err == nil {
+		t.Error("OpenTunnel accepted no ports")
+	}
+}
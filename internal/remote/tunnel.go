@@ -0,0 +1,98 @@
+package remote
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tunnel forwards ports on this host's loopback to the same ports on the
+// remote host's loopback, where the containers publish them.
+type Tunnel struct {
+	target Target
+	ports  []int
+	stderr *lockedBuffer
+	done   chan struct{}
+	err    error
+	stop   func()
+}
+
+// OpenTunnel starts `ssh -N -L` for every port and waits until all of them
+// accept connections locally. Zero ports are ignored.
+func (t Target) OpenTunnel(ports []int, timeout time.Duration) (*Tunnel, error) {
+	opts := []string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=30"}
+	var forwarded []int
+	for _, p := range ports {
+		if p == 0 {
+			continue
+		}
+		forwarded = append(forwarded, p)
+		opts = append(opts, "-L", fmt.Sprintf("127.0.0.1:%d:127.0.0.1:%d", p, p))
+	}
+	if len(forwarded) == 0 {
+		return nil, fmt.Errorf("no ports to forward")
+	}
+
+	cmd := t.command(opts)
+	tn := &Tunnel{target: t, ports: forwarded, stderr: &lockedBuffer{}, done: make(chan struct{})}
+	cmd.Stderr = tn.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", cmd.Path, err)
+	}
+	tn.stop = func() { cmd.Process.Kill() }
+	go func() {
+		err := cmd.Wait()
+		if msg := strings.TrimSpace(tn.stderr.String()); msg != "" {
+			err = fmt.Errorf("ssh: %s", msg)
+		}
+		tn.err = err
+		close(tn.done)
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for _, p := range forwarded {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(p))
+		for {
+			if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+				conn.Close()
+				break
+			}
+			select {
+			case <-tn.done:
+				return nil, fmt.Errorf("tunnel to %s failed: %v", t, tn.err)
+			case <-time.After(100 * time.Millisecond):
+			}
+			if time.Now().After(deadline) {
+				tn.Close()
+				return nil, fmt.Errorf("tunnel to %s: port %d not forwarded after %s", t, p, timeout)
+			}
+		}
+	}
+	return tn, nil
+}
+
+// Ports returns the forwarded ports.
+func (tn *Tunnel) Ports() []int {
+	return tn.ports
+}
+
+// Done is closed when the ssh client exits; Err then tells why.
+func (tn *Tunnel) Done() <-chan struct{} {
+	return tn.done
+}
+
+func (tn *Tunnel) Err() error {
+	<-tn.done
+	return tn.err
+}
+
+// Close stops forwarding. It is safe to call on a nil Tunnel.
+func (tn *Tunnel) Close() {
+	if tn == nil {
+		return
+	}
+	tn.stop()
+	<-tn.done
+}
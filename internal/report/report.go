@@ -13,14 +13,25 @@ import (
 //go:embed template.html
 var defaultTemplate string
 
+// Engagement describes the project an audit belongs to. It heads the report;
+// empty fields are left out.
+type Engagement struct {
+	Project string
+	Client  string
+	Window  string // Engagement dates, e.g. "2025-07-01 to 2025-07-31"
+	Domains []string
+	Status  string
+}
+
 type ReportData struct {
 	Date         string
+	Engagement   Engagement
 	Stats        *audit.Analysis
 	TopReuse     []audit.StatPair
 	TopBaseWords []audit.StatPair
 }
 
-func Generate(path string, stats *audit.Analysis, customTemplatePath string) error {
+func Generate(path string, stats *audit.Analysis, eng Engagement, customTemplatePath string) error {
 	tmplContent := defaultTemplate
 
 	if customTemplatePath != "" {
@@ -68,6 +79,7 @@ func Generate(path string, stats *audit.Analysis, customTemplatePath string) err
 
 	data := ReportData{
 		Date:         time.Now().Format("2006-01-02 15:04:05"),
+		Engagement:   eng,
 		Stats:        stats,
 		TopReuse:     topReuse,
 		TopBaseWords: topBase,
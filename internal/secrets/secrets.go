@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+)
+
+const (
+	lower   = "abcdefghijklmnopqrstuvwxyz"
+	upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits  = "0123456789"
+	symbols = "!@#%*-_+="
+
+	KEY_SIZE = 32
+)
+
+// GeneratePassword returns a random password of the given length containing
+// at least one lowercase letter, uppercase letter and digit. When withSymbols
+// is set it also contains at least one symbol, which BloodHound requires for
+// user passwords. Symbols are left out of service passwords because they end
+// up inside connection strings.
+func GeneratePassword(length int, withSymbols bool) (string, error) {
+	classes := []string{lower, upper, digits}
+	if withSymbols {
+		classes = append(classes, symbols)
+	}
+	if length < len(classes) {
+		return "", fmt.Errorf("password length %d is too short", length)
+	}
+
+	all := ""
+	for _, c := range classes {
+		all += c
+	}
+
+	out := make([]byte, length)
+	// Guarantee one character from every class, then fill the rest
+	for i, c := range classes {
+		ch, err := randomChar(c)
+		if err != nil {
+			return "", err
+		}
+		out[i] = ch
+	}
+	for i := len(classes); i < length; i++ {
+		ch, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		out[i] = ch
+	}
+
+	// Shuffle so the guaranteed characters are not always first
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+	return string(out), nil
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
+
+// LoadOrCreateKey reads the encryption key stored at path, creating a new
+// random key with owner-only permissions if the file does not exist.
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != KEY_SIZE {
+			return nil, fmt.Errorf("key file %s is corrupt (expected %d bytes, got %d)", path, KEY_SIZE, len(key))
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key = make([]byte, KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM. The random nonce is prepended to
+// the returned ciphertext.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens data produced by Encrypt.
+func Decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong key?): %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,195 @@
+package tlsproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	CA_CERT     = "ca.crt"
+	CA_KEY      = "ca.key"
+	SERVER_CERT = "server.crt"
+	SERVER_KEY  = "server.key"
+
+	CA_VALIDITY = 10 * 365 * 24 * time.Hour
+	// Browsers reject server certificates valid for more than 398 days
+	SERVER_VALIDITY = 397 * 24 * time.Hour
+	// RENEW_BEFORE renews the server certificate when it is this close to expiry.
+	RENEW_BEFORE = 30 * 24 * time.Hour
+)
+
+// Certificates are the PEM files of a project's TLS endpoint.
+type Certificates struct {
+	CACert     string
+	CAKey      string
+	ServerCert string
+	ServerKey  string
+}
+
+// Paths returns the certificate files kept in dir.
+func Paths(dir string) Certificates {
+	return Certificates{
+		CACert:     filepath.Join(dir, CA_CERT),
+		CAKey:      filepath.Join(dir, CA_KEY),
+		ServerCert: filepath.Join(dir, SERVER_CERT),
+		ServerKey:  filepath.Join(dir, SERVER_KEY),
+	}
+}
+
+// EnsureCertificates makes sure dir holds a CA for the project and a server
+// certificate signed by it that is valid for every entry of hosts (names or
+// IP addresses). The CA is created once and kept, so clients that trust it
+// keep working; the server certificate is reissued when it is missing, about
+// to expire or does not cover hosts. It reports whether anything was issued.
+func EnsureCertificates(dir, project string, hosts []string) (Certificates, bool, error) {
+	paths := Paths(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return paths, false, err
+	}
+
+	caCert, caKey, err := loadPair(paths.CACert, paths.CAKey)
+	issued := false
+	if errors.Is(err, os.ErrNotExist) {
+		caCert, caKey, err = createCA(paths, project)
+		issued = true
+	}
+	if err != nil {
+		return paths, false, fmt.Errorf("certificate authority: %w", err)
+	}
+
+	if !issued {
+		cert, _, err := loadPair(paths.ServerCert, paths.ServerKey)
+		if err == nil && covers(cert, hosts) && time.Until(cert.NotAfter) > RENEW_BEFORE && cert.CheckSignatureFrom(caCert) == nil {
+			return paths, false, nil
+		}
+	}
+	if err := createServerCert(paths, project, hosts, caCert, caKey); err != nil {
+		return paths, false, fmt.Errorf("server certificate: %w", err)
+	}
+	return paths, true, nil
+}
+
+func createCA(paths Certificates, project string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"SiloHound"}, CommonName: fmt.Sprintf("SiloHound %s CA", project)},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CA_VALIDITY),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writePair(paths.CACert, paths.CAKey, der, key); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func createServerCert(paths Certificates, project string, hosts []string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"SiloHound"}, CommonName: fmt.Sprintf("SiloHound %s", project)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(SERVER_VALIDITY),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return writePair(paths.ServerCert, paths.ServerKey, der, key)
+}
+
+// covers reports whether cert is valid for every host.
+func covers(cert *x509.Certificate, hosts []string) bool {
+	for _, h := range hosts {
+		if cert.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// writePair writes a certificate (0644) and its key (0600) as PEM.
+func writePair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func loadPair(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("%s or %s is not PEM encoded", certPath, keyPath)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
@@ -0,0 +1,181 @@
+package tlsproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ALLOW_ANY is the allowlist entry that admits every client.
+const ALLOW_ANY = "any"
+
+// Allowlist holds the client networks admitted to the endpoint. Loopback
+// clients are always admitted; an empty list admits nobody else.
+type Allowlist struct {
+	any      bool
+	prefixes []netip.Prefix
+}
+
+// ParseAllowlist parses a comma-separated list of IP addresses, CIDR ranges
+// or "any".
+func ParseAllowlist(s string) (Allowlist, error) {
+	var a Allowlist
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case entry == ALLOW_ANY:
+			a.any = true
+		case strings.Contains(entry, "/"):
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return Allowlist{}, fmt.Errorf("invalid allowlist entry %q: %w", entry, err)
+			}
+			a.prefixes = append(a.prefixes, p.Masked())
+		default:
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return Allowlist{}, fmt.Errorf("invalid allowlist entry %q: %w", entry, err)
+			}
+			a.prefixes = append(a.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return a, nil
+}
+
+// Any reports whether every client is admitted.
+func (a Allowlist) Any() bool {
+	return a.any
+}
+
+// LoopbackOnly reports whether only clients on this host are admitted.
+func (a Allowlist) LoopbackOnly() bool {
+	return !a.any && len(a.prefixes) == 0
+}
+
+// Allowed reports whether a client at addr (an IP, optionally with a port)
+// is admitted.
+func (a Allowlist) Allowed(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	if a.any || ip.IsLoopback() {
+		return true
+	}
+	for _, p := range a.prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Allowlist) String() string {
+	if a.any {
+		return ALLOW_ANY
+	}
+	if a.LoopbackOnly() {
+		return "loopback only"
+	}
+	var parts []string
+	for _, p := range a.prefixes {
+		if p.IsSingleIP() {
+			parts = append(parts, p.Addr().String())
+		} else {
+			parts = append(parts, p.String())
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Handler forwards admitted requests to target and answers everyone else
+// with 403 Forbidden.
+func Handler(target *url.URL, allow Allowlist, logger *log.Logger) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Host = r.In.Host
+		},
+		ErrorLog: logger,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allow.Allowed(r.RemoteAddr) {
+			logger.Printf("Rejected %s %s from %s (not in allowlist)", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// Serve runs a TLS endpoint on addr with the given certificates until ctx is
+// cancelled. Connections from clients outside the allowlist are closed
+// before the TLS handshake.
+func Serve(ctx context.Context, addr string, certs Certificates, target *url.URL, allow Allowlist, logger *log.Logger) error {
+	pair, err := tls.LoadX509KeyPair(certs.ServerCert, certs.ServerKey)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	ln = tls.NewListener(&allowListener{Listener: ln, allow: allow, logger: logger}, &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	srv := &http.Server{
+		Handler:           Handler(target, allow, logger),
+		ReadHeaderTimeout: 30 * time.Second,
+		ErrorLog:          logger,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// allowListener drops connections from clients outside the allowlist.
+type allowListener struct {
+	net.Listener
+	allow  Allowlist
+	logger *log.Logger
+}
+
+func (l *allowListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.allow.Allowed(conn.RemoteAddr().String()) {
+			return conn, nil
+		}
+		l.logger.Printf("Rejected connection from %s (not in allowlist)", conn.RemoteAddr())
+		conn.Close()
+	}
+}
@@ -0,0 +1,126 @@
+package tlsproxy
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestEnsureCertificates(t *testing.T) {
+	dir := t.TempDir()
+	hosts := []string{"localhost", "127.0.0.1", "10.0.0.5"}
+
+	certs, issued, err := EnsureCertificates(dir, "Acme", hosts)
+	if err != nil || !issued {
+		t.Fatalf("EnsureCertificates = %v, %v; want new certificates", issued, err)
+	}
+	ca := readCert(t, certs.CACert)
+	server := readCert(t, certs.ServerCert)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	for _, h := range hosts {
+		if _, err := server.Verify(x509.VerifyOptions{Roots: pool, DNSName: h}); err != nil {
+			t.Errorf("server certificate does not verify for %s: %v", h, err)
+		}
+	}
+	if info, err := os.Stat(certs.CAKey); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("CA key mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// Nothing changes while the hosts are covered
+	if _, issued, err := EnsureCertificates(dir, "Acme", hosts[:1]); err != nil || issued {
+		t.Errorf("second call = %v, %v; want reuse", issued, err)
+	}
+
+	// A new host reissues the server certificate under the same CA
+	if _, issued, err := EnsureCertificates(dir, "Acme", append(hosts, "bh.example.test")); err != nil || !issued {
+		t.Fatalf("new host = %v, %v; want reissue", issued, err)
+	}
+	if got := readCert(t, certs.CACert); !got.Equal(ca) {
+		t.Error("CA was replaced")
+	}
+	if err := readCert(t, certs.ServerCert).VerifyHostname("bh.example.test"); err != nil {
+		t.Errorf("reissued certificate: %v", err)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	a, err := ParseAllowlist("10.0.0.0/24, 192.168.1.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.0.0.44:5123":      true,
+		"192.168.1.7":         true,
+		"192.168.1.8":         false,
+		"127.0.0.1:9000":      true,
+		"[::1]:9000":          true,
+		"[::ffff:10.0.0.9]:1": true,
+		"8.8.8.8:443":         false,
+		"garbage":             false,
+	} {
+		if got := a.Allowed(addr); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	if empty, _ := ParseAllowlist(""); empty.Allowed("10.0.0.1") || !empty.Allowed("127.0.0.1") {
+		t.Error("empty allowlist should admit loopback only")
+	}
+	if open, _ := ParseAllowlist("any"); !open.Any() || !open.Allowed("8.8.8.8") {
+		t.Error("any should admit everyone")
+	}
+	if _, err := ParseAllowlist("10.0.0.300"); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "bloodhound "+r.Header.Get("X-Forwarded-For"))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+
+	allow, _ := ParseAllowlist("10.0.0.0/24")
+	h := Handler(target, allow, log.New(io.Discard, "", 0))
+
+	req := httptest.NewRequest("GET", "/ui/login", nil)
+	req.RemoteAddr = "10.0.0.7:4444"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "bloodhound 10.0.0.7" {
+		t.Errorf("allowed client got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req.RemoteAddr = "10.0.1.7:4444"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("rejected client got %d, want 403", rec.Code)
+	}
+}
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s is not PEM", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
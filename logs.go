@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// LOGS_DIR holds saved log bundles below the project path.
+const LOGS_DIR = "logs"
+
+// ALL_ROLES selects every service role for -logs.
+const ALL_ROLES = "all"
+
+// logRoles resolves a -logs argument to the roles it names.
+func logRoles(arg string) ([]string, error) {
+	if arg == ALL_ROLES {
+		return docker.LogRoles, nil
+	}
+	for _, role := range docker.LogRoles {
+		if arg == role {
+			return []string{role}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown role %q (expected %s, %s, %s or %s)", arg, docker.ROLE_BLOODHOUND, docker.ROLE_NEO4J, docker.ROLE_POSTGRES, ALL_ROLES)
+}
+
+// saveLogBundle writes the logs of the given roles, one file per role, and
+// the project status into a gzipped tarball below the project's logs folder,
+// and returns its path.
+func saveLogBundle(mgr *docker.Manager, proj *database.Project, roles []string, opts docker.LogOptions) (string, error) {
+	files := map[string][]byte{}
+	var found int
+	for _, role := range roles {
+		var buf bytes.Buffer
+		if err := mgr.RoleLogs(proj.Name, role, opts, &buf, &buf); err != nil {
+			fmt.Printf("Warning: No logs for %s: %v\n", role, err)
+			continue
+		}
+		files[role+".log"] = buf.Bytes()
+		found++
+	}
+	if found == 0 {
+		return "", fmt.Errorf("no logs available for project %s", proj.Name)
+	}
+	if st, err := collectStatus(mgr, *proj); err == nil {
+		if data, err := json.MarshalIndent(st, "", "  "); err == nil {
+			files["status.json"] = data
+		}
+	}
+
+	dir := filepath.Join(proj.Path, LOGS_DIR)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, fmt.Sprintf("silohound-logs-%s.tar.gz", time.Now().Format("20060102-150405")))
+	if err := writeLogArchive(dest, files); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	return dest, nil
+}
+
+func writeLogArchive(dest string, files map[string][]byte) error {
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for name, data := range files {
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
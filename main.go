@@ -14,13 +14,18 @@ import (
 	"time"
 
 	"github.com/Mortimus/SiloHound/internal/audit"
+	"github.com/Mortimus/SiloHound/internal/bulk"
 	"github.com/Mortimus/SiloHound/internal/database"
 	"github.com/Mortimus/SiloHound/internal/docker"
 	"github.com/Mortimus/SiloHound/internal/graph"
 	"github.com/Mortimus/SiloHound/internal/importer"
+	"github.com/Mortimus/SiloHound/internal/plan"
 	"github.com/Mortimus/SiloHound/internal/report"
 )
 
+// DEFAULT_NEO4J_HEAP is a good baseline for AD imports.
+const DEFAULT_NEO4J_HEAP = "2G"
+
 var (
 	Version = "v1.1.0"
 	Commit  = ""
@@ -55,17 +60,83 @@ func main() {
 	name := flag.String("name", "", "Project Name")
 	path := flag.String("path", "", "Path to store data folders (default: current directory)")
 	list := flag.Bool("list", false, "List known projects")
+	showStatus := flag.Bool("status", false, "Show container state, health, ports and disk usage for -name or all projects")
+	runDoctor := flag.Bool("doctor", false, "Check the container engine, ports, disk, memory, data paths and images, and suggest fixes (-name limits it to one project)")
+	jsonOut := flag.Bool("json", false, "Print -status or -history output as JSON")
+	showHistory := flag.Bool("history", false, "Show the operations run on -name, or on all projects: who, when, with which arguments and how it went")
+	exportHist := flag.String("history-export", "", "Write the operation history of -name, or of all projects, to this file as CSV, or JSON if it ends in .json")
 	clean := flag.Bool("clean", false, "Clean/Delete project (requires -name)")
-	stop := flag.Bool("stop", false, "Stop all containers for project (requires -name)")
+	stop := flag.Bool("stop", false, "Stop all containers for project (requires -name, or -all, -projects or -tag)")
+	allProjects := flag.Bool("all", false, "Start, or with -stop stop, every known project")
+	projectList := flag.String("projects", "", "Comma-separated project names or globs (e.g. acme-*) to start, or with -stop stop, in one run")
+	tagFilter := flag.String("tag", "", "Only act on (or -list) projects with this tag")
+	parallel := flag.Int("parallel", bulk.DEFAULT_PARALLEL, "How many projects -all, -projects or -tag act on at once")
+	setTags := flag.String("tags", "", "Set the project's comma-separated tags; empty clears them (requires -name)")
+	client := flag.String("client", "", "Set the project's client name; with -list, only list projects whose client contains this (requires -name)")
+	startDate := flag.String("start-date", "", "Set the engagement start date, YYYY-MM-DD; empty clears it (requires -name)")
+	endDate := flag.String("end-date", "", "Set the engagement end date, YYYY-MM-DD; empty clears it (requires -name)")
+	domains := flag.String("domains", "", "Set the comma-separated domains in scope; empty clears them (requires -name)")
+	notes := flag.String("notes", "", "Set free-form notes for the project; empty clears them (requires -name)")
+	projectStatus := flag.String("project-status", "", "Set the engagement status: active, paused, reporting or archived; with -list, only list projects in this status (requires -name)")
+	showInfo := flag.Bool("info", false, "Show the project's client, engagement dates, status, domains, tags and notes (requires -name)")
 	move := flag.String("move", "", "Move project to new path (requires -name)")
+	showCreds := flag.Bool("creds", false, "Print the project's service credentials (requires -name)")
+	rotateCreds := flag.Bool("rotate-creds", false, "Generate new service credentials for a running project (requires -name)")
 	custom := flag.String("custom", "", "Path to custom queries.json (optional)")
 	cloneQueries := flag.Bool("clone-queries", true, "Clone SpecterOps Query Library")
 	pull := flag.Bool("pull", false, "Force pull images before starting")
+	exportImages := flag.String("export-images", "", "Save all required and pinned images to an offline bundle at this path")
+	importImages := flag.String("import-images", "", "Load images from an offline bundle created with -export-images")
+	imageBundle := flag.String("image-bundle", "", "Offline image bundle to load if pulling an image fails")
+	storageMode := flag.String("storage", "", "Where a new project keeps its Postgres and Neo4j data: bind (folders under -path) or volume (named volumes) (default: bind)")
+	migrateStorage := flag.String("migrate-storage", "", "Move the project's data to bind or volume storage (requires -name)")
+	exportProj := flag.String("export-project", "", "Pack the project's data, saved queries, reports and credentials into a bundle at this path (requires -name)")
+	importProj := flag.String("import-project", "", "Register a project from a bundle created with -export-project (uses -name and -path if given)")
+	neo4jVersion := flag.String("neo4j-version", "", "Neo4j major version for a new project: 4 (neo4j:4.4) or 5 (default: 4)")
+	neo4jPlugins := flag.String("neo4j-plugins", "", "Comma-separated Neo4j plugins: apoc, apoc-extended, gds, or none; jars in the project's plugins folder are used offline (default: stored, or apoc)")
+	migrateNeo4jFlag := flag.Bool("migrate-neo4j", false, "Back up data and migrate the project's graph from Neo4j 4.4 to Neo4j 5, rolling back on failure (requires -name)")
+	upgrade := flag.Bool("upgrade", false, "Back up data and move the project to the latest images, rolling back on failure (requires -name)")
+	snapshot := flag.String("snapshot", "", "Stop the project and archive its data under this label (requires -name)")
+	listSnaps := flag.Bool("snapshots", false, "List the project's snapshots (requires -name)")
+	restore := flag.Int("restore", 0, "Replace the project's data with the snapshot with this ID (requires -name)")
+	deleteSnap := flag.Int("delete-snapshot", 0, "Delete the snapshot with this ID (requires -name)")
+	logsRole := flag.String("logs", "", "Show container logs for a role (bh, neo4j, psql) or all (requires -name)")
+	follow := flag.Bool("follow", false, "Keep streaming -logs as they are written")
+	logSince := flag.String("since", "", "Only show logs since a timestamp or relative duration, e.g. 30m")
+	logTail := flag.String("tail", "all", "Number of log lines to show from the end of each log, or all")
+	logTimestamps := flag.Bool("timestamps", false, "Prefix each log line with its timestamp")
+	saveLogs := flag.Bool("save-logs", false, "Save a log bundle for -logs roles (default: all) to the project's logs folder (requires -name)")
+	supervise := flag.Bool("supervise", false, "Stay in the foreground after startup (or attach to a running project) and restart containers that crash (requires -name)")
+	tlsOn := flag.Bool("tls", false, "Serve the BloodHound UI over HTTPS with a per-project CA and stay in the foreground (requires -name)")
+	tlsBind := flag.String("tls-bind", DEFAULT_TLS_BIND, "Address the -tls endpoint listens on, e.g. 0.0.0.0 to expose it (default: stored, or 127.0.0.1)")
+	tlsPort := flag.Int("tls-port", 0, "Port for the -tls endpoint (default: stored, or the first free port from 8443)")
+	tlsAllow := flag.String("tls-allow", "", "Comma-separated client IPs and CIDR ranges admitted to the -tls endpoint, or any (default: stored, or this host only)")
+	tlsHosts := flag.String("tls-hosts", "", "Extra comma-separated names or IPs for the -tls certificate")
+	showCrashes := flag.Bool("crashes", false, "List the crashes recorded by -supervise (requires -name)")
 	debugFlag := flag.Bool("debug", false, "Enable verbose startup diagnostics and container logs")
+	runtimeName := flag.String("runtime", "", "Container runtime: docker or podman (default: $SILOHOUND_RUNTIME or docker)")
+	sshHost := flag.String("ssh-host", "", "Run a new project on the Docker engine of this host over SSH: host, user@host or ssh://user@host:port (default: stored, or local)")
+	connect := flag.Bool("connect", false, "Forward a remote project's BloodHound and Neo4j ports to this host until Ctrl+C (requires -name)")
+	podmanSocket := flag.String("podman-socket", "", "Podman API socket (default: $CONTAINER_HOST or the user/system podman.sock)")
 	ver := flag.Bool("v", false, "Show version")
 
+	// Host ports (0 = reuse the stored port or pick a free one)
+	bhPort := flag.Int("bh-port", 0, "Host port for the BloodHound UI (default: stored or auto)")
+	neo4jHTTPPort := flag.Int("neo4j-http-port", 0, "Host port for the Neo4j browser (default: stored or auto)")
+	neo4jBoltPort := flag.Int("neo4j-bolt-port", 0, "Host port for Neo4j Bolt (default: stored or auto)")
+
+	// Readiness probe tuning
+	readyTimeout := flag.Duration("ready-timeout", docker.DefaultProbeConfig().Timeout, "How long to wait for each service to become ready")
+	readyBackoff := flag.Duration("ready-backoff", docker.DefaultProbeConfig().Backoff, "Initial delay between readiness probes (doubles up to -ready-max-backoff)")
+	readyMaxBackoff := flag.Duration("ready-max-backoff", docker.DefaultProbeConfig().MaxBackoff, "Maximum delay between readiness probes")
+
 	// Add neo4j memory flag with 2G baseline (good default for AD imports)
-	neo4jHeap := flag.String("neo4j-heap", "2G", "Maximum JVM heap size for Neo4j (e.g., 2G, 4G, 8G)")
+	neo4jHeap := flag.String("neo4j-heap", DEFAULT_NEO4J_HEAP, "Maximum JVM heap size for Neo4j (e.g., 2G, 4G, 8G), or auto to size heap and page cache from host RAM and graph size; a resume keeps the stored size unless given")
+	neo4jPageCache := flag.String("neo4j-pagecache", "", "Neo4j page cache size (e.g., 4G), or auto (default: stored, or Neo4j's default)")
+	cpus := flag.Float64("cpus", 0, "CPU limit per container, e.g. 2 or 1.5; 0 removes it (default: stored, or none)")
+	psqlMem := flag.String("postgres-memory", "", "Memory limit for the Postgres container, e.g. 1G; 0 removes it (default: stored, or none)")
+	neo4jMem := flag.String("neo4j-memory", "", "Memory limit for the Neo4j container; must exceed heap plus page cache (default: stored, or none)")
+	bhMem := flag.String("bh-memory", "", "Memory limit for the BloodHound container (default: stored, or none)")
 
 	// Password Audit Flags
 	auditNTDS := flag.String("audit-ntds", "", "Path to secretsdump output (User:RID:LM:NT:...)")
@@ -74,6 +145,34 @@ func main() {
 
 	flag.Parse()
 
+	sizing := sizingFlags{
+		set:       map[string]bool{},
+		heap:      *neo4jHeap,
+		pageCache: *neo4jPageCache,
+		cpus:      *cpus,
+		psqlMem:   *psqlMem,
+		neo4jMem:  *neo4jMem,
+		bhMem:     *bhMem,
+	}
+	flag.Visit(func(f *flag.Flag) { sizing.set[f.Name] = true })
+	meta := metadataFlags{
+		set:     sizing.set,
+		client:  *client,
+		start:   *startDate,
+		end:     *endDate,
+		tags:    *setTags,
+		domains: *domains,
+		notes:   *notes,
+		status:  *projectStatus,
+	}
+	tlsOpts := tlsFlags{
+		set:   sizing.set,
+		bind:  *tlsBind,
+		port:  *tlsPort,
+		allow: *tlsAllow,
+		hosts: *tlsHosts,
+	}
+
 	if len(os.Args) < 2 {
 		flag.Usage()
 		os.Exit(0)
@@ -101,29 +200,160 @@ func main() {
 		log.Fatalf("Failed to init database: %v", err)
 	}
 	defer db.Close()
+	if m := db.Migration; m.Migrated() && m.Backup != "" {
+		fmt.Printf("Upgraded the project database from schema version %d to %d (backup: %s)\n", m.From, m.To, m.Backup)
+	}
 
-	// Docker Manager
+	// Container Manager
 	ctx := context.Background()
-	mgr, err := docker.NewManager(ctx, *debugFlag)
+	docker.Version = Version
+	// A project on a remote host is managed through that host's engine
+	remoteHost := *sshHost
+	if *name != "" {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj != nil && remoteHost != "" && remoteHost != proj.SSHHost {
+			where := "this host"
+			if proj.SSHHost != "" {
+				where = proj.SSHHost
+			}
+			log.Fatalf("Project %s runs on %s; its containers and data cannot be moved to %s.", proj.Name, where, remoteHost)
+		}
+		if proj != nil {
+			remoteHost = proj.SSHHost
+		}
+	}
+	rt, err := docker.NewRuntime(*runtimeName, *podmanSocket, remoteHost)
+	if err != nil && *runDoctor {
+		if !doctorCheck(db, nil, err, *name, *neo4jHeap) {
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to create docker client: %v", err)
+		log.Fatalf("Failed to connect to container runtime: %v", err)
 	}
+	mgr := docker.NewManager(ctx, rt, *debugFlag)
 	defer mgr.Close()
 
+	probeCfg := docker.DefaultProbeConfig()
+	probeCfg.Timeout = *readyTimeout
+	probeCfg.Backoff = *readyBackoff
+	probeCfg.MaxBackoff = *readyMaxBackoff
+	for _, role := range []string{docker.ROLE_POSTGRES, docker.ROLE_NEO4J, docker.ROLE_BLOODHOUND} {
+		mgr.SetProbeConfig(role, probeCfg)
+	}
+
 	// List Projects
 	if *list {
-		projects, err := db.ListProjects()
+		projects, err := selectProjects(db, *allProjects, *projectList, *tagFilter)
 		if err != nil {
 			log.Fatalf("Failed to list projects: %v", err)
 		}
+		if *projectStatus != "" {
+			if err := checkStatus(strings.ToLower(*projectStatus)); err != nil {
+				log.Fatal(err)
+			}
+		}
 		fmt.Printf("Known Projects:\n")
 		for _, p := range projects {
+			if !matchesFilter(p, *client, *projectStatus) {
+				continue
+			}
 			running, _ := mgr.IsRunning(p.Name)
 			status := "STOPPED"
-			if running {
+			switch {
+			case p.SSHHost != "":
+				// Only the engine on that host knows
+				status = "REMOTE on " + p.SSHHost
+			case running:
 				status = "RUNNING"
 			}
-			fmt.Printf("- %s (Status: %s, Created: %s, Path: %s)\n", p.Name, status, p.CreatedAt.Format(time.RFC822), p.Path)
+			details := ""
+			if p.Client != "" {
+				details += ", Client: " + p.Client
+			}
+			if p.Status != database.STATUS_ACTIVE {
+				details += ", Engagement: " + p.Status
+			}
+			if p.Tags != "" {
+				details += ", Tags: " + p.Tags
+			}
+			fmt.Printf("- %s (Status: %s, Created: %s, Path: %s, Ports: %s%s)\n", p.Name, status, p.CreatedAt.Format(time.RFC822), p.Path, formatPorts(p), details)
+		}
+		return
+	}
+
+	// Project Status
+	if *showStatus {
+		if err := printStatus(db, mgr, *name, *jsonOut); err != nil {
+			log.Fatalf("Failed to get status: %v", err)
+		}
+		return
+	}
+
+	// Operation History
+	if *showHistory || *exportHist != "" {
+		if *exportHist != "" {
+			err = exportHistory(db, *name, *exportHist)
+		} else {
+			err = printHistory(db, *name, *jsonOut)
+		}
+		if err != nil {
+			log.Fatalf("Failed to read the history: %v", err)
+		}
+		return
+	}
+
+	// Preflight Checks
+	if *runDoctor {
+		if !doctorCheck(db, mgr, nil, *name, *neo4jHeap) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Offline Image Bundles
+	if *exportImages != "" {
+		if err := exportImageBundle(db, mgr, *exportImages); err != nil {
+			log.Fatalf("Failed to export images: %v", err)
+		}
+		return
+	}
+	if *importImages != "" {
+		if err := importImageBundle(mgr, *importImages); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Project Import
+	if *importProj != "" {
+		op := beginOperation(db, *name, OP_IMPORT)
+		imported, err := importProject(db, mgr, *importProj, *name, *path)
+		if imported != "" {
+			op.project = imported
+		}
+		if err != nil {
+			fail(op, "Failed to import project: %v", err)
+		}
+		op.end(nil)
+		return
+	}
+
+	// Bulk Start / Stop
+	if *allProjects || *projectList != "" || *tagFilter != "" {
+		if *name != "" {
+			log.Fatal("-name cannot be combined with -all, -projects or -tag")
+		}
+		projects, err := selectProjects(db, *allProjects, *projectList, *tagFilter)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := runBulk(db, mgr, projects, *stop, *parallel); err != nil {
+			log.Fatal(err)
 		}
 		return
 	}
@@ -132,12 +362,34 @@ func main() {
 		log.Fatal("-name is required")
 	}
 
+	// Project Metadata
+	if meta.given() || *showInfo {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		if !meta.given() {
+			printMetadata(proj.Name, proj.Metadata())
+			return
+		}
+		op := beginOperation(db, proj.Name, OP_UPDATE)
+		if err := updateMetadata(db, proj, meta); err != nil {
+			fail(op, "Failed to update project %s: %v", proj.Name, err)
+		}
+		op.end(nil)
+		return
+	}
+
 	// Clean Project
 	if *clean {
+		op := beginOperation(db, *name, OP_CLEAN)
 		// Get Path first
 		proj, err := db.GetProject(*name)
 		if err != nil {
-			log.Fatalf("Database error: %v", err)
+			fail(op, "Database error: %v", err)
 		}
 
 		// Stop containers
@@ -148,13 +400,18 @@ func main() {
 
 		// Ensure stopped
 		if running, _ := mgr.IsRunning(*name); running {
-			log.Fatalf("Critical: Failed to stop containers. Cannot proceed with clean as it may corrupt data or fail.")
+			fail(op, "Critical: Failed to stop containers. Cannot proceed with clean as it may corrupt data or fail.")
+		}
+
+		// Remove data volumes (volume storage, or left over from a migration)
+		if err := mgr.RemoveProjectVolumes(*name); err != nil {
+			fail(op, "Failed to remove data volumes: %v", err)
 		}
 
 		// Remove from DB
 		err = db.DeleteProject(*name)
 		if err != nil {
-			log.Fatalf("Failed to delete project from DB: %v", err)
+			fail(op, "Failed to delete project from DB: %v", err)
 		}
 		fmt.Printf("Project %s removed from database.\n", *name)
 
@@ -163,15 +420,16 @@ func main() {
 			fmt.Printf("Cleaning up files at %s...\n", proj.Path)
 
 			// Safe cleanup: Only remove specific directories we created
-			folders := []string{"bloodhound-data", "BloodHoundQueryLibrary"}
+			folders := []string{"bloodhound-data", "BloodHoundQueryLibrary", SNAPSHOT_DIR}
 			uid := os.Getuid()
 			gid := os.Getgid()
 
 			for _, f := range folders {
 				p := filepath.Join(proj.Path, f)
 
-				// Fix permissions via Docker if it exists
-				if _, err := os.Stat(p); err == nil {
+				// Fix permissions via Docker if it exists; a remote engine
+				// cannot see this host's files
+				if _, err := os.Stat(p); err == nil && proj.SSHHost == "" {
 					fmt.Printf("Fixing permissions for %s...\n", f)
 					if err := mgr.FixPermissions(p, uid, gid); err != nil {
 						fmt.Printf("Warning: Failed to fix permissions for %s: %v\n", f, err)
@@ -193,47 +451,264 @@ func main() {
 			fmt.Println("Project data removed.")
 		}
 
+		op.end(nil)
 		return
 	}
 
 	// Stop Project
 	if *stop {
+		op := beginOperation(db, *name, OP_STOP)
 		fmt.Printf("Stopping containers for project %s...\n", *name)
 		if err := mgr.StopProjectContainers(*name); err != nil {
-			log.Fatalf("Failed to stop containers: %v", err)
+			fail(op, "Failed to stop containers: %v", err)
 		}
 		fmt.Printf("Project %s containers stopped.\n", *name)
+		op.end(nil)
 		return
 	}
 
 	// Move Project
 	if *move != "" {
+		op := beginOperation(db, *name, OP_MOVE)
 		existing, err := db.GetProject(*name)
 		if err != nil {
-			log.Fatal(err)
+			fail(op, "%v", err)
 		}
 		if existing == nil {
-			log.Fatalf("Project %s not found", *name)
+			fail(op, "Project %s not found", *name)
 		}
 
 		newPath, err := filepath.Abs(*move)
 		if err != nil {
-			log.Fatalf("Invalid path: %v", err)
+			fail(op, "Invalid path: %v", err)
 		}
 
 		if newPath == existing.Path {
 			fmt.Println("New path is the same as the current path.")
+			op.end(nil)
 			return
 		}
 
 		// Update DB
 		err = db.UpdateProjectPath(*name, newPath)
 		if err != nil {
-			log.Fatalf("Failed to update project path in DB: %v", err)
+			fail(op, "Failed to update project path in DB: %v", err)
 		}
 
 		fmt.Printf("Project %s path updated in database.\nOld: %s\nNew: %s\n", *name, existing.Path, newPath)
-		fmt.Println("NOTE: This command only updates the database record. You must move the data files manually if needed.")
+		if existing.Storage == docker.STORAGE_VOLUME {
+			fmt.Println("NOTE: The Postgres and Neo4j data live in named volumes and do not move. Move reports and snapshots manually if needed.")
+		} else {
+			fmt.Println("NOTE: This command only updates the database record. You must move the data files manually if needed.")
+		}
+		op.end(nil)
+		return
+	}
+
+	// Remote Port Forwarding
+	if *connect {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		if err := connectProject(mgr, proj); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Show Credentials
+	if *showCreds {
+		op := beginOperation(db, *name, OP_SHOW_CREDS)
+		creds, err := db.GetCredentials(*name)
+		if err != nil {
+			fail(op, "Failed to read credentials: %v", err)
+		}
+		if creds == nil {
+			fail(op, "No credentials stored for project %s", *name)
+		}
+		op.end(nil)
+		printCredentials(*name, *creds)
+		return
+	}
+
+	// Rotate Credentials
+	if *rotateCreds {
+		op := beginOperation(db, *name, OP_ROTATE_CREDS)
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if proj == nil {
+			fail(op, "Project %s not found", *name)
+		}
+		tunnel := mustTunnel(op, proj, projectPorts(proj))
+		defer tunnel.Close()
+		if err := rotateCredentials(db, mgr, proj); err != nil {
+			fail(op, "Failed to rotate credentials: %v", err)
+		}
+		op.end(nil)
+		creds, _ := db.GetCredentials(*name)
+		fmt.Println("Credentials rotated.")
+		printCredentials(*name, *creds)
+		return
+	}
+
+	// Upgrade Project Images
+	if *upgrade {
+		op := beginOperation(db, *name, OP_UPGRADE)
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if proj == nil {
+			fail(op, "Project %s not found", *name)
+		}
+		resources, err := resolveResources(mgr, proj, sizing)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if err := upgradeProject(db, mgr, proj, resources, *debugFlag); err != nil {
+			fail(op, "%v", err)
+		}
+		op.end(nil)
+		return
+	}
+
+	// Neo4j Major Version Migration
+	if *migrateNeo4jFlag {
+		op := beginOperation(db, *name, OP_MIGRATE_NEO4J)
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if proj == nil {
+			fail(op, "Project %s not found", *name)
+		}
+		if err := migrateNeo4j(db, mgr, proj, *debugFlag); err != nil {
+			fail(op, "%v", err)
+		}
+		op.end(nil)
+		return
+	}
+
+	// Container Logs
+	if *logsRole != "" || *saveLogs {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		if *logsRole == "" {
+			*logsRole = ALL_ROLES
+		}
+		roles, err := logRoles(*logsRole)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts := docker.LogOptions{Tail: *logTail, Since: *logSince, Follow: *follow, Timestamps: *logTimestamps}
+		if *saveLogs {
+			if *follow {
+				log.Fatal("-save-logs cannot be combined with -follow")
+			}
+			dest, err := saveLogBundle(mgr, proj, roles, opts)
+			if err != nil {
+				log.Fatalf("Failed to save logs: %v", err)
+			}
+			fmt.Printf("Logs saved to %s\n", dest)
+			return
+		}
+		if err := mgr.StreamLogs(proj.Name, roles, opts, os.Stdout); err != nil {
+			log.Fatalf("Failed to read logs: %v", err)
+		}
+		return
+	}
+
+	// Storage Migration
+	if *migrateStorage != "" {
+		op := beginOperation(db, *name, OP_MIGRATE_STORAGE)
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if proj == nil {
+			fail(op, "Project %s not found", *name)
+		}
+		if err := migrateProjectStorage(db, mgr, proj, *migrateStorage); err != nil {
+			fail(op, "Storage migration failed: %v", err)
+		}
+		op.end(nil)
+		return
+	}
+
+	// Project Export
+	if *exportProj != "" {
+		op := beginOperation(db, *name, OP_EXPORT)
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			fail(op, "%v", err)
+		}
+		if proj == nil {
+			fail(op, "Project %s not found", *name)
+		}
+		if err := exportProject(db, mgr, proj, *exportProj); err != nil {
+			fail(op, "Failed to export project: %v", err)
+		}
+		op.end(nil)
+		return
+	}
+
+	// Snapshots
+	if *snapshot != "" || *listSnaps || *restore != 0 || *deleteSnap != 0 {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		switch {
+		case *snapshot != "":
+			op := beginOperation(db, proj.Name, OP_SNAPSHOT)
+			err = createSnapshot(db, mgr, proj, *snapshot)
+			op.end(err)
+		case *restore != 0:
+			op := beginOperation(db, proj.Name, OP_RESTORE)
+			err = restoreSnapshot(db, mgr, proj, *restore)
+			op.end(err)
+		case *deleteSnap != 0:
+			op := beginOperation(db, proj.Name, OP_DELETE_SNAPSHOT)
+			err = deleteSnapshot(db, proj, *deleteSnap)
+			op.end(err)
+			if err == nil {
+				fmt.Printf("Snapshot %d deleted.\n", *deleteSnap)
+			}
+		default:
+			err = listSnapshots(db, proj)
+		}
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	// Crash History
+	if *showCrashes {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		if err := printCrashes(db, proj); err != nil {
+			log.Fatalf("%v", err)
+		}
 		return
 	}
 
@@ -244,6 +719,31 @@ func main() {
 	if err != nil {
 		log.Printf("Warning: Failed to check if running: %v", err)
 	}
+
+	// Attach to a running project instead of starting it again
+	if running && (*supervise || *tlsOn) {
+		proj, err := db.GetProject(*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proj == nil {
+			log.Fatalf("Project %s not found", *name)
+		}
+		tunnel := mustTunnel(nil, proj, projectPorts(proj))
+		defer tunnel.Close()
+		var ep *tlsEndpoint
+		if *tlsOn {
+			resolved, err := resolveTLS(db, proj, tlsOpts)
+			if err != nil {
+				log.Fatalf("Invalid TLS settings: %v", err)
+			}
+			ep = &resolved
+		}
+		if err := runForeground(db, mgr, proj, ep, *supervise); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	if running {
 		fmt.Printf("WARNING: Project %s appears to be already running. Starting another instance may fail or cause conflicts.\n", *name)
 		fmt.Print("Press ENTER to continue anyway, or Ctrl+C to abort...")
@@ -256,6 +756,10 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	startOp := beginOperation(db, *name, OP_START)
+	if existing == nil {
+		startOp.name = OP_CREATE
+	}
 
 	if existing != nil {
 		fmt.Printf("Resuming known project %s...\n", existing.Name)
@@ -263,7 +767,7 @@ func main() {
 			absPath, _ := filepath.Abs(*path)
 			if absPath != existing.Path {
 				// Prevent Overwrite - Strict Error
-				log.Fatalf("Error: Project '%s' already exists at '%s'.\nYou provided '%s'.\nUse -move at the command line/terminal to change the project location or omit -path to use the existing one.", existing.Name, existing.Path, absPath)
+				fail(startOp, "Error: Project '%s' already exists at '%s'.\nYou provided '%s'.\nUse -move at the command line/terminal to change the project location or omit -path to use the existing one.", existing.Name, existing.Path, absPath)
 			} else {
 				workingDir = existing.Path
 			}
@@ -271,12 +775,21 @@ func main() {
 			workingDir = existing.Path
 		}
 		fmt.Printf("Project Path: %s\n", workingDir)
+		if *storageMode != "" && *storageMode != existing.Storage {
+			fail(startOp, "Project %s uses %s storage. Use -migrate-storage %s to move its data.", existing.Name, existing.Storage, *storageMode)
+		}
+		if *neo4jVersion != "" && *neo4jVersion != existing.Neo4jVersion {
+			if *neo4jVersion == docker.NEO4J_MAJOR_5 {
+				fail(startOp, "Project %s runs Neo4j %s. Use -migrate-neo4j to move its graph to Neo4j 5.", existing.Name, existing.Neo4jVersion)
+			}
+			fail(startOp, "Project %s runs Neo4j %s and cannot be moved to Neo4j %s.", existing.Name, existing.Neo4jVersion, *neo4jVersion)
+		}
 	} else {
 		// New Project
 		if *path == "" {
 			wd, err := os.Getwd()
 			if err != nil {
-				log.Fatal(err)
+				fail(startOp, "%v", err)
 			}
 			workingDir = wd
 		} else {
@@ -286,118 +799,185 @@ func main() {
 		// Ensure absolute path
 		absPath, err := filepath.Abs(workingDir)
 		if err != nil {
-			log.Fatalf("Failed to resolve absolute path: %v", err)
+			fail(startOp, "Failed to resolve absolute path: %v", err)
 		}
 		workingDir = absPath
 
+		if *storageMode != "" && !docker.ValidStorage(*storageMode) {
+			fail(startOp, "Invalid -storage %q (expected %s or %s)", *storageMode, docker.STORAGE_BIND, docker.STORAGE_VOLUME)
+		}
+		if remoteHost != "" {
+			// The remote engine cannot reach bind folders on this host
+			if *storageMode == docker.STORAGE_BIND {
+				fail(startOp, "Projects on a remote host need %s storage", docker.STORAGE_VOLUME)
+			}
+			*storageMode = docker.STORAGE_VOLUME
+		}
+		if *neo4jVersion != "" && !docker.ValidNeo4jMajor(*neo4jVersion) {
+			fail(startOp, "Invalid -neo4j-version %q (expected %s or %s)", *neo4jVersion, docker.NEO4J_MAJOR_4, docker.NEO4J_MAJOR_5)
+		}
+
 		fmt.Printf("New project %s detected. Registering at %s\n", *name, workingDir)
 		err = db.AddProject(*name, workingDir)
 		if err != nil {
-			log.Fatal(err)
+			fail(startOp, "%v", err)
+		}
+		if *storageMode != "" {
+			if err := db.UpdateProjectStorage(*name, *storageMode); err != nil {
+				fail(startOp, "%v", err)
+			}
+		}
+		if *neo4jVersion != "" {
+			if err := db.UpdateProjectNeo4jVersion(*name, *neo4jVersion); err != nil {
+				fail(startOp, "%v", err)
+			}
+		}
+		if remoteHost != "" {
+			if err := db.UpdateProjectSSHHost(*name, remoteHost); err != nil {
+				fail(startOp, "%v", err)
+			}
+			fmt.Printf("Project %s will run on %s.\n", *name, remoteHost)
 		}
 	}
 
-	// Create Folders
-	createFolders(workingDir)
-
-	// Ensure Network
-	netName, err := mgr.EnsureNetwork(*name)
+	// Resolve host ports
+	ports, err := resolvePorts(db, mgr, *name, docker.Ports{
+		BloodHound: *bhPort,
+		Neo4jHTTP:  *neo4jHTTPPort,
+		Neo4jBolt:  *neo4jBoltPort,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create network: %v", err)
+		fail(startOp, "Failed to allocate ports: %v", err)
 	}
+	fmt.Printf("Ports: BloodHound %d, Neo4j HTTP %d, Neo4j Bolt %d\n", ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt)
 
-	// Image Management
-	images := []string{docker.POSTGRESQL, docker.NEO4J, docker.BLOODHOUND}
-	for _, img := range images {
-		exists, _ := mgr.ImageExists(img)
-		if !exists || *pull {
-			fmt.Printf("Pulling %s...\n", img)
-			if err := mgr.PullImage(img); err != nil {
-				fmt.Printf("Warning: Failed to pull %s: %v\n", img, err)
-			}
-		} else {
-			fmt.Printf("Image %s found locally. Skipping pull.\n", img)
-		}
+	// Load or generate service credentials
+	creds, err := loadCredentials(db, *name, workingDir)
+	if err != nil {
+		fail(startOp, "Failed to load credentials: %v", err)
 	}
 
-	// Start Containers
-	psqlID, err := mgr.SpawnPostgres(*name, workingDir, netName)
+	proj, err := db.GetProject(*name)
 	if err != nil {
-		if *debugFlag {
-			_ = mgr.PrintProjectDiagnostics(*name)
-		}
-		// mgr.StopProjectContainers(*name) // Optional cleanup on fail
-		log.Fatalf("Failed to start Postgres: %v", err)
+		fail(startOp, "%v", err)
+	}
+
+	// Remote projects are probed and audited through SSH tunnels
+	tunnel := mustTunnel(startOp, proj, ports)
+	defer tunnel.Close()
+
+	// Create Folders; volume projects only need the path for reports and snapshots
+	if proj.Storage == docker.STORAGE_VOLUME {
+		os.MkdirAll(workingDir, 0755)
+	} else {
+		createFolders(workingDir)
 	}
-	fmt.Printf("Postgres started (ID: %s)\n", psqlID[:12])
 
-	neo4jID, err := mgr.SpawnNeo4j(*name, workingDir, netName, *neo4jHeap)
+	// Replace containers from older versions that lack ownership labels
+	if n, err := mgr.MigrateLegacyContainers(*name); err != nil {
+		fmt.Printf("Warning: Failed to migrate legacy containers: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("Migrated %d legacy container(s) for project %s.\n", n, *name)
+	}
+
+	// Image Management
+	images, err := projectImages(db, mgr, proj, *pull, *imageBundle, true)
 	if err != nil {
-		if *debugFlag {
-			_ = mgr.PrintProjectDiagnostics(*name)
-		}
-		log.Fatalf("Failed to start Neo4j: %v", err)
+		fail(startOp, "Failed to prepare images: %v", err)
 	}
-	fmt.Printf("Neo4j started (ID: %s) with heap size %s\n", neo4jID[:12], *neo4jHeap)
 
-	bhID, err := mgr.SpawnBloodhound(*name, netName, "admin", "admin")
+	// Resolve sizing; anything not given on the command line keeps the stored value
+	resources, err := resolveResources(mgr, proj, sizing)
 	if err != nil {
-		if *debugFlag {
-			_ = mgr.PrintProjectDiagnostics(*name)
-		}
-		log.Fatalf("Failed to start BloodHound: %v", err)
+		fail(startOp, "Invalid resource settings: %v", err)
 	}
-	fmt.Printf("BloodHound started (ID: %s)\n", bhID[:12])
+	fmt.Printf("Resources: %s\n", formatResources(resources))
 
-	// Update Password Expiration (1 Year)
-	expirationFunc := func() error {
-		expDate := time.Now().AddDate(1, 0, 0).Format("2006-01-02 15:04:05")
-		sql := fmt.Sprintf("UPDATE auth_secrets SET expires_at='%s' WHERE id='1';", expDate)
-		// Execute on Postgres
-		return mgr.Exec(psqlID, []string{"psql", "-q", "-U", "bloodhound", "-d", "bloodhound", "-c", sql})
+	// Neo4j plugins; a new list is stored and used from this start on
+	if sizing.set["neo4j-plugins"] {
+		if err := resolvePlugins(db, proj, *neo4jPlugins); err != nil {
+			fail(startOp, "Invalid plugin settings: %v", err)
+		}
+	}
+	neo4jOpts, err := projectNeo4j(proj)
+	if err != nil {
+		fail(startOp, "Invalid plugin settings: %v", err)
+	}
+	fmt.Printf("Neo4j plugins: %s\n", formatPlugins(neo4jOpts))
+	if unused := unusedJars(neo4jOpts); len(unused) > 0 {
+		fmt.Printf("Note: Ignoring jars for plugins that are not enabled: %s\n", strings.Join(unused, ", "))
 	}
 
-	fmt.Println("Updating password expiration to 1 year...")
-	if err := expirationFunc(); err != nil {
-		fmt.Printf("Warning: Failed to update password expiration: %v\n", err)
+	var tlsEP *tlsEndpoint
+	if *tlsOn {
+		ep, err := resolveTLS(db, proj, tlsOpts)
+		if err != nil {
+			fail(startOp, "Invalid TLS settings: %v", err)
+		}
+		tlsEP = &ep
 	}
 
-	// Inject Queries into Postgres
+	// Read custom queries up front so a bad file fails before anything starts
+	var customQueries importer.BloodHoundQueries
 	if *custom != "" {
-		fmt.Printf("Injecting custom queries from %s...\n", *custom)
 		queries, err := importer.ReadLegacyQueries(*custom)
-		if err == nil {
-			newQueries := importer.LegacyToNewQueries(queries)
-			injectQueries(mgr, psqlID, newQueries)
-		} else {
-			fmt.Printf("Failed to read custom queries: %v\n", err)
+		if err != nil {
+			fail(startOp, "Failed to read custom queries: %v", err)
 		}
+		customQueries = importer.LegacyToNewQueries(queries)
 	}
 
+	// Start the project as one plan; a failed step rolls back the earlier ones
+	// unless -debug asks to keep them for inspection
+	start := &startup{
+		mgr:        mgr,
+		name:       *name,
+		workingDir: workingDir,
+		storage:    proj.Storage,
+		neo4j:      neo4jOpts,
+		images:     images,
+		resources:  resources,
+		creds:      creds,
+		ports:      ports,
+	}
+	startPlan := plan.New()
+	startPlan.Add(start.networkStep())
+	startPlan.Add(start.volumeStep())
+	startPlan.Add(start.containerSteps()...)
+	startPlan.Add(start.expiryStep())
+	if *custom != "" {
+		startPlan.Add(recordedStep(db, *name, OP_INJECT_QUERIES, start.queryStep("Inject custom queries", func() (importer.BloodHoundQueries, bool) {
+			fmt.Printf("Injecting custom queries from %s...\n", *custom)
+			return customQueries, true
+		})))
+	}
 	if *cloneQueries {
-		fmt.Printf("Cloning Query Library...\n")
-		dest := filepath.Join(workingDir, "BloodHoundQueryLibrary")
-		err := importer.CloneQueryLibrary(dest)
-		if err == nil {
-			fmt.Printf("Loading queries from library...\n")
-			queries, err := importer.LoadQueriesFromDir(dest)
-			if err == nil {
-				injectQueries(mgr, psqlID, queries)
-			}
-		} else {
-			fmt.Printf("Failed to clone/load library: %v\n", err)
+		startPlan.Add(recordedStep(db, *name, OP_INJECT_QUERIES, start.queryStep("Inject query library", libraryQueries(workingDir))))
+	}
+
+	if summary, err := startPlan.Run(*debugFlag); err != nil {
+		if *debugFlag {
+			_ = mgr.PrintProjectDiagnostics(*name)
 		}
+		printStartupFailure(summary, *name)
+		fail(startOp, "Startup failed: %v", err)
+	}
+	if err := db.UpdateProjectResources(*name, resources); err != nil {
+		fmt.Printf("Warning: Failed to record resource settings: %v\n", err)
 	}
+	startOp.end(nil)
 
 	// Audit Feature
 	if *auditNTDS != "" && *auditCracked != "" {
 		fmt.Println("Starting Password Audit...")
+		auditOp := beginOperation(db, *name, OP_AUDIT)
 
 		// 1. Parse
 		fmt.Println("Parsing NTDS...")
 		users, err := audit.ParseNTDS(*auditNTDS)
 		if err != nil {
 			fmt.Printf("Failed to parse NTDS: %v\n", err)
+			auditOp.end(err)
 		} else {
 			fmt.Printf("Loaded %d users from NTDS.\n", len(users))
 
@@ -405,6 +985,7 @@ func main() {
 			pot, err := audit.ParsePotfile(*auditCracked)
 			if err != nil {
 				fmt.Printf("Failed to parse cracked file: %v\n", err)
+				auditOp.end(err)
 			} else {
 				fmt.Printf("Loaded %d cracked hashes.\n", len(pot))
 
@@ -414,10 +995,10 @@ func main() {
 				fmt.Printf("Cracked %d/%d users (%.2f%%)\n", stats.CrackedUsers, stats.TotalUsers, stats.CrackedPercentage)
 
 				// 3. Update Neo4j
-				// We need to wait for Neo4j to be fully ready and accepting HTTP
-				// The container is "ready" via logs, but ports might need a second. Use retries.
+				// Startup only finishes once Neo4j answers its HTTP and Bolt
+				// probes, so it is already accepting queries here.
 				fmt.Println("Updating Neo4j with audit data...")
-				graphCli := graph.NewClient("http://127.0.0.1:7474", "neo4j", "bloodhoundcommunityedition") // Default creds
+				graphCli := graph.NewClient(fmt.Sprintf("http://127.0.0.1:%d", ports.Neo4jHTTP), "neo4j", creds.Neo4jPassword)
 
 				// Optional: Wait for connection
 				// For now just try update
@@ -520,7 +1101,9 @@ func main() {
 				// 4. Report
 				reportPath := filepath.Join(workingDir, fmt.Sprintf("AuditReport_%s.html", time.Now().Format("20060102_150405")))
 				fmt.Printf("Generating report at %s...\n", reportPath)
-				if err := report.Generate(reportPath, stats, *auditTemplate); err != nil {
+				err = report.Generate(reportPath, stats, reportEngagement(proj), *auditTemplate)
+				auditOp.end(err)
+				if err != nil {
 					fmt.Printf("Error generating report: %v\n", err)
 				} else {
 					fmt.Println("Report generated successfully!")
@@ -532,11 +1115,111 @@ func main() {
 		fmt.Println("Warning: Both -audit-ntds and -audit-cracked are required for auditing.")
 	}
 
-	fmt.Printf("\nSiloHound is now running in the background.\n")
-	fmt.Printf("URL: http://127.0.0.1:8181\n")
-	fmt.Printf("User: admin\nPass: admin\n\n")
+	if proj.SSHHost != "" {
+		fmt.Printf("\nSiloHound is now running in the background on %s.\n", proj.SSHHost)
+		fmt.Printf("The URLs below work while '%s -name %s -connect' keeps the tunnels open.\n", os.Args[0], *name)
+	} else {
+		fmt.Printf("\nSiloHound is now running in the background.\n")
+	}
+	fmt.Printf("URL: http://127.0.0.1:%d\n", ports.BloodHound)
+	fmt.Printf("Neo4j Browser: http://127.0.0.1:%d (Bolt: %d)\n", ports.Neo4jHTTP, ports.Neo4jBolt)
+	fmt.Printf("User: %s\nPass: %s\n\n", creds.AdminUser, creds.AdminPassword)
 	fmt.Printf("To stop the containers, run:\n")
 	fmt.Printf("  %s -name %s -stop\n", os.Args[0], *name)
+
+	if *supervise || tlsEP != nil {
+		fmt.Println()
+		if err := runForeground(db, mgr, proj, tlsEP, *supervise); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// resolvePorts merges the ports requested on the command line with the ones
+// stored for the project, allocates any that are still missing while avoiding
+// ports claimed by other projects, and persists the result. Requested and
+// stored ports must be free on this host unless the project's own containers
+// hold them.
+func resolvePorts(db *database.Database, mgr *docker.Manager, name string, requested docker.Ports) (docker.Ports, error) {
+	projects, err := db.ListProjects()
+	if err != nil {
+		return docker.Ports{}, err
+	}
+
+	reserved := make(map[int]bool)
+	for _, p := range projects {
+		if p.Name == name {
+			if requested.BloodHound == 0 {
+				requested.BloodHound = p.BHPort
+			}
+			if requested.Neo4jHTTP == 0 {
+				requested.Neo4jHTTP = p.Neo4jHTTPPort
+			}
+			if requested.Neo4jBolt == 0 {
+				requested.Neo4jBolt = p.Neo4jBoltPort
+			}
+			continue
+		}
+		for _, port := range []int{p.BHPort, p.Neo4jHTTPPort, p.Neo4jBoltPort} {
+			if port != 0 {
+				reserved[port] = true
+			}
+		}
+	}
+
+	for _, port := range []int{requested.BloodHound, requested.Neo4jHTTP, requested.Neo4jBolt} {
+		if reserved[port] {
+			return docker.Ports{}, fmt.Errorf("port %d is already assigned to another project", port)
+		}
+	}
+	if err := checkPortsFree(mgr, name, requested); err != nil {
+		return docker.Ports{}, err
+	}
+
+	ports, err := docker.AllocatePorts(requested, reserved)
+	if err != nil {
+		return docker.Ports{}, err
+	}
+
+	if err := db.UpdateProjectPorts(name, ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt); err != nil {
+		return docker.Ports{}, err
+	}
+	return ports, nil
+}
+
+// checkPortsFree fails if a set port in ports is in use on this host by
+// anything but the project's own running containers.
+func checkPortsFree(mgr *docker.Manager, name string, ports docker.Ports) error {
+	containers, err := mgr.InspectProject(name)
+	if err != nil {
+		return err
+	}
+	held := map[int]bool{}
+	for _, c := range containers {
+		if !c.Running {
+			continue
+		}
+		for _, pb := range c.Ports {
+			held[pb.HostPort] = true
+		}
+	}
+	for _, port := range []int{ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt} {
+		if port != 0 && !held[port] && !docker.PortAvailable(port) {
+			return fmt.Errorf("port %d is already in use on this host; free it or choose another port", port)
+		}
+	}
+	return nil
+}
+
+func projectPorts(p *database.Project) docker.Ports {
+	return docker.Ports{BloodHound: p.BHPort, Neo4jHTTP: p.Neo4jHTTPPort, Neo4jBolt: p.Neo4jBoltPort}
+}
+
+func formatPorts(p database.Project) string {
+	if p.BHPort == 0 {
+		return "unassigned"
+	}
+	return fmt.Sprintf("bh=%d neo4j=%d bolt=%d", p.BHPort, p.Neo4jHTTPPort, p.Neo4jBoltPort)
 }
 
 func createFolders(base string) {
@@ -544,41 +1227,38 @@ func createFolders(base string) {
 	os.MkdirAll(filepath.Join(base, "bloodhound-data", "neo4j"), 0755)
 }
 
-func injectQueries(mgr *docker.Manager, psqlID string, queries importer.BloodHoundQueries) {
-	// Check database connectivity. Note: We can't easily capture the query result
-	// to verify the admin user exists, but the INSERT queries below have WHERE EXISTS
-	// clauses that will prevent insertion if the admin user doesn't exist.
-	checkSQL := "SELECT COUNT(*) FROM users WHERE principal_name = 'admin';"
-	checkCmd := []string{"psql", "-t", "-U", "bloodhound", "-d", "bloodhound", "-c", checkSQL}
+// injectQueries adds queries to the admin user's saved queries, skipping
+// names that already exist, and returns the names it inserted. It fails only
+// if the admin user never appears; individual query failures are counted.
+func injectQueries(mgr *docker.Manager, psqlID, adminUser string, queries importer.BloodHoundQueries) ([]string, error) {
+	admin := escapeSQL(adminUser)
+	checkSQL := fmt.Sprintf("SELECT COUNT(*) FROM users WHERE principal_name = '%s';", admin)
 
 	// Wait for admin user (up to 2 minutes)
 	fmt.Println("Waiting for BloodHound initialization (checking for admin user)...")
 	ready := false
+	var lastErr error
 	for i := 0; i < 24; i++ {
-		if err := mgr.Exec(psqlID, checkCmd); err == nil {
-			// Success means the query ran, but we can't easily check output with Exec.
-			// However, if the table 'users' doesn't exist, psql returns error code.
-			// If admin user doesn't exist, insertion query handles it via WHERE EXISTS.
-			// But we really want to wait until table exists at least.
-			// Let's assume if psql command succeeds, DB is good.
-			// But user asked for admin user check.
-			// Since we can't capture stdout easily here without changing Exec
-			// We will rely on the WHERE EXISTS logic but we just wait a bit to ensure DB is likely populated.
-
-			// Actually, let's just proceed. The previous logic had a check.
+		out, err := psqlQuery(mgr, psqlID, checkSQL)
+		if err == nil && out != "0" {
 			ready = true
 			break
 		}
+		// Before BloodHound has migrated the schema the users table does not exist yet
+		lastErr = err
 		time.Sleep(5 * time.Second)
 	}
 
 	if !ready {
-		fmt.Printf("Warning: Database check failed or timed out.\n")
 		fmt.Println("Note: Queries can only be injected after the admin user is created.")
-		fmt.Println("Please log in to BloodHound UI first, then re-run with the -custom flag.")
-		return
+		if lastErr != nil {
+			return nil, fmt.Errorf("database check failed: %w", lastErr)
+		}
+		return nil, fmt.Errorf("admin user %s was not found after 2 minutes", adminUser)
 	}
 
+	var inserted []string
+	skipped, failed := 0, 0
 	for i, q := range queries.Queries {
 		fmt.Printf("Injecting [%d/%d]: %s\n", i+1, len(queries.Queries), q.Name)
 
@@ -588,19 +1268,41 @@ func injectQueries(mgr *docker.Manager, psqlID string, queries importer.BloodHou
 
 		// SQL to insert query
 		sqlQuery := fmt.Sprintf(
-			"INSERT INTO saved_queries (user_id, name, query, description) SELECT (SELECT id FROM users WHERE principal_name = 'admin'), '%s', '%s', '%s' WHERE EXISTS (SELECT 1 FROM users WHERE principal_name = 'admin') AND NOT EXISTS (SELECT 1 FROM saved_queries WHERE name = '%s');",
-			sName, sQuery, sDesc, sName,
+			"INSERT INTO saved_queries (user_id, name, query, description) SELECT (SELECT id FROM users WHERE principal_name = '%s'), '%s', '%s', '%s' WHERE EXISTS (SELECT 1 FROM users WHERE principal_name = '%s') AND NOT EXISTS (SELECT 1 FROM saved_queries WHERE name = '%s');",
+			admin, sName, sQuery, sDesc, admin, sName,
 		)
 
-		cmd := []string{"psql", "-q", "-U", "bloodhound", "-d", "bloodhound", "-c", sqlQuery}
-
-		if err := mgr.Exec(psqlID, cmd); err != nil {
+		out, err := psqlQuery(mgr, psqlID, sqlQuery)
+		switch {
+		case err != nil:
 			fmt.Printf("Failed to inject query '%s': %v\n", q.Name, err)
+			failed++
+		case out == "INSERT 0 1":
+			inserted = append(inserted, q.Name)
+		case out == "INSERT 0 0":
+			skipped++
+		default:
+			fmt.Printf("Unexpected result injecting query '%s': %s\n", q.Name, out)
+			failed++
 		}
 	}
 
-	fmt.Printf("\nQuery injection complete. Processed %d queries.\n", len(queries.Queries))
-	fmt.Println("Note: If queries don't appear in BloodHound, make sure you've logged in to the UI first.")
+	fmt.Printf("\nQuery injection complete. Inserted %d, skipped %d already present, failed %d (of %d).\n", len(inserted), skipped, failed, len(queries.Queries))
+	return inserted, nil
+}
+
+// psqlQuery runs a single SQL statement against the BloodHound database and
+// returns its trimmed output (rows for SELECT, the command tag otherwise).
+func psqlQuery(mgr *docker.Manager, psqlID, sql string) (string, error) {
+	cmd := []string{"psql", "-t", "-A", "-v", "ON_ERROR_STOP=1", "-U", "bloodhound", "-d", "bloodhound", "-c", sql}
+	res, err := mgr.ExecCapture(context.Background(), psqlID, cmd)
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("psql exited with code %d: %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return strings.TrimSpace(res.Stdout), nil
 }
 
 func escapeSQL(s string) string {
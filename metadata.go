@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/report"
+)
+
+// DATE_LAYOUT is how engagement dates are given and stored.
+const DATE_LAYOUT = "2006-01-02"
+
+// metadataFlags are the engagement details from the command line. Only the
+// flags in set were given; everything else keeps the project's stored value.
+// An empty value clears a field.
+type metadataFlags struct {
+	set     map[string]bool
+	client  string
+	start   string
+	end     string
+	tags    string
+	domains string
+	notes   string
+	status  string
+}
+
+var metadataFlagNames = []string{"client", "start-date", "end-date", "tags", "domains", "notes", "project-status"}
+
+// given reports whether any metadata flag was given.
+func (f metadataFlags) given() bool {
+	for _, n := range metadataFlagNames {
+		if f.set[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns m with the given flags applied and checked.
+func (f metadataFlags) apply(m database.Metadata) (database.Metadata, error) {
+	if f.set["client"] {
+		m.Client = strings.TrimSpace(f.client)
+	}
+	if f.set["start-date"] {
+		m.Start = strings.TrimSpace(f.start)
+	}
+	if f.set["end-date"] {
+		m.End = strings.TrimSpace(f.end)
+	}
+	if f.set["tags"] {
+		m.Tags = parseList(f.tags)
+	}
+	if f.set["domains"] {
+		m.Domains = parseList(f.domains)
+	}
+	if f.set["notes"] {
+		m.Notes = strings.TrimSpace(f.notes)
+	}
+	if f.set["project-status"] {
+		m.Status = strings.ToLower(strings.TrimSpace(f.status))
+	}
+
+	if err := checkStatus(m.Status); err != nil {
+		return m, err
+	}
+	var start, end time.Time
+	var err error
+	if m.Start != "" {
+		if start, err = time.Parse(DATE_LAYOUT, m.Start); err != nil {
+			return m, fmt.Errorf("invalid start date %q (expected YYYY-MM-DD)", m.Start)
+		}
+	}
+	if m.End != "" {
+		if end, err = time.Parse(DATE_LAYOUT, m.End); err != nil {
+			return m, fmt.Errorf("invalid end date %q (expected YYYY-MM-DD)", m.End)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return m, fmt.Errorf("engagement ends (%s) before it starts (%s)", m.End, m.Start)
+	}
+	return m, nil
+}
+
+func checkStatus(status string) error {
+	if !slices.Contains(database.Statuses, status) {
+		return fmt.Errorf("unknown project status %q (expected %s)", status, strings.Join(database.Statuses, ", "))
+	}
+	return nil
+}
+
+// updateMetadata stores the given engagement details for a project.
+func updateMetadata(db *database.Database, proj *database.Project, f metadataFlags) error {
+	m, err := f.apply(proj.Metadata())
+	if err != nil {
+		return err
+	}
+	if err := db.UpdateProjectMetadata(proj.Name, m); err != nil {
+		return err
+	}
+	fmt.Printf("Updated project %s.\n", proj.Name)
+	printMetadata(proj.Name, m)
+	return nil
+}
+
+func printMetadata(name string, m database.Metadata) {
+	orNone := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	fmt.Printf("Project %s\n", name)
+	fmt.Printf("  Client:     %s\n", orNone(m.Client))
+	fmt.Printf("  Engagement: %s\n", orNone(formatWindow(m)))
+	fmt.Printf("  Status:     %s\n", m.Status)
+	fmt.Printf("  Domains:    %s\n", orNone(m.Domains))
+	fmt.Printf("  Tags:       %s\n", orNone(m.Tags))
+	if m.Notes != "" {
+		fmt.Printf("  Notes:      %s\n", strings.ReplaceAll(m.Notes, "\n", "\n              "))
+	}
+}
+
+// formatWindow describes the engagement window, e.g. "2025-07-01 to
+// 2025-07-31" or "from 2025-07-01". It is empty when no dates are set.
+func formatWindow(m database.Metadata) string {
+	switch {
+	case m.Start != "" && m.End != "":
+		return m.Start + " to " + m.End
+	case m.Start != "":
+		return "from " + m.Start
+	case m.End != "":
+		return "until " + m.End
+	}
+	return ""
+}
+
+// matchesFilter reports whether a project matches the -list filters: a
+// client name (case-insensitive substring) and a lifecycle status.
+func matchesFilter(p database.Project, client, status string) bool {
+	if client != "" && !strings.Contains(strings.ToLower(p.Client), strings.ToLower(client)) {
+		return false
+	}
+	return status == "" || strings.EqualFold(p.Status, status)
+}
+
+// reportEngagement heads an audit report with the project's details.
+func reportEngagement(proj *database.Project) report.Engagement {
+	eng := report.Engagement{
+		Project: proj.Name,
+		Client:  proj.Client,
+		Window:  formatWindow(proj.Metadata()),
+		Status:  proj.Status,
+	}
+	if proj.Domains != "" {
+		eng.Domains = strings.Split(proj.Domains, ",")
+	}
+	return eng
+}
+
+// parseList normalizes a comma-separated list of tags or domains: trimmed,
+// lower case, without duplicates or empty entries.
+func parseList(s string) string {
+	var items []string
+	seen := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			items = append(items, t)
+		}
+	}
+	return strings.Join(items, ",")
+}
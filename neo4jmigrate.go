@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/graph"
+)
+
+// MIGRATE_DIR is where the 4.4 dump is staged inside the Neo4j data folder.
+const MIGRATE_DIR = "/data/.migrate"
+
+// graphCounts is the size of a project's graph.
+type graphCounts struct {
+	nodes, edges int64
+}
+
+// migrateNeo4j moves a project's graph from Neo4j 4.4 to Neo4j 5. The store
+// is dumped with the 4.4 neo4j-admin, loaded and migrated with the 5.x one in
+// throwaway containers, and the project is brought up on Neo4j 5. The data
+// directory is backed up first and restored if anything fails or the node
+// and edge counts differ afterwards.
+func migrateNeo4j(db *database.Database, mgr *docker.Manager, proj *database.Project, debug bool) error {
+	if err := requireLocal(proj, "Migrating Neo4j"); err != nil {
+		return err
+	}
+	if proj.Neo4jVersion == docker.NEO4J_MAJOR_5 {
+		fmt.Printf("Project %s already runs Neo4j %s.\n", proj.Name, proj.Neo4jVersion)
+		return nil
+	}
+	current := pinnedImages(proj)
+	if current.IsZero() {
+		return fmt.Errorf("project %s has no pinned images yet; start it once before migrating", proj.Name)
+	}
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return fmt.Errorf("no credentials stored for project %s; start it once first", proj.Name)
+	}
+
+	// Fetch Neo4j 5 before touching anything so a failed pull costs no downtime
+	target := current
+	target.Neo4j = docker.NEO4J_5
+	local, err := ensureImages(mgr, target, false, "", true)
+	if err != nil {
+		return err
+	}
+	next, err := mgr.ResolveImages(local)
+	if err != nil {
+		return err
+	}
+
+	// The counts need a running database
+	wasRunning, err := mgr.IsRunning(proj.Name)
+	if err != nil {
+		return err
+	}
+	if !wasRunning {
+		if err := restartProject(db, mgr, proj); err != nil {
+			return fmt.Errorf("failed to start project to count the graph: %w", err)
+		}
+	}
+	before, err := countGraph(proj, creds.Neo4jPassword)
+	if err != nil {
+		return fmt.Errorf("failed to count the graph before migrating: %w", err)
+	}
+	fmt.Printf("Neo4j %s graph: %d nodes, %d edges\n", proj.Neo4jVersion, before.nodes, before.edges)
+
+	fmt.Printf("Stopping containers for project %s...\n", proj.Name)
+	if err := mgr.StopProjectContainers(proj.Name); err != nil {
+		return fmt.Errorf("failed to stop containers: %w", err)
+	}
+
+	backup := fmt.Sprintf("bloodhound-data.backup-%s.tar.gz", time.Now().Format("20060102_150405"))
+	fmt.Printf("Backing up bloodhound-data to %s...\n", backup)
+	if _, err := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", archiveDataScript(proj.Storage, backup)}); err != nil {
+		return fmt.Errorf("backup failed, migration aborted: %w", err)
+	}
+
+	after, err := convertNeo4jStore(db, mgr, proj, current.Neo4j, next.Neo4j)
+	if err == nil && after != before {
+		err = fmt.Errorf("graph changed during migration: %d nodes, %d edges before; %d nodes, %d edges after", before.nodes, before.edges, after.nodes, after.edges)
+	}
+	if err != nil {
+		if debug {
+			_ = mgr.PrintProjectDiagnostics(proj.Name)
+		}
+		fmt.Printf("Migration failed: %v\n", err)
+		fmt.Println("Rolling back...")
+		if rbErr := rollbackNeo4jMigration(db, mgr, proj, current, backup); rbErr != nil {
+			return fmt.Errorf("migration failed (%v) and rollback failed: %w; the backup is at %s", err, rbErr, filepath.Join(proj.Path, backup))
+		}
+		if wasRunning {
+			if rsErr := restartProject(db, mgr, proj); rsErr != nil {
+				fmt.Printf("Warning: Failed to restart project on Neo4j %s: %v\n", proj.Neo4jVersion, rsErr)
+			}
+		}
+		return fmt.Errorf("migration failed and was rolled back to Neo4j %s: %w", proj.Neo4jVersion, err)
+	}
+	fmt.Printf("Neo4j %s graph: %d nodes, %d edges\n", proj.Neo4jVersion, after.nodes, after.edges)
+
+	if !wasRunning {
+		fmt.Printf("Stopping containers for project %s...\n", proj.Name)
+		if err := mgr.StopProjectContainers(proj.Name); err != nil {
+			fmt.Printf("Warning: Failed to stop containers: %v\n", err)
+		}
+	}
+	fmt.Printf("Migration complete. The pre-migration backup is kept at %s\n", filepath.Join(proj.Path, backup))
+	return nil
+}
+
+// convertNeo4jStore dumps the stopped project's graph with oldImage, loads and
+// migrates it with newImage, records the new version and starts the project
+// on it. It returns the graph counts after the start.
+func convertNeo4jStore(db *database.Database, mgr *docker.Manager, proj *database.Project, oldImage, newImage string) (graphCounts, error) {
+	fmt.Println("Dumping the Neo4j 4.4 store...")
+	dump := fmt.Sprintf("mkdir -p %[1]s && neo4j-admin dump --database=neo4j --to=%[1]s/neo4j.dump", MIGRATE_DIR)
+	if _, err := mgr.RunNeo4jAdmin(proj.Name, proj.Path, proj.Storage, oldImage, dump); err != nil {
+		return graphCounts{}, err
+	}
+
+	// The system database is recreated by Neo4j 5 and sets the stored
+	// password again from the environment
+	fmt.Println("Loading and migrating the store with Neo4j 5...")
+	convert := fmt.Sprintf("find /data -mindepth 1 -maxdepth 1 ! -name .migrate -exec rm -rf {} + && "+
+		"neo4j-admin database load --from-path=%[1]s --overwrite-destination=true neo4j && "+
+		"neo4j-admin database migrate --force-btree-indexes-to-range neo4j && "+
+		"rm -rf %[1]s", MIGRATE_DIR)
+	if _, err := mgr.RunNeo4jAdmin(proj.Name, proj.Path, proj.Storage, newImage, convert); err != nil {
+		return graphCounts{}, err
+	}
+
+	if err := db.UpdateProjectImages(proj.Name, proj.BHImage, newImage, proj.PostgresImage); err != nil {
+		return graphCounts{}, err
+	}
+	if err := db.UpdateProjectNeo4jVersion(proj.Name, docker.NEO4J_MAJOR_5); err != nil {
+		return graphCounts{}, err
+	}
+	proj.Neo4jImage, proj.Neo4jVersion = newImage, docker.NEO4J_MAJOR_5
+
+	if err := restartProject(db, mgr, proj); err != nil {
+		return graphCounts{}, err
+	}
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return graphCounts{}, err
+	}
+	return countGraph(proj, creds.Neo4jPassword)
+}
+
+// rollbackNeo4jMigration restores the backup and points the project at its
+// previous Neo4j image and version again.
+func rollbackNeo4jMigration(db *database.Database, mgr *docker.Manager, proj *database.Project, previous docker.Images, backup string) error {
+	if err := rollbackUpgrade(mgr, proj, backup); err != nil {
+		return err
+	}
+	if err := db.UpdateProjectImages(proj.Name, previous.BloodHound, previous.Neo4j, previous.Postgres); err != nil {
+		return err
+	}
+	if err := db.UpdateProjectNeo4jVersion(proj.Name, docker.NEO4J_MAJOR_4); err != nil {
+		return err
+	}
+	proj.BHImage, proj.Neo4jImage, proj.PostgresImage = previous.BloodHound, previous.Neo4j, previous.Postgres
+	proj.Neo4jVersion = docker.NEO4J_MAJOR_4
+	return nil
+}
+
+// countGraph counts the nodes and edges of a running local project's graph.
+func countGraph(proj *database.Project, neo4jPass string) (graphCounts, error) {
+	cli := graph.NewClient(fmt.Sprintf("http://127.0.0.1:%d", proj.Neo4jHTTPPort), "neo4j", neo4jPass)
+	nodes, edges, err := cli.CountGraph()
+	if err != nil {
+		return graphCounts{}, err
+	}
+	return graphCounts{nodes: nodes, edges: edges}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// PLUGIN_DIR holds vendored Neo4j plugin jars below the project path. Jars
+// found there are used instead of downloading the plugin at startup.
+const PLUGIN_DIR = "plugins"
+
+// resolvePlugins stores the plugin list given with -neo4j-plugins for the
+// project. The change takes effect the next time Neo4j starts.
+func resolvePlugins(db *database.Database, proj *database.Project, list string) error {
+	plugins, err := docker.ParsePlugins(list)
+	if err != nil {
+		return err
+	}
+	joined := strings.Join(plugins, ",")
+	if err := db.UpdateProjectNeo4jPlugins(proj.Name, joined); err != nil {
+		return err
+	}
+	proj.Neo4jPlugins = joined
+	return nil
+}
+
+// projectNeo4j returns the Neo4j version and plugins a project starts with,
+// picking up any vendored plugin jars in its plugin folder.
+func projectNeo4j(proj *database.Project) (docker.Neo4jOptions, error) {
+	plugins, err := docker.ParsePlugins(proj.Neo4jPlugins)
+	if err != nil {
+		return docker.Neo4jOptions{}, fmt.Errorf("project %s: %w", proj.Name, err)
+	}
+	// A remote engine cannot mount jars from this host, so remote projects
+	// always download their plugins.
+	var vendored map[string]string
+	if proj.SSHHost == "" {
+		if vendored, err = docker.VendoredPlugins(filepath.Join(proj.Path, PLUGIN_DIR)); err != nil {
+			return docker.Neo4jOptions{}, err
+		}
+	}
+	return docker.Neo4jOptions{Version: proj.Neo4jVersion, Plugins: plugins, Vendored: vendored}, nil
+}
+
+// formatPlugins describes enabled plugins and where each comes from.
+func formatPlugins(opts docker.Neo4jOptions) string {
+	if len(opts.Plugins) == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, p := range opts.Plugins {
+		if jar := opts.Vendored[p]; jar != "" {
+			p += " (vendored " + filepath.Base(jar) + ")"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// unusedJars lists vendored jars for plugins the project does not enable.
+func unusedJars(opts docker.Neo4jOptions) []string {
+	var out []string
+	for p, jar := range opts.Vendored {
+		enabled := false
+		for _, e := range opts.Plugins {
+			enabled = enabled || e == p
+		}
+		if !enabled {
+			out = append(out, filepath.Base(jar))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
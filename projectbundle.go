@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/bundle"
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/importer"
+)
+
+const (
+	DATA_DIR     = "bloodhound-data"
+	IMPORT_STAGE = ".silohound-import"
+)
+
+// savedQueriesSQL dumps BloodHound's saved queries in the format -custom and
+// the query library use.
+const savedQueriesSQL = `SELECT COALESCE(json_agg(json_build_object('name', name, 'description', description, 'query', query) ORDER BY id), '[]') FROM saved_queries;`
+
+// exportProject packs the project's data, saved queries, audit reports,
+// settings and credentials into one bundle at dest.
+func exportProject(db *database.Database, mgr *docker.Manager, proj *database.Project, dest string) error {
+	if err := requireLocal(proj, "Exporting"); err != nil {
+		return err
+	}
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return fmt.Errorf("no credentials stored for project %s; start it once before exporting", proj.Name)
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp("", "silohound-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	files := map[string]string{}
+
+	// Saved queries are only readable while Postgres is up; they are in the
+	// data archive either way, the JSON copy is for people and other tools.
+	if psqlID, _ := mgr.ContainerID(proj.Name, docker.ROLE_POSTGRES); psqlID != "" {
+		fmt.Println("Exporting saved queries...")
+		if path, err := dumpSavedQueries(mgr, psqlID, staging); err != nil {
+			fmt.Printf("Warning: Failed to export saved queries: %v\n", err)
+		} else {
+			files[bundle.QUERIES_FILE] = path
+		}
+	} else {
+		fmt.Println("Note: Project is not running; saved queries are exported with the data only.")
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(proj.Path, "AuditReport_*.html"))
+	for _, r := range reports {
+		files[bundle.REPORTS_DIR+"/"+filepath.Base(r)] = r
+	}
+
+	wasRunning, err := stopForData(mgr, proj.Name)
+	if err != nil {
+		return err
+	}
+
+	archive := ".export-data.tar.gz"
+	fmt.Printf("Archiving %s...\n", DATA_DIR)
+	script := fmt.Sprintf("tar --numeric-owner -czf /data/%[1]s.partial -C %[5]s %[2]s && chown %[3]d:%[4]d /data/%[1]s.partial && mv /data/%[1]s.partial /data/%[1]s",
+		archive, DATA_DIR, os.Getuid(), os.Getgid(), docker.DataRoot(proj.Storage))
+	_, archiveErr := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", script})
+
+	if wasRunning {
+		if err := restartProject(db, mgr, proj); err != nil {
+			fmt.Printf("Warning: Failed to restart project: %v\n", err)
+		}
+	}
+	archivePath := filepath.Join(proj.Path, archive)
+	defer os.Remove(archivePath)
+	if archiveErr != nil {
+		os.Remove(archivePath + ".partial")
+		return fmt.Errorf("failed to archive data: %w", archiveErr)
+	}
+	files[bundle.DATA_FILE] = archivePath
+
+	manifest := &bundle.Manifest{
+		CreatedAt: time.Now().UTC(),
+		Project: bundle.Project{
+			Name:            proj.Name,
+			CreatedAt:       proj.CreatedAt,
+			Neo4jHeap:       proj.Neo4jHeap,
+			Neo4jPlugins:    proj.Neo4jPlugins,
+			Neo4jPageCache:  proj.Neo4jPageCache,
+			CPULimit:        proj.CPULimit,
+			PostgresMemory:  proj.PostgresMemory,
+			Neo4jMemory:     proj.Neo4jMemory,
+			BHMemory:        proj.BHMemory,
+			Client:          proj.Client,
+			EngagementStart: proj.EngagementStart,
+			EngagementEnd:   proj.EngagementEnd,
+			Domains:         proj.Domains,
+			Tags:            proj.Tags,
+			Notes:           proj.Notes,
+			Status:          proj.Status,
+		},
+		Images: bundle.Images{
+			BloodHound:      proj.BHImage,
+			Neo4j:           proj.Neo4jImage,
+			Postgres:        proj.PostgresImage,
+			Neo4jVersion:    docker.ReferenceTag(docker.Neo4jImage(proj.Neo4jVersion)),
+			PostgresVersion: docker.ReferenceTag(docker.POSTGRESQL),
+		},
+		Credentials: *creds,
+	}
+	fmt.Printf("Writing bundle to %s...\n", dest)
+	if err := bundle.Write(dest, manifest, files); err != nil {
+		return err
+	}
+
+	fmt.Printf("Project %s exported to %s (%d file(s)).\n", proj.Name, dest, len(manifest.Files))
+	fmt.Println("WARNING: The bundle contains the project's service credentials. Share it only over trusted channels.")
+	return nil
+}
+
+func dumpSavedQueries(mgr *docker.Manager, psqlID, dir string) (string, error) {
+	out, err := psqlQuery(mgr, psqlID, savedQueriesSQL)
+	if err != nil {
+		return "", err
+	}
+	var queries importer.BloodHoundQueries
+	if err := json.Unmarshal([]byte(out), &queries.Queries); err != nil {
+		return "", fmt.Errorf("unexpected saved query output: %w", err)
+	}
+	b, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, bundle.QUERIES_FILE)
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return "", err
+	}
+	fmt.Printf("Exported %d saved queries.\n", len(queries.Queries))
+	return path, nil
+}
+
+// importProject unpacks a bundle into dest and registers it as project name.
+// Either may be empty to use the bundle's project name and the current
+// directory. It returns the name the project is imported as, once known,
+// also when the import fails.
+func importProject(db *database.Database, mgr *docker.Manager, src, name, dest string) (string, error) {
+	manifest, err := bundle.ReadManifest(src)
+	if err != nil {
+		return "", err
+	}
+	// Data from either supported Neo4j major version can be imported as is
+	neo4jMajor := strings.SplitN(manifest.Images.Neo4jVersion, ".", 2)[0]
+	if !docker.ValidNeo4jMajor(neo4jMajor) {
+		neo4jMajor = docker.DEFAULT_NEO4J_MAJOR
+	}
+	if err := bundle.CheckCompatible(manifest, docker.ReferenceTag(docker.POSTGRESQL), docker.ReferenceTag(docker.Neo4jImage(neo4jMajor))); err != nil {
+		return "", fmt.Errorf("refusing to import: %w", err)
+	}
+	plugins, err := docker.ParsePlugins(manifest.Project.Neo4jPlugins)
+	if err != nil {
+		return "", fmt.Errorf("refusing to import: %w", err)
+	}
+
+	if name == "" {
+		name = manifest.Project.Name
+	}
+	if existing, err := db.GetProject(name); err != nil {
+		return name, err
+	} else if existing != nil {
+		return name, fmt.Errorf("project %s already exists at %s; use -name to import under a different name", name, existing.Path)
+	}
+	if dest == "" {
+		if dest, err = os.Getwd(); err != nil {
+			return name, err
+		}
+	}
+	if dest, err = filepath.Abs(dest); err != nil {
+		return name, err
+	}
+	if _, err := os.Stat(filepath.Join(dest, DATA_DIR)); err == nil {
+		return name, fmt.Errorf("%s already contains %s; choose an empty -path", dest, DATA_DIR)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return name, err
+	}
+
+	fmt.Printf("Importing project %s (exported %s by SiloHound %s) into %s...\n",
+		manifest.Project.Name, manifest.CreatedAt.Format(time.RFC822), manifest.SiloHoundVersion, dest)
+	staging := filepath.Join(dest, IMPORT_STAGE)
+	if err := os.RemoveAll(staging); err != nil {
+		return name, err
+	}
+	defer os.RemoveAll(staging)
+	if _, err := bundle.Extract(src, staging); err != nil {
+		return name, err
+	}
+
+	// The data keeps the container users' numeric ownership, so unpack it
+	// as root inside the toolbox.
+	fmt.Printf("Restoring %s...\n", DATA_DIR)
+	script := fmt.Sprintf("tar --numeric-owner -xzf /data/%s/%s -C /data", IMPORT_STAGE, bundle.DATA_FILE)
+	if _, err := mgr.RunToolbox(dest, []string{"sh", "-c", script}); err != nil {
+		mgr.RunToolbox(dest, []string{"rm", "-rf", "/data/" + DATA_DIR})
+		return name, fmt.Errorf("failed to restore data: %w", err)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(staging, bundle.REPORTS_DIR, "*.html"))
+	for _, r := range reports {
+		if err := os.Rename(r, filepath.Join(dest, filepath.Base(r))); err != nil {
+			fmt.Printf("Warning: Failed to restore %s: %v\n", filepath.Base(r), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(staging, bundle.QUERIES_FILE)); err == nil {
+		if err := os.Rename(filepath.Join(staging, bundle.QUERIES_FILE), filepath.Join(dest, "saved-queries.json")); err != nil {
+			fmt.Printf("Warning: Failed to restore saved queries: %v\n", err)
+		}
+	}
+
+	if err := db.AddProject(name, dest); err != nil {
+		return name, err
+	}
+	img := manifest.Images
+	if err := db.UpdateProjectImages(name, img.BloodHound, img.Neo4j, img.Postgres); err != nil {
+		return name, err
+	}
+	if err := db.UpdateProjectNeo4jVersion(name, neo4jMajor); err != nil {
+		return name, err
+	}
+	if err := db.UpdateProjectNeo4jPlugins(name, strings.Join(plugins, ",")); err != nil {
+		return name, err
+	}
+	p := manifest.Project
+	res := database.Resources{
+		Neo4jHeap:      p.Neo4jHeap,
+		Neo4jPageCache: p.Neo4jPageCache,
+		CPULimit:       p.CPULimit,
+		PostgresMemory: p.PostgresMemory,
+		Neo4jMemory:    p.Neo4jMemory,
+		BHMemory:       p.BHMemory,
+	}
+	if err := db.UpdateProjectResources(name, res); err != nil {
+		return name, err
+	}
+	if err := db.UpdateProjectMetadata(name, bundleMetadata(manifest.Project)); err != nil {
+		return name, err
+	}
+	if err := db.SetCredentials(name, manifest.Credentials); err != nil {
+		return name, err
+	}
+
+	fmt.Printf("Project %s imported with %d audit report(s).\n", name, len(reports))
+	fmt.Printf("Start it with: %s -name %s\n", os.Args[0], name)
+	return name, nil
+}
+
+// bundleMetadata is the engagement details of a bundled project. Bundles
+// without a status, or with one this version does not know, import as active.
+func bundleMetadata(p bundle.Project) database.Metadata {
+	m := database.Metadata{
+		Client:  p.Client,
+		Start:   p.EngagementStart,
+		End:     p.EngagementEnd,
+		Tags:    p.Tags,
+		Domains: p.Domains,
+		Notes:   p.Notes,
+		Status:  p.Status,
+	}
+	if checkStatus(m.Status) != nil {
+		m.Status = database.STATUS_ACTIVE
+	}
+	return m
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/remote"
+)
+
+// TUNNEL_TIMEOUT bounds how long the SSH port forwards may take to open.
+const TUNNEL_TIMEOUT = 30 * time.Second
+
+// openTunnels forwards a remote project's published ports to the same ports
+// on this host's loopback, so the readiness probes, the audit and the printed
+// URLs work unchanged. Local projects need no tunnel and get nil.
+func openTunnels(proj *database.Project, ports docker.Ports) (*remote.Tunnel, error) {
+	if proj.SSHHost == "" {
+		return nil, nil
+	}
+	target, err := remote.ParseTarget(proj.SSHHost)
+	if err != nil {
+		return nil, err
+	}
+	list := []int{ports.BloodHound, ports.Neo4jHTTP, ports.Neo4jBolt}
+	fmt.Printf("Forwarding ports %s from %s over SSH...\n", joinPorts(list), target)
+	return target.OpenTunnel(list, TUNNEL_TIMEOUT)
+}
+
+// mustTunnel is openTunnels for command handlers: it fails op and exits if
+// the tunnel cannot be opened. Close the result when the command is done.
+func mustTunnel(op *operation, proj *database.Project, ports docker.Ports) *remote.Tunnel {
+	tunnel, err := openTunnels(proj, ports)
+	if err != nil {
+		fail(op, "Failed to reach remote project %s: %v", proj.Name, err)
+	}
+	return tunnel
+}
+
+// requireLocal rejects operations that move project data through this host's
+// filesystem, which the remote engine cannot reach.
+func requireLocal(proj *database.Project, what string) error {
+	if proj.SSHHost != "" {
+		return fmt.Errorf("%s is not supported for project %s, which runs on remote host %s", what, proj.Name, proj.SSHHost)
+	}
+	return nil
+}
+
+// connectProject keeps tunnels to a running remote project open until
+// interrupted, so its UI and Neo4j can be used from this machine.
+func connectProject(mgr *docker.Manager, proj *database.Project) error {
+	if proj.SSHHost == "" {
+		return fmt.Errorf("project %s runs on this host; its ports are reachable without -connect", proj.Name)
+	}
+	running, err := mgr.IsRunning(proj.Name)
+	if err != nil {
+		return err
+	}
+	if !running {
+		return fmt.Errorf("project %s is not running on %s", proj.Name, proj.SSHHost)
+	}
+
+	ports := projectPorts(proj)
+	tunnel, err := openTunnels(proj, ports)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+
+	fmt.Printf("Connected to project %s on %s.\n", proj.Name, proj.SSHHost)
+	fmt.Printf("URL: http://127.0.0.1:%d\n", ports.BloodHound)
+	fmt.Printf("Neo4j Browser: http://127.0.0.1:%d (Bolt: %d)\n", ports.Neo4jHTTP, ports.Neo4jBolt)
+	fmt.Println("Press Ctrl+C to close the tunnels.")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	select {
+	case <-stop:
+		fmt.Println("\nClosing tunnels.")
+		return nil
+	case <-tunnel.Done():
+		return fmt.Errorf("tunnel closed: %v", tunnel.Err())
+	}
+}
+
+func joinPorts(ports []int) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ", ")
+}
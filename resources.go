@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// AUTO_SIZE asks for the Neo4j heap and page cache to be sized from host RAM
+// and the graph store.
+const AUTO_SIZE = "auto"
+
+// NEO4J_MEMORY_OVERHEAD is what the Neo4j JVM needs beyond heap and page
+// cache (metaspace, threads, direct buffers).
+const NEO4J_MEMORY_OVERHEAD = 512 * docker.MiB
+
+// sizingFlags are the sizing options from the command line. Only the flags
+// in set were given; everything else keeps the project's stored value.
+type sizingFlags struct {
+	set       map[string]bool
+	heap      string
+	pageCache string
+	cpus      float64
+	psqlMem   string
+	neo4jMem  string
+	bhMem     string
+}
+
+// resolveResources merges the given flags into the project's stored sizing,
+// resolves auto sizing and validates the result.
+func resolveResources(mgr *docker.Manager, proj *database.Project, f sizingFlags) (database.Resources, error) {
+	r := proj.Resources()
+	if f.set["neo4j-heap"] {
+		r.Neo4jHeap = f.heap
+		if f.heap == AUTO_SIZE && !f.set["neo4j-pagecache"] {
+			r.Neo4jPageCache = AUTO_SIZE
+		}
+	}
+	if f.set["neo4j-pagecache"] {
+		r.Neo4jPageCache = f.pageCache
+	}
+	if f.set["cpus"] {
+		r.CPULimit = f.cpus
+	}
+	if f.set["postgres-memory"] {
+		r.PostgresMemory = f.psqlMem
+	}
+	if f.set["neo4j-memory"] {
+		r.Neo4jMemory = f.neo4jMem
+	}
+	if f.set["bh-memory"] {
+		r.BHMemory = f.bhMem
+	}
+	if r.Neo4jHeap == "" {
+		r.Neo4jHeap = DEFAULT_NEO4J_HEAP
+	}
+
+	if r.Neo4jHeap == AUTO_SIZE || r.Neo4jPageCache == AUTO_SIZE {
+		engine, err := mgr.EngineInfo()
+		if err != nil {
+			return r, fmt.Errorf("auto sizing needs the host memory: %w", err)
+		}
+		graph := projectDataSizes(mgr, proj)[docker.ROLE_NEO4J]
+		auto := docker.AutoNeo4jMemory(engine.MemTotal, graph.Bytes)
+		if r.Neo4jHeap == AUTO_SIZE {
+			r.Neo4jHeap = auto.Heap
+		}
+		if r.Neo4jPageCache == AUTO_SIZE {
+			r.Neo4jPageCache = auto.PageCache
+		}
+		fmt.Printf("Auto-sized Neo4j memory from %s host RAM and a %s graph: heap %s, page cache %s\n",
+			formatSize(dataSize{Bytes: engine.MemTotal}), formatSize(graph), r.Neo4jHeap, r.Neo4jPageCache)
+	}
+
+	// "0" and "none" remove a limit
+	for _, mem := range []*string{&r.PostgresMemory, &r.Neo4jMemory, &r.BHMemory} {
+		if *mem == "0" || *mem == "none" {
+			*mem = ""
+		}
+	}
+	if r.CPULimit < 0 {
+		return r, fmt.Errorf("invalid -cpus %v", r.CPULimit)
+	}
+
+	heap, err := docker.ParseSize(r.Neo4jHeap)
+	if err != nil {
+		return r, fmt.Errorf("invalid Neo4j heap: %w", err)
+	}
+	var pageCache int64
+	if r.Neo4jPageCache != "" {
+		if pageCache, err = docker.ParseSize(r.Neo4jPageCache); err != nil {
+			return r, fmt.Errorf("invalid Neo4j page cache: %w", err)
+		}
+	}
+	for _, mem := range []string{r.PostgresMemory, r.Neo4jMemory, r.BHMemory} {
+		if _, err := memoryLimit(mem); err != nil {
+			return r, err
+		}
+	}
+	if limit, _ := memoryLimit(r.Neo4jMemory); limit > 0 && limit < heap+pageCache+NEO4J_MEMORY_OVERHEAD {
+		return r, fmt.Errorf("Neo4j memory limit %s is too small for heap %s plus page cache %s; raise -neo4j-memory or lower the heap", r.Neo4jMemory, r.Neo4jHeap, r.Neo4jPageCache)
+	}
+	return r, nil
+}
+
+// storedResources is the sizing a project resumes with when no flags apply.
+func storedResources(proj *database.Project) database.Resources {
+	r := proj.Resources()
+	if r.Neo4jHeap == "" {
+		r.Neo4jHeap = DEFAULT_NEO4J_HEAP
+	}
+	return r
+}
+
+func memoryLimit(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := docker.ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit: %w", err)
+	}
+	return n, nil
+}
+
+// roleLimits returns the container limits for role. The sizes were
+// validated by resolveResources.
+func roleLimits(r database.Resources, role string) docker.Limits {
+	mem := map[string]string{
+		docker.ROLE_POSTGRES:   r.PostgresMemory,
+		docker.ROLE_NEO4J:      r.Neo4jMemory,
+		docker.ROLE_BLOODHOUND: r.BHMemory,
+	}[role]
+	limit, _ := memoryLimit(mem)
+	return docker.Limits{CPUs: r.CPULimit, Memory: limit}
+}
+
+func neo4jMemory(r database.Resources) docker.Neo4jMemory {
+	return docker.Neo4jMemory{Heap: r.Neo4jHeap, PageCache: r.Neo4jPageCache}
+}
+
+func formatResources(r database.Resources) string {
+	s := fmt.Sprintf("Neo4j heap %s", r.Neo4jHeap)
+	if r.Neo4jPageCache != "" {
+		s += fmt.Sprintf(", page cache %s", r.Neo4jPageCache)
+	}
+	if r.CPULimit > 0 {
+		s += fmt.Sprintf(", %g CPUs per container", r.CPULimit)
+	}
+	for _, l := range []struct{ name, mem string }{{"postgres", r.PostgresMemory}, {"neo4j", r.Neo4jMemory}, {"bh", r.BHMemory}} {
+		if l.mem != "" {
+			s += fmt.Sprintf(", %s memory %s", l.name, l.mem)
+		}
+	}
+	return s
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+const SNAPSHOT_DIR = "snapshots"
+
+// snapshotDirs are archived relative to the project path.
+var snapshotDirs = []string{docker.PSQLFOLDER, docker.NEO4JFOLDER}
+
+var unsafeLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// createSnapshot archives the project's Postgres and Neo4j data. The project
+// is stopped for the copy so both databases are consistent, and started again
+// afterwards if it was running.
+func createSnapshot(db *database.Database, mgr *docker.Manager, proj *database.Project, label string) error {
+	if err := requireLocal(proj, "Taking snapshots"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(proj.Path, SNAPSHOT_DIR), 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	file := filepath.Join(SNAPSHOT_DIR, fmt.Sprintf("%s_%s.tar.gz", now.Format("20060102_150405"), unsafeLabelChars.ReplaceAllString(label, "_")))
+
+	wasRunning, err := stopForData(mgr, proj.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Archiving %s to %s...\n", strings.Join(snapshotDirs, " and "), file)
+	_, archiveErr := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", archiveDataScript(proj.Storage, file)})
+
+	if wasRunning {
+		if err := restartProject(db, mgr, proj); err != nil {
+			fmt.Printf("Warning: Failed to restart project: %v\n", err)
+		}
+	}
+	if archiveErr != nil {
+		os.Remove(filepath.Join(proj.Path, file+".partial"))
+		return fmt.Errorf("snapshot failed: %w", archiveErr)
+	}
+
+	size, sum, err := fileChecksum(filepath.Join(proj.Path, file))
+	if err != nil {
+		return err
+	}
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	id, err := db.AddSnapshot(database.Snapshot{
+		Project:     proj.Name,
+		Label:       label,
+		File:        file,
+		Size:        size,
+		SHA256:      sum,
+		CreatedAt:   now,
+		Credentials: creds,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Snapshot %d (%s) created: %s, sha256 %s\n", id, label, formatSize(dataSize{Bytes: size}), sum)
+	return nil
+}
+
+// restoreSnapshot replaces the project's data directories with the contents
+// of a snapshot. The archive is verified and fully extracted before the
+// current data is removed, so a bad archive leaves the project untouched.
+func restoreSnapshot(db *database.Database, mgr *docker.Manager, proj *database.Project, id int) error {
+	if err := requireLocal(proj, "Restoring snapshots"); err != nil {
+		return err
+	}
+	snap, err := db.GetSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if snap == nil || snap.Project != proj.Name {
+		return fmt.Errorf("snapshot %d not found for project %s", id, proj.Name)
+	}
+
+	fmt.Printf("Verifying snapshot %d (%s)...\n", snap.ID, snap.Label)
+	size, sum, err := fileChecksum(filepath.Join(proj.Path, snap.File))
+	if err != nil {
+		return err
+	}
+	if size != snap.Size || sum != snap.SHA256 {
+		return fmt.Errorf("snapshot %s does not match its recorded checksum; refusing to restore", snap.File)
+	}
+
+	wasRunning, err := stopForData(mgr, proj.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Restoring data directories...")
+	if _, err := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", restoreArchiveScript(proj.Storage, snap.File)}); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	fmt.Printf("Snapshot %d restored.\n", snap.ID)
+
+	// The restored databases only accept the logins they were archived with
+	if snap.Credentials != nil {
+		if err := db.SetCredentials(proj.Name, *snap.Credentials); err != nil {
+			return fmt.Errorf("failed to restore the snapshot's credentials: %w", err)
+		}
+		fmt.Println("Credentials restored to those of the snapshot.")
+	} else if rotatedSince(db, proj.Name, snap.CreatedAt) {
+		fmt.Printf("Warning: Snapshot %d predates stored snapshot credentials and the credentials were rotated after it was taken. The restored databases expect the old passwords.\n", snap.ID)
+	}
+
+	if wasRunning {
+		return restartProject(db, mgr, proj)
+	}
+	return nil
+}
+
+// rotatedSince reports whether the history records a successful credential
+// rotation of the project after t.
+func rotatedSince(db *database.Database, project string, t time.Time) bool {
+	events, err := db.ListEvents(project)
+	if err != nil {
+		return false
+	}
+	for _, e := range events {
+		if e.Operation == OP_ROTATE_CREDS && e.Outcome == database.OUTCOME_OK && e.StartedAt.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSnapshot removes a snapshot's archive and its record.
+func deleteSnapshot(db *database.Database, proj *database.Project, id int) error {
+	snap, err := db.GetSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if snap == nil || snap.Project != proj.Name {
+		return fmt.Errorf("snapshot %d not found for project %s", id, proj.Name)
+	}
+	if err := os.Remove(filepath.Join(proj.Path, snap.File)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return db.DeleteSnapshot(id)
+}
+
+func listSnapshots(db *database.Database, proj *database.Project) error {
+	snaps, err := db.ListSnapshots(proj.Name)
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		fmt.Printf("No snapshots for project %s.\n", proj.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tLABEL\tSIZE\tSHA256\tFILE")
+	for _, s := range snaps {
+		file := s.File
+		if _, err := os.Stat(filepath.Join(proj.Path, s.File)); err != nil {
+			file += " (MISSING)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.CreatedAt.Format(time.RFC822), s.Label, formatSize(dataSize{Bytes: s.Size}), s.SHA256[:min(12, len(s.SHA256))], file)
+	}
+	return w.Flush()
+}
+
+// stopForData stops the project so its data files can be copied or replaced,
+// and reports whether it was running.
+func stopForData(mgr *docker.Manager, name string) (bool, error) {
+	running, err := mgr.IsRunning(name)
+	if err != nil {
+		return false, err
+	}
+	if !running {
+		return false, nil
+	}
+	fmt.Printf("Stopping containers for project %s...\n", name)
+	if err := mgr.StopProjectContainers(name); err != nil {
+		return true, fmt.Errorf("failed to stop containers: %w", err)
+	}
+	if still, _ := mgr.IsRunning(name); still {
+		return true, fmt.Errorf("containers for project %s are still running", name)
+	}
+	return true, nil
+}
+
+// restartProject starts a stopped project again from its stored settings.
+func restartProject(db *database.Database, mgr *docker.Manager, proj *database.Project) error {
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return fmt.Errorf("no credentials stored for project %s", proj.Name)
+	}
+	images, err := projectImages(db, mgr, proj, false, "", true)
+	if err != nil {
+		return err
+	}
+	return spawnStored(mgr, proj, *creds, images, projectPorts(proj))
+}
+
+// spawnStored starts a project's containers from its stored settings with
+// the given images and ports.
+func spawnStored(mgr *docker.Manager, proj *database.Project, creds database.Credentials, images docker.Images, ports docker.Ports) error {
+	neo4j, err := projectNeo4j(proj)
+	if err != nil {
+		return err
+	}
+	netName, err := mgr.EnsureNetwork(proj.Name, proj.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restarting project %s...\n", proj.Name)
+	_, err = spawnProject(mgr, proj.Name, proj.Path, proj.Storage, netName, neo4j, images, storedResources(proj), creds, ports)
+	return err
+}
+
+// archiveDataScript returns toolbox shell commands that archive the data
+// folders to file below the project path. The data is owned by the container
+// users, so it is archived as root and the finished archive is handed to the
+// host user.
+func archiveDataScript(storage, file string) string {
+	return fmt.Sprintf("tar --numeric-owner -czf /data/%[1]s.partial -C %[5]s %[2]s && chown %[3]d:%[4]d /data/%[1]s.partial && mv /data/%[1]s.partial /data/%[1]s",
+		file, strings.Join(snapshotDirs, " "), os.Getuid(), os.Getgid(), docker.DataRoot(storage))
+}
+
+// restoreArchiveScript returns toolbox shell commands that replace the data
+// folders with the contents of an archive written by archiveDataScript. The
+// archive is fully extracted before the current data is removed.
+func restoreArchiveScript(storage, file string) string {
+	return fmt.Sprintf("rm -rf /data/.restore && mkdir /data/.restore && tar --numeric-owner -xzf /data/%s -C /data/.restore && %s && rm -rf /data/.restore",
+		file, replaceDataScript(storage, "/data/.restore"))
+}
+
+// replaceDataScript returns toolbox shell commands that replace the project's
+// data folders with the ones below src. Bind folders are swapped by rename;
+// volume mount points cannot be renamed, so their contents are replaced.
+func replaceDataScript(storage, src string) string {
+	var cmds []string
+	for _, dir := range snapshotDirs {
+		if storage == docker.STORAGE_VOLUME {
+			dst := path.Join(docker.TOOLBOX_VOLUME_ROOT, dir)
+			cmds = append(cmds, fmt.Sprintf("find %[1]s -mindepth 1 -delete && cp -a %[2]s/%[3]s/. %[1]s/", dst, src, dir))
+		} else {
+			cmds = append(cmds, fmt.Sprintf("rm -rf /data/%[1]s && mv %[2]s/%[1]s /data/%[1]s", dir, src))
+		}
+	}
+	return strings.Join(cmds, " && ")
+}
+
+func fileChecksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
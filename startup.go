@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/importer"
+	"github.com/Mortimus/SiloHound/internal/plan"
+)
+
+// startup holds what the steps of a project start need and what they
+// produce, so later steps and undo functions can use earlier results.
+type startup struct {
+	mgr        *docker.Manager
+	name       string
+	workingDir string
+	storage    string
+	neo4j      docker.Neo4jOptions // Version and plugins; memory comes from resources
+	images     docker.Images
+	resources  database.Resources
+	creds      database.Credentials
+	ports      docker.Ports
+
+	netName        string
+	createdNetwork bool
+	psqlID         string
+}
+
+// networkStep creates the project network. It is only removed again on
+// rollback if this run created it.
+func (s *startup) networkStep() plan.Step {
+	return plan.Step{
+		Name: "Create network",
+		Do: func() error {
+			existed, err := s.mgr.NetworkExists(s.name)
+			if err != nil {
+				return err
+			}
+			s.netName, err = s.mgr.EnsureNetwork(s.name, s.workingDir)
+			s.createdNetwork = err == nil && !existed
+			return err
+		},
+		Undo: func() error {
+			if !s.createdNetwork {
+				return nil
+			}
+			return s.mgr.RemoveNetwork(s.name)
+		},
+	}
+}
+
+// volumeStep creates the data volumes of a project in volume storage mode.
+// They hold the project's data, so rollback never removes them.
+func (s *startup) volumeStep() plan.Step {
+	return plan.Step{
+		Name: "Create data volumes",
+		Do: func() error {
+			if s.storage != docker.STORAGE_VOLUME {
+				return nil
+			}
+			return s.mgr.EnsureVolumes(s.name, s.workingDir)
+		},
+	}
+}
+
+// containerSteps starts Postgres, Neo4j and BloodHound in order.
+func (s *startup) containerSteps() []plan.Step {
+	return []plan.Step{
+		s.roleStep(docker.ROLE_POSTGRES),
+		s.roleStep(docker.ROLE_NEO4J),
+		s.roleStep(docker.ROLE_BLOODHOUND),
+	}
+}
+
+// roleStep starts the container of one service role.
+func (s *startup) roleStep(role string) plan.Step {
+	switch role {
+	case docker.ROLE_POSTGRES:
+		return plan.Step{
+			Name: "Start Postgres",
+			Do: func() error {
+				id, err := s.mgr.SpawnPostgres(s.name, s.workingDir, s.storage, s.netName, s.images.Postgres, s.creds.PostgresPassword, roleLimits(s.resources, docker.ROLE_POSTGRES))
+				if err != nil {
+					return err
+				}
+				s.psqlID = id
+				fmt.Printf("Postgres started (ID: %s)\n", id[:12])
+				return nil
+			},
+			Undo: s.stopRole(docker.ROLE_POSTGRES),
+		}
+	case docker.ROLE_NEO4J:
+		return plan.Step{
+			Name: "Start Neo4j",
+			Do: func() error {
+				id, err := s.mgr.SpawnNeo4j(s.name, s.workingDir, s.storage, s.netName, s.images.Neo4j, s.neo4jOptions(), s.creds.Neo4jPassword, s.ports, roleLimits(s.resources, docker.ROLE_NEO4J))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Neo4j started (ID: %s) with heap size %s\n", id[:12], s.resources.Neo4jHeap)
+				return nil
+			},
+			Undo: s.stopRole(docker.ROLE_NEO4J),
+		}
+	default:
+		return plan.Step{
+			Name: "Start BloodHound",
+			Do: func() error {
+				id, err := s.mgr.SpawnBloodhound(s.name, s.workingDir, s.netName, s.images.BloodHound, s.creds.AdminUser, s.creds.AdminPassword, s.creds.PostgresPassword, s.creds.Neo4jPassword, s.ports, roleLimits(s.resources, docker.ROLE_BLOODHOUND))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("BloodHound started (ID: %s)\n", id[:12])
+				return nil
+			},
+			Undo: s.stopRole(docker.ROLE_BLOODHOUND),
+		}
+	}
+}
+
+func (s *startup) neo4jOptions() docker.Neo4jOptions {
+	opts := s.neo4j
+	opts.Memory = neo4jMemory(s.resources)
+	return opts
+}
+
+func (s *startup) stopRole(role string) func() error {
+	return func() error {
+		return s.mgr.StopProjectContainer(s.name, role)
+	}
+}
+
+// expiryStep pushes the admin password expiry out by a year. Undo restores
+// the previous value.
+func (s *startup) expiryStep() plan.Step {
+	var previous string
+	var saved bool
+	return plan.Step{
+		Name: "Extend password expiry",
+		Do: func() error {
+			fmt.Println("Updating password expiration to 1 year...")
+			prev, err := psqlQuery(s.mgr, s.psqlID, "SELECT expires_at FROM auth_secrets WHERE id='1';")
+			if err != nil {
+				return err
+			}
+			previous, saved = prev, true
+
+			expDate := time.Now().AddDate(1, 0, 0).Format("2006-01-02 15:04:05")
+			out, err := psqlQuery(s.mgr, s.psqlID, fmt.Sprintf("UPDATE auth_secrets SET expires_at='%s' WHERE id='1';", expDate))
+			if err != nil {
+				return err
+			}
+			if out != "UPDATE 1" {
+				return fmt.Errorf("admin secret not found (psql reported %q)", out)
+			}
+			return nil
+		},
+		Undo: func() error {
+			if !saved {
+				return nil
+			}
+			value := "NULL"
+			if previous != "" {
+				value = "'" + escapeSQL(previous) + "'"
+			}
+			_, err := psqlQuery(s.mgr, s.psqlID, fmt.Sprintf("UPDATE auth_secrets SET expires_at=%s WHERE id='1';", value))
+			return err
+		},
+	}
+}
+
+// queryStep injects saved queries loaded by load. Undo deletes the queries
+// this step inserted; queries that were already present are left alone.
+func (s *startup) queryStep(name string, load func() (importer.BloodHoundQueries, bool)) plan.Step {
+	var inserted []string
+	return plan.Step{
+		Name: name,
+		Do: func() error {
+			queries, ok := load()
+			if !ok {
+				return nil
+			}
+			var err error
+			inserted, err = injectQueries(s.mgr, s.psqlID, s.creds.AdminUser, queries)
+			return err
+		},
+		Undo: func() error {
+			if len(inserted) == 0 {
+				return nil
+			}
+			names := make([]string, len(inserted))
+			for i, n := range inserted {
+				names[i] = "'" + escapeSQL(n) + "'"
+			}
+			sql := fmt.Sprintf("DELETE FROM saved_queries WHERE user_id = (SELECT id FROM users WHERE principal_name = '%s') AND name IN (%s);", escapeSQL(s.creds.AdminUser), strings.Join(names, ", "))
+			_, err := psqlQuery(s.mgr, s.psqlID, sql)
+			return err
+		},
+	}
+}
+
+// libraryQueries clones the SpecterOps Query Library into the project. Being
+// offline is not a startup failure, so clone errors are only reported.
+func libraryQueries(workingDir string) func() (importer.BloodHoundQueries, bool) {
+	return func() (importer.BloodHoundQueries, bool) {
+		fmt.Printf("Cloning Query Library...\n")
+		dest := filepath.Join(workingDir, "BloodHoundQueryLibrary")
+		if err := importer.CloneQueryLibrary(dest); err != nil {
+			fmt.Printf("Failed to clone/load library: %v\n", err)
+			return importer.BloodHoundQueries{}, false
+		}
+		fmt.Printf("Loading queries from library...\n")
+		queries, err := importer.LoadQueriesFromDir(dest)
+		if err != nil {
+			fmt.Printf("Failed to clone/load library: %v\n", err)
+			return importer.BloodHoundQueries{}, false
+		}
+		return queries, true
+	}
+}
+
+// printStartupFailure reports every step's outcome after a failed start.
+func printStartupFailure(report *plan.Report, name string) {
+	fmt.Println("\nStartup failed. Step summary:")
+	report.Print(os.Stdout)
+	if report.Kept {
+		fmt.Println("Completed steps were left in place for inspection (-debug).")
+		fmt.Printf("Run '%s -name %s -stop' to clean up.\n", os.Args[0], name)
+	}
+}
+
+// spawnProject starts Postgres, Neo4j and BloodHound in order and returns the
+// Postgres container ID. Containers that did start are removed again if a
+// later one fails.
+func spawnProject(mgr *docker.Manager, name, workingDir, storage, netName string, neo4j docker.Neo4jOptions, images docker.Images, resources database.Resources, creds database.Credentials, ports docker.Ports) (string, error) {
+	s := &startup{
+		mgr:        mgr,
+		name:       name,
+		workingDir: workingDir,
+		storage:    storage,
+		neo4j:      neo4j,
+		netName:    netName,
+		images:     images,
+		resources:  resources,
+		creds:      creds,
+		ports:      ports,
+	}
+	p := plan.New()
+	p.Add(s.volumeStep())
+	p.Add(s.containerSteps()...)
+	if _, err := p.Run(false); err != nil {
+		return "", err
+	}
+	return s.psqlID, nil
+}
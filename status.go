@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+type roleStatus struct {
+	Role          string   `json:"role"`
+	Container     string   `json:"container,omitempty"`
+	State         string   `json:"state"`
+	Health        string   `json:"health,omitempty"`
+	StartedAt     string   `json:"started_at,omitempty"`
+	UptimeSeconds int64    `json:"uptime_seconds,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	ImageID       string   `json:"image_id,omitempty"`
+	Ports         []string `json:"ports,omitempty"`
+}
+
+type dataSize struct {
+	Bytes int64 `json:"bytes"`
+	// Partial is set when some files could not be read, usually because they
+	// belong to a container user; Bytes is then a lower bound.
+	Partial bool `json:"partial,omitempty"`
+}
+
+type projectStatus struct {
+	Name       string              `json:"name"`
+	RemoteHost string              `json:"remote_host,omitempty"`
+	Path       string              `json:"path"`
+	PathExists bool                `json:"path_exists"`
+	Storage    string              `json:"storage"`
+	Neo4j      string              `json:"neo4j_version"`
+	Plugins    string              `json:"neo4j_plugins"`
+	Neo4jHeap  string              `json:"neo4j_heap,omitempty"`
+	Resources  string              `json:"resources,omitempty"`
+	DataSizes  map[string]dataSize `json:"data_sizes,omitempty"`
+	Roles      []roleStatus        `json:"roles"`
+}
+
+// statusRoles are the service roles reported, in startup order.
+var statusRoles = []string{docker.ROLE_POSTGRES, docker.ROLE_NEO4J, docker.ROLE_BLOODHOUND}
+
+// baseStatus is what the project database knows about a project.
+func baseStatus(p database.Project) projectStatus {
+	st := projectStatus{Name: p.Name, RemoteHost: p.SSHHost, Path: p.Path, Storage: p.Storage, Neo4j: p.Neo4jVersion, Plugins: p.Neo4jPlugins, Neo4jHeap: p.Neo4jHeap, Roles: []roleStatus{}}
+	if p.Neo4jHeap != "" {
+		st.Resources = formatResources(p.Resources())
+	}
+	return st
+}
+
+// collectStatus inspects a project on mgr, which must be the engine the
+// project runs on. The data of remote projects is not sized: their volumes
+// are only reachable through the remote engine's toolbox.
+func collectStatus(mgr *docker.Manager, p database.Project) (projectStatus, error) {
+	st := baseStatus(p)
+	if p.SSHHost == "" {
+		if info, err := os.Stat(p.Path); err == nil && info.IsDir() {
+			st.PathExists = true
+			st.DataSizes = projectDataSizes(mgr, &p)
+		}
+	}
+
+	containers, err := mgr.InspectProject(p.Name)
+	if err != nil {
+		return st, err
+	}
+	for _, role := range statusRoles {
+		rs := roleStatus{Role: role, State: "absent"}
+		if c, ok := containers[role]; ok {
+			rs.Container = c.Name
+			rs.State = c.State
+			rs.Health = c.Health
+			rs.Image = c.Image
+			rs.ImageID = c.ImageID
+			if c.Running && !c.StartedAt.IsZero() {
+				rs.StartedAt = c.StartedAt.Format(time.RFC3339)
+				rs.UptimeSeconds = int64(time.Since(c.StartedAt).Seconds())
+			}
+			for _, pb := range c.Ports {
+				rs.Ports = append(rs.Ports, fmt.Sprintf("%s:%d->%d", pb.HostIP, pb.HostPort, pb.ContainerPort))
+			}
+		}
+		st.Roles = append(st.Roles, rs)
+	}
+	return st, nil
+}
+
+// dirSize sums the size of all regular files under path. Unreadable entries
+// are skipped and mark the result as partial.
+func dirSize(path string) dataSize {
+	var size dataSize
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				size.Partial = true
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			size.Partial = true
+			return nil
+		}
+		size.Bytes += info.Size()
+		return nil
+	})
+	return size
+}
+
+// printStatus shows the status of one project, or of all projects when name
+// is empty, as a table or as JSON.
+func printStatus(db *database.Database, mgr *docker.Manager, name string, asJSON bool) error {
+	var projects []database.Project
+	if name != "" {
+		p, err := db.GetProject(name)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return fmt.Errorf("project %s not found", name)
+		}
+		projects = append(projects, *p)
+	} else {
+		var err error
+		if projects, err = db.ListProjects(); err != nil {
+			return err
+		}
+	}
+
+	statuses := []projectStatus{}
+	for _, p := range projects {
+		// Without -name, mgr is this host's engine, which knows nothing
+		// about remote projects
+		if p.SSHHost != "" && name == "" {
+			statuses = append(statuses, baseStatus(p))
+			continue
+		}
+		st, err := collectStatus(mgr, p)
+		if err != nil {
+			return fmt.Errorf("failed to inspect project %s: %w", p.Name, err)
+		}
+		statuses = append(statuses, st)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+
+	for i, st := range statuses {
+		if i > 0 {
+			fmt.Println()
+		}
+		printProjectStatus(st)
+	}
+	return nil
+}
+
+func printProjectStatus(st projectStatus) {
+	fmt.Printf("Project %s\n", st.Name)
+	if st.RemoteHost != "" {
+		fmt.Printf("  Host:       REMOTE on %s\n", st.RemoteHost)
+	}
+	if st.PathExists || st.RemoteHost != "" {
+		fmt.Printf("  Path:       %s\n", st.Path)
+	} else {
+		fmt.Printf("  Path:       %s (MISSING)\n", st.Path)
+	}
+	res := st.Resources
+	if res == "" {
+		res = "not recorded"
+	}
+	plugins := st.Plugins
+	if plugins == "" {
+		plugins = docker.PLUGINS_NONE
+	}
+	fmt.Printf("  Neo4j:      %s (plugins: %s)\n", docker.ReferenceTag(docker.Neo4jImage(st.Neo4j)), plugins)
+	fmt.Printf("  Resources:  %s\n", res)
+	if st.PathExists {
+		fmt.Printf("  Data:       postgres %s, neo4j %s (%s storage)\n", formatSize(st.DataSizes[docker.ROLE_POSTGRES]), formatSize(st.DataSizes[docker.ROLE_NEO4J]), st.Storage)
+	}
+	if st.RemoteHost != "" && len(st.Roles) == 0 {
+		fmt.Printf("  Containers: run %s -name %s -status to inspect them on %s\n", os.Args[0], st.Name, st.RemoteHost)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  ROLE\tSTATE\tHEALTH\tUPTIME\tPORTS\tIMAGE")
+	for _, rs := range st.Roles {
+		health := rs.Health
+		if health == "" {
+			health = "-"
+		}
+		uptime := "-"
+		if rs.StartedAt != "" {
+			uptime = (time.Duration(rs.UptimeSeconds) * time.Second).String()
+		}
+		ports := strings.Join(rs.Ports, ",")
+		if ports == "" {
+			ports = "-"
+		}
+		image := rs.Image
+		if image == "" {
+			image = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", rs.Role, rs.State, health, uptime, ports, image)
+	}
+	w.Flush()
+}
+
+func formatSize(s dataSize) string {
+	const unit = 1024
+	str := fmt.Sprintf("%d B", s.Bytes)
+	if s.Bytes >= unit {
+		div, exp := int64(unit), 0
+		for n := s.Bytes / unit; n >= unit; n /= unit {
+			div *= unit
+			exp++
+		}
+		str = fmt.Sprintf("%.1f %ciB", float64(s.Bytes)/float64(div), "KMGTPE"[exp])
+	}
+	if s.Partial {
+		str = ">= " + str
+	}
+	return str
+}
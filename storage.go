@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// migrateProjectStorage moves a project's Postgres and Neo4j data between
+// bind folders and named volumes. The project is stopped during the copy and
+// restarted afterwards if it was running.
+func migrateProjectStorage(db *database.Database, mgr *docker.Manager, proj *database.Project, mode string) error {
+	if !docker.ValidStorage(mode) {
+		return fmt.Errorf("unknown storage mode %q (use %s or %s)", mode, docker.STORAGE_BIND, docker.STORAGE_VOLUME)
+	}
+	if err := requireLocal(proj, "Changing storage"); err != nil {
+		return err
+	}
+	if proj.Storage == mode {
+		fmt.Printf("Project %s already uses %s storage.\n", proj.Name, mode)
+		return nil
+	}
+
+	wasRunning, err := stopForData(mgr, proj.Name)
+	if err != nil {
+		return err
+	}
+
+	// Both copies run in a volume-mode toolbox, which sees the bind folders
+	// below /data and the volumes below /volumes
+	var copies []string
+	for _, dir := range snapshotDirs {
+		bind := path.Join(docker.TOOLBOX_PROJECT_ROOT, dir)
+		volume := path.Join(docker.TOOLBOX_VOLUME_ROOT, dir)
+		if mode == docker.STORAGE_VOLUME {
+			copies = append(copies, fmt.Sprintf("find %[2]s -mindepth 1 -delete && cp -a %[1]s/. %[2]s/", bind, volume))
+		} else {
+			copies = append(copies, fmt.Sprintf("mkdir -p %[1]s && cp -a %[2]s/. %[1]s/", bind, volume))
+		}
+	}
+	if mode == docker.STORAGE_VOLUME {
+		if err := mgr.EnsureVolumes(proj.Name, proj.Path); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Copying data for project %s to %s storage...\n", proj.Name, mode)
+	if _, err := mgr.RunDataToolbox(proj.Name, docker.STORAGE_VOLUME, proj.Path, []string{"sh", "-c", strings.Join(copies, " && ")}); err != nil {
+		if mode == docker.STORAGE_VOLUME {
+			mgr.RemoveProjectVolumes(proj.Name)
+		}
+		return fmt.Errorf("failed to copy data: %w", err)
+	}
+	if err := db.UpdateProjectStorage(proj.Name, mode); err != nil {
+		return err
+	}
+	proj.Storage = mode
+
+	// The old copy is only removed once the project points at the new one
+	if mode == docker.STORAGE_VOLUME {
+		if _, err := mgr.RunToolbox(proj.Path, []string{"rm", "-rf", "/data/" + DATA_DIR}); err != nil {
+			fmt.Printf("Warning: Failed to remove old data folders: %v\n", err)
+		}
+	} else if err := mgr.RemoveProjectVolumes(proj.Name); err != nil {
+		fmt.Printf("Warning: Failed to remove old volumes: %v\n", err)
+	}
+	fmt.Printf("Project %s now uses %s storage.\n", proj.Name, mode)
+
+	if wasRunning {
+		return restartProject(db, mgr, proj)
+	}
+	return nil
+}
+
+// projectDataSizes returns the size of the Postgres and Neo4j data. Bind
+// folders are walked on the host; volumes are measured with du in a
+// toolbox, since their contents are not reachable from the host.
+func projectDataSizes(mgr *docker.Manager, p *database.Project) map[string]dataSize {
+	if p.Storage != docker.STORAGE_VOLUME {
+		return map[string]dataSize{
+			docker.ROLE_POSTGRES: dirSize(filepath.Join(p.Path, docker.PSQLFOLDER)),
+			docker.ROLE_NEO4J:    dirSize(filepath.Join(p.Path, docker.NEO4JFOLDER)),
+		}
+	}
+
+	sizes := map[string]dataSize{
+		docker.ROLE_POSTGRES: {Partial: true},
+		docker.ROLE_NEO4J:    {Partial: true},
+	}
+	dirs := map[string]string{
+		path.Join(docker.TOOLBOX_VOLUME_ROOT, docker.PSQLFOLDER):  docker.ROLE_POSTGRES,
+		path.Join(docker.TOOLBOX_VOLUME_ROOT, docker.NEO4JFOLDER): docker.ROLE_NEO4J,
+	}
+	out, err := mgr.RunDataToolbox(p.Name, p.Storage, p.Path, []string{"du", "-sk",
+		path.Join(docker.TOOLBOX_VOLUME_ROOT, docker.PSQLFOLDER), path.Join(docker.TOOLBOX_VOLUME_ROOT, docker.NEO4JFOLDER)})
+	if err != nil {
+		return sizes
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if role, ok := dirs[fields[1]]; ok && err == nil {
+			sizes[role] = dataSize{Bytes: kb << 10}
+		}
+	}
+	return sizes
+}
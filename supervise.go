@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// CRASH_DIR holds the logs captured from crashed containers, below the
+// project path.
+const CRASH_DIR = "crashes"
+
+// superviseProject watches a running project and restarts containers that
+// exit unexpectedly, recording each crash with the container's last logs.
+// It blocks until the project is stopped or the supervisor gives up.
+func superviseProject(db *database.Database, mgr *docker.Manager, proj *database.Project) error {
+	cfg := docker.DefaultSupervisorConfig()
+	cfg.Respawn = func(role string) error {
+		return respawnRole(db, mgr, proj, role)
+	}
+	cfg.OnCrash = func(c docker.Crash) {
+		if err := recordCrash(db, proj, c); err != nil {
+			fmt.Printf("Warning: Failed to record crash: %v\n", err)
+		}
+	}
+	return mgr.Supervise(proj.Name, cfg)
+}
+
+// respawnRole starts a fresh container for one role from the project's stored
+// settings, the same way a normal start does.
+func respawnRole(db *database.Database, mgr *docker.Manager, proj *database.Project, role string) error {
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return fmt.Errorf("no credentials stored for project %s", proj.Name)
+	}
+	images, err := projectImages(db, mgr, proj, false, "", true)
+	if err != nil {
+		return err
+	}
+	neo4j, err := projectNeo4j(proj)
+	if err != nil {
+		return err
+	}
+	netName, err := mgr.EnsureNetwork(proj.Name, proj.Path)
+	if err != nil {
+		return err
+	}
+	s := &startup{
+		mgr:        mgr,
+		name:       proj.Name,
+		workingDir: proj.Path,
+		storage:    proj.Storage,
+		neo4j:      neo4j,
+		netName:    netName,
+		images:     images,
+		resources:  storedResources(proj),
+		creds:      *creds,
+		ports:      projectPorts(proj),
+	}
+	return s.roleStep(role).Do()
+}
+
+// recordCrash writes the crashed container's last logs below the project
+// path and adds the crash to the project's history.
+func recordCrash(db *database.Database, proj *database.Project, c docker.Crash) error {
+	entry := database.Crash{
+		Project:   proj.Name,
+		Role:      c.Role,
+		Container: c.Container,
+		ExitCode:  c.ExitCode,
+		OOM:       c.OOM,
+		Attempts:  c.Attempts,
+		Restarted: c.RestartErr == nil,
+		CrashedAt: c.Time,
+	}
+	if c.RestartErr != nil {
+		entry.Error = c.RestartErr.Error()
+	}
+
+	file := filepath.Join(CRASH_DIR, fmt.Sprintf("%s-%s.log", c.Time.Format("20060102-150405"), c.Role))
+	if err := os.MkdirAll(filepath.Join(proj.Path, CRASH_DIR), 0755); err != nil {
+		fmt.Printf("Warning: Failed to save crash logs: %v\n", err)
+	} else if err := os.WriteFile(filepath.Join(proj.Path, file), []byte(c.Logs), 0644); err != nil {
+		fmt.Printf("Warning: Failed to save crash logs: %v\n", err)
+	} else {
+		entry.LogFile = file
+		fmt.Printf("Last logs of %s saved to %s\n", c.Container, filepath.Join(proj.Path, file))
+	}
+
+	_, err := db.AddCrash(entry)
+	return err
+}
+
+// printCrashes lists a project's crash history.
+func printCrashes(db *database.Database, proj *database.Project) error {
+	crashes, err := db.ListCrashes(proj.Name)
+	if err != nil {
+		return err
+	}
+	if len(crashes) == 0 {
+		fmt.Printf("No crashes recorded for project %s.\n", proj.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tROLE\tEXIT\tOOM\tRESTARTED\tLOGS")
+	for _, c := range crashes {
+		restarted := "yes"
+		if !c.Restarted {
+			restarted = "no: " + c.Error
+		}
+		oom := ""
+		if c.OOM {
+			oom = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", c.CrashedAt.Local().Format(time.RFC822), c.Role, c.ExitCode, oom, restarted, c.LogFile)
+	}
+	return w.Flush()
+}
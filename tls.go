@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+	"github.com/Mortimus/SiloHound/internal/tlsproxy"
+)
+
+const (
+	// TLS_DIR holds the project's CA and server certificate below its path.
+	TLS_DIR          = "tls"
+	DEFAULT_TLS_BIND = "127.0.0.1"
+	DEFAULT_TLS_PORT = 8443
+)
+
+// tlsFlags are the TLS endpoint options from the command line. Only the
+// flags in set were given; everything else keeps the project's stored value.
+type tlsFlags struct {
+	set   map[string]bool
+	bind  string
+	port  int
+	allow string
+	hosts string
+}
+
+// tlsEndpoint is a project's resolved TLS endpoint.
+type tlsEndpoint struct {
+	bind  string
+	port  int
+	allow tlsproxy.Allowlist
+	hosts []string
+}
+
+func (e tlsEndpoint) addr() string {
+	return net.JoinHostPort(e.bind, strconv.Itoa(e.port))
+}
+
+// resolveTLS merges the given flags into the project's stored TLS settings,
+// validates them and stores the result.
+func resolveTLS(db *database.Database, proj *database.Project, f tlsFlags) (tlsEndpoint, error) {
+	bind, port, allow := proj.TLSBind, proj.TLSPort, proj.TLSAllow
+	if f.set["tls-bind"] || bind == "" {
+		bind = f.bind
+	}
+	if f.set["tls-port"] {
+		port = f.port
+	}
+	if f.set["tls-allow"] {
+		allow = f.allow
+	}
+
+	if net.ParseIP(bind) == nil {
+		return tlsEndpoint{}, fmt.Errorf("invalid -tls-bind %q: expected an IP address", bind)
+	}
+	allowlist, err := tlsproxy.ParseAllowlist(allow)
+	if err != nil {
+		return tlsEndpoint{}, err
+	}
+	if port == 0 {
+		if port, err = pickTLSPort(db, proj.Name, bind); err != nil {
+			return tlsEndpoint{}, err
+		}
+	}
+	if port < 1 || port > 65535 {
+		return tlsEndpoint{}, fmt.Errorf("invalid -tls-port %d", port)
+	}
+	if err := db.UpdateProjectTLS(proj.Name, bind, port, allow); err != nil {
+		return tlsEndpoint{}, err
+	}
+	proj.TLSBind, proj.TLSPort, proj.TLSAllow = bind, port, allow
+
+	return tlsEndpoint{bind: bind, port: port, allow: allowlist, hosts: certHosts(bind, f.hosts)}, nil
+}
+
+// pickTLSPort returns the first port from DEFAULT_TLS_PORT up that no other
+// project uses and that can be bound.
+func pickTLSPort(db *database.Database, name, bind string) (int, error) {
+	projects, err := db.ListProjects()
+	if err != nil {
+		return 0, err
+	}
+	reserved := map[int]bool{}
+	for _, p := range projects {
+		if p.Name != name {
+			for _, port := range []int{p.TLSPort, p.BHPort, p.Neo4jHTTPPort, p.Neo4jBoltPort} {
+				reserved[port] = true
+			}
+		}
+	}
+	for port := DEFAULT_TLS_PORT; port < DEFAULT_TLS_PORT+100; port++ {
+		if reserved[port] {
+			continue
+		}
+		if l, err := net.Listen("tcp", net.JoinHostPort(bind, strconv.Itoa(port))); err == nil {
+			l.Close()
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free TLS port near %d; choose one with -tls-port", DEFAULT_TLS_PORT)
+}
+
+// certHosts lists the names and addresses the server certificate must cover:
+// loopback, this host's name, the bind address (or every interface address
+// when binding to all of them) and any extra names given.
+func certHosts(bind, extra string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	if ip := net.ParseIP(bind); ip != nil && ip.IsUnspecified() {
+		if addrs, err := net.InterfaceAddrs(); err == nil {
+			for _, a := range addrs {
+				if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+					hosts = append(hosts, ipnet.IP.String())
+				}
+			}
+		}
+	} else {
+		hosts = append(hosts, bind)
+	}
+	for _, h := range strings.Split(extra, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	seen := map[string]bool{}
+	var out []string
+	for _, h := range hosts {
+		if !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// serveTLS terminates TLS for the project's BloodHound UI until ctx ends.
+func serveTLS(ctx context.Context, proj *database.Project, ep tlsEndpoint) error {
+	dir := filepath.Join(proj.Path, TLS_DIR)
+	certs, issued, err := tlsproxy.EnsureCertificates(dir, proj.Name, ep.hosts)
+	if err != nil {
+		return err
+	}
+	if issued {
+		fmt.Printf("Issued a TLS certificate for %s\n", strings.Join(ep.hosts, ", "))
+	}
+
+	ip := net.ParseIP(ep.bind)
+	switch {
+	case ep.allow.Any():
+		fmt.Println("WARNING: -tls-allow any admits every client that can reach this host.")
+	case !ip.IsLoopback() && ep.allow.LoopbackOnly():
+		fmt.Println("Note: No -tls-allow given, so only clients on this host are admitted.")
+	}
+
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(proj.BHPort))}
+	fmt.Printf("Serving BloodHound over HTTPS on https://%s (clients: %s)\n", ep.addr(), ep.allow)
+	fmt.Printf("Teammates must trust the project CA: %s\n", certs.CACert)
+	return tlsproxy.Serve(ctx, ep.addr(), certs, target, ep.allow, log.New(os.Stderr, "[TLS] ", log.LstdFlags))
+}
+
+// runForeground keeps SiloHound attached to a running project, serving the
+// TLS endpoint and/or supervising its containers. It returns when the
+// supervisor ends or the endpoint fails.
+func runForeground(db *database.Database, mgr *docker.Manager, proj *database.Project, ep *tlsEndpoint, supervise bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tlsErr := make(chan error, 1)
+	if ep != nil {
+		go func() { tlsErr <- serveTLS(ctx, proj, *ep) }()
+	}
+	supErr := make(chan error, 1)
+	if supervise {
+		go func() { supErr <- superviseProject(db, mgr, proj) }()
+	}
+
+	select {
+	case err := <-tlsErr:
+		return fmt.Errorf("TLS endpoint stopped: %w", err)
+	case err := <-supErr:
+		cancel()
+		if ep != nil {
+			<-tlsErr
+		}
+		if err != nil {
+			return fmt.Errorf("supervisor stopped: %w", err)
+		}
+		return nil
+	}
+}
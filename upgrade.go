@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Mortimus/SiloHound/internal/database"
+	"github.com/Mortimus/SiloHound/internal/docker"
+)
+
+// upgradeProject moves a project from its pinned images to the current
+// default images. The data directory is backed up first; if the project does
+// not start cleanly on the new images the backup is restored and the old pins
+// are kept.
+func upgradeProject(db *database.Database, mgr *docker.Manager, proj *database.Project, resources database.Resources, debug bool) error {
+	if err := requireLocal(proj, "Upgrading"); err != nil {
+		return err
+	}
+	current := pinnedImages(proj)
+	if current.IsZero() {
+		return fmt.Errorf("project %s has no pinned images yet; start it once before upgrading", proj.Name)
+	}
+
+	creds, err := db.GetCredentials(proj.Name)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return fmt.Errorf("no credentials stored for project %s; start it once first", proj.Name)
+	}
+
+	// Fetch the new images before touching anything so a failed pull costs no downtime
+	fmt.Println("Pulling latest images...")
+	local, err := ensureImages(mgr, docker.DefaultImagesFor(proj.Neo4jVersion), true, "", true)
+	if err != nil {
+		return err
+	}
+	next, err := mgr.ResolveImages(local)
+	if err != nil {
+		return err
+	}
+	if next == current {
+		fmt.Println("Project images are already up to date.")
+		return nil
+	}
+	fmt.Println("Current images:")
+	printImages(current)
+	fmt.Println("Upgrading to:")
+	printImages(next)
+
+	fmt.Printf("Stopping containers for project %s...\n", proj.Name)
+	if err := mgr.StopProjectContainers(proj.Name); err != nil {
+		return fmt.Errorf("failed to stop containers: %w", err)
+	}
+
+	backup := fmt.Sprintf("bloodhound-data.backup-%s.tar.gz", time.Now().Format("20060102_150405"))
+	fmt.Printf("Backing up bloodhound-data to %s...\n", backup)
+	if _, err := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", archiveDataScript(proj.Storage, backup)}); err != nil {
+		return fmt.Errorf("backup failed, upgrade aborted: %w", err)
+	}
+
+	neo4j, err := projectNeo4j(proj)
+	if err != nil {
+		return err
+	}
+	netName, err := mgr.EnsureNetwork(proj.Name, proj.Path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Starting project on the new images...")
+	if _, err := spawnProject(mgr, proj.Name, proj.Path, proj.Storage, netName, neo4j, next, resources, *creds, projectPorts(proj)); err != nil {
+		if debug {
+			_ = mgr.PrintProjectDiagnostics(proj.Name)
+		}
+		fmt.Printf("Upgrade failed: %v\n", err)
+		fmt.Println("Rolling back...")
+		if rbErr := rollbackUpgrade(mgr, proj, backup); rbErr != nil {
+			return fmt.Errorf("upgrade failed (%v) and rollback failed: %w; the backup is at %s", err, rbErr, filepath.Join(proj.Path, backup))
+		}
+		return fmt.Errorf("upgrade failed and was rolled back to the previous images: %w", err)
+	}
+
+	if err := db.UpdateProjectImages(proj.Name, next.BloodHound, next.Neo4j, next.Postgres); err != nil {
+		return err
+	}
+	if err := db.UpdateProjectResources(proj.Name, resources); err != nil {
+		return err
+	}
+	fmt.Printf("Upgrade complete. The pre-upgrade backup is kept at %s\n", filepath.Join(proj.Path, backup))
+	return nil
+}
+
+func rollbackUpgrade(mgr *docker.Manager, proj *database.Project, backup string) error {
+	if err := mgr.StopProjectContainers(proj.Name); err != nil {
+		return err
+	}
+	restore := fmt.Sprintf("%s && rm -f /data/%s", restoreArchiveScript(proj.Storage, backup), backup)
+	if _, err := mgr.RunDataToolbox(proj.Name, proj.Storage, proj.Path, []string{"sh", "-c", restore}); err != nil {
+		return err
+	}
+	fmt.Println("Data restored. Start the project normally to resume on the previous images.")
+	return nil
+}